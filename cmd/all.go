@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/creydr/knative-lambda-func-migrator-poc/pkg/migrator"
+)
+
+// migrateAll reads the Go files of the package directory except tests, regardless of their build constraints,
+// and writes the Knative function serving every Lambda handler they register to w
+func migrateAll(w io.Writer, dir string, opts migrator.Options) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read input directory: %w", err)
+	}
+
+	var sources []migrator.Source
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sources = append(sources, migrator.Source{Src: content, Filename: path})
+	}
+	return migrator.TransformAllTo(w, sources, opts)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/creydr/knative-lambda-func-migrator-poc/pkg/migrator"
+)
+
+// migrateDir migrates every Go file in the directory tree that registers a Lambda handler, writing each
+// next to its original with the suffix inserted before the .go extension, or in place without a suffix.
+// A summary of the written files is printed to w; failing files are logged and don't stop the migration.
+func migrateDir(w io.Writer, dir, suffix string, exclude []string, opts migrator.Options, backup bool) error {
+	var migrated, failed int
+	err := walkGoFiles(dir, suffix, exclude, func(path string) {
+		output, err := migrateFile(path, suffix, opts, backup)
+		switch {
+		case errors.Is(err, migrator.ErrNoHandler):
+			return
+		case err != nil:
+			log.Printf("Failed to migrate %s: %v", path, err)
+			failed++
+		}
+		if output != "" {
+			fmt.Fprintf(w, "%s -> %s\n", path, output)
+			migrated++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Migrated %d file(s)\n", migrated)
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate %d file(s)", failed)
+	}
+	return nil
+}
+
+// listDir prints the handlers detected in every Go file of the directory tree that registers a Lambda
+// handler, previewing what migrateDir would migrate; failing files are logged and don't stop the listing.
+func listDir(w io.Writer, dir string, exclude []string, opts migrator.Options) error {
+	var failed int
+	err := walkGoFiles(dir, "", exclude, func(path string) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", path, err)
+			failed++
+			return
+		}
+		opts.Filename = path
+
+		var buf bytes.Buffer
+		err = migrator.ListHandlers(&buf, content, opts)
+		var warningsErr *migrator.WarningsError
+		switch {
+		case errors.Is(err, migrator.ErrNoHandler):
+			return
+		case err != nil && !errors.As(err, &warningsErr):
+			log.Printf("Failed to list handlers of %s: %v", path, err)
+			failed++
+			return
+		}
+		fmt.Fprintf(w, "%s:\n%s\n", path, buf.Bytes())
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to list the handlers of %d file(s)", failed)
+	}
+	return nil
+}
+
+// walkGoFiles calls fn with every Go file of the directory tree except tests and files ending with the
+// suffix before the .go extension. Directories the go command ignores and those whose path relative to
+// dir matches one of the exclude glob patterns are skipped.
+func walkGoFiles(dir, suffix string, exclude []string, fn func(path string)) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip the directories the go command ignores and the excluded ones
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := d.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			for _, pattern := range exclude {
+				if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") || (suffix != "" && strings.HasSuffix(path, suffix+".go")) {
+			return nil
+		}
+
+		fn(path)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk %s: %w", dir, err)
+	}
+	return nil
+}
+
+// migrateFile migrates the file at path and returns the path the migrated code was written to, if any
+func migrateFile(path, suffix string, opts migrator.Options, backup bool) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	opts.Filename = path
+
+	if suffix == "" {
+		err := transformInPlace(path, content, opts, backup)
+		var warningsErr *migrator.WarningsError
+		if err != nil && !errors.As(err, &warningsErr) {
+			return "", err
+		}
+		return path, err
+	}
+
+	// Warnings don't invalidate the output, they are reported once it is written
+	var buf bytes.Buffer
+	transformErr := migrator.TransformTo(&buf, content, opts)
+	var warningsErr *migrator.WarningsError
+	if transformErr != nil && !errors.As(transformErr, &warningsErr) {
+		return "", transformErr
+	}
+
+	output := strings.TrimSuffix(path, ".go") + suffix + ".go"
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return output, transformErr
+}
+
+// excludeFlag collects the glob patterns of repeated -exclude-dir flags
+type excludeFlag []string
+
+func (f *excludeFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *excludeFlag) Set(value string) error {
+	// Match only fails on malformed patterns
+	if _, err := filepath.Match(value, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", value, err)
+	}
+	*f = append(*f, strings.TrimSuffix(filepath.ToSlash(value), "/"))
+	return nil
+}
@@ -1,726 +1,785 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/parser"
-	"go/printer"
 	"go/token"
-	"go/types"
+	"io"
+	"io/fs"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
-	"golang.org/x/tools/go/packages"
+	"github.com/creydr/knative-lambda-func-migrator-poc/pkg/migrator"
+	"golang.org/x/mod/module"
 )
 
+// usage returns a usage message printing the defaults of the command-line flags except the hidden ones
+func usage(hidden ...string) func() {
+	return func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+		flag.VisitAll(func(f *flag.Flag) {
+			for _, name := range hidden {
+				if f.Name == name {
+					return
+				}
+			}
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		})
+		visible.PrintDefaults()
+	}
+}
+
 func main() {
 	// Parse command-line arguments
-	inputFile := flag.String("input", "", "Path to the Go file containing AWS Lambda handler")
+	inputFile := flag.String("input", "", "Path to the Go file containing AWS Lambda handler, or to the directory of the main package with -all")
+	all := flag.Bool("all", false, "Migrate every Lambda handler registered in the Go files of the -input directory, e.g. main files selected by build tags, into one function declaring a handler type per handler named after it (e.g. OrdersHandler created by NewOrdersHandler for orders)")
+	dir := flag.String("dir", "", "Directory to migrate every Go file registering a Lambda handler in, instead of a single -input file")
+	outputSuffix := flag.String("output-suffix", "", "With -dir, write each migrated file next to its original with this suffix before the .go extension (e.g. .knative) instead of overwriting it")
+	var excludeDirs excludeFlag
+	flag.Var(&excludeDirs, "exclude-dir", "With -dir, skip directories whose path relative to -dir matches this glob pattern, e.g. examples or */generated (repeatable)")
 	outputFile := flag.String("output", "", "Path to write the modified Go file (optional, defaults to stdout)")
+	outputFormat := flag.String("output-format", "file", "What -output receives: file (the transformed file) or patch (a git patch turning the input file into the transformed one, applicable with git apply from the repository root, leaving the input unchanged)")
+	noBackup := flag.Bool("no-backup", false, "Don't keep a .bak copy of the input file when -output overwrites it")
+	handler := flag.String("handler", "", "Name of the handler to migrate if lambda.Start is called several times, e.g. conditionally (defaults to the first)")
+	handlerPackage := flag.String("handler-package", "", "Import path of the package declaring the handler, e.g. example.com/app/handler, to tell it apart from functions of the same name in other packages (type checks the handler)")
+	handlerRegex := flag.String("handler-regex", "", "Only migrate or -list handlers whose name matches this regular expression, e.g. '.*Handler$', skipping files without a matching handler in -dir mode")
+	signatureMap := flag.String("signature-map", "", "Comma-separated role of each handler parameter for handlers whose parameters aren't in the order of the Lambda docs, e.g. input,ctx; roles are ctx (request context), input (decoded request body), body (raw request body as []byte), writer (response writer), cancel (function cancelling the request context as context.CancelFunc), and logger (default *log.Logger or *slog.Logger)")
+	list := flag.Bool("list", false, "List the detected Lambda handlers and their signatures without transforming anything")
+	rewriteDeadline := flag.Bool("rewrite-deadline", false, "Rewrite ctx.Deadline() calls in the handler to a helper falling back to -deadline-default")
+	deadlineDefault := flag.Duration("deadline-default", 5*time.Minute, "Deadline assumed by -rewrite-deadline when the request context has none")
+	awsRegionEnv := flag.String("aws-region-env", "", "Pass the region read from the given environment variable to config.LoadDefaultConfig calls not setting one")
+	noAWSConfigAdvisory := flag.Bool("no-aws-config-advisory", false, "Don't warn about config.LoadDefaultConfig calls relying on the credentials and region Lambda provides")
+	stripXRay := flag.Bool("strip-xray", false, "Remove the AWS X-Ray SDK's instrumentation calls (xray.Configure, xray.AWS, awsv2.AWSV2Instrumentor, ...) and unwrap its xray.Capture, xray.CaptureAsync, and xray.Client wrappers, removing its imports no longer used")
+	renameConflictingImports := flag.Bool("rename-conflicting-imports", false, "Import the packages the generated code needs under another name (e.g. stdjson) if the input file uses theirs for another package or a declaration, instead of failing")
+	poolBuffers := flag.Bool("pool-buffers", false, "Read request bodies into buffers from a sync.Pool instead of allocating per request (the handler must not retain its input)")
+	normalize := flag.Bool("normalize", false, "Rename the handler declared in the input file and its references to "+migrator.NormalizedHandlerName)
+	dryValidate := flag.Bool("dry-validate", false, "Print the planned import and declaration changes without emitting the transformed file")
+	recoverPanics := flag.Bool("recover", false, "Recover from handler panics, logging them and responding with 500")
+	noStack := flag.Bool("no-stack", false, "With -recover, don't log the stack trace of recovered panics, e.g. for privacy-sensitive deployments")
+	panicStatus := flag.Bool("panic-status", false, "With -recover, respond with the status reported by a StatusCode() int method of the recovered value")
+	readyz := flag.Bool("readyz", false, "With -style=func-instance, answer /readyz with 503 until Start ran the initialization of main successfully and 200 after, and report the same from Ready")
+	lambdaImportPath := flag.String("lambda-import-path", "github.com/aws/aws-lambda-go", "Module path of the AWS Lambda SDK whose lambda.Start calls are migrated and whose lambda import is removed, e.g. of a fork or vendored copy (its other packages, e.g. events, are kept while referenced)")
+	style := flag.String("style", migrator.StyleHandler, "Shape of the generated code: handler (Handler type with a Handle method), func-instance (Function type implementing func's Start, Stop, Handle, Ready, and Alive hooks, with the initialization of main moved to Start), or cloudevents (Handler type whose Handle method takes and returns CloudEvents, passing the event data to the handler)")
+	inputSource := flag.String("input-source", migrator.InputSourceBody, "Where the handler input is read from: body (raw request body), auto (negotiate JSON or form data on Content-Type), or multipart (file uploaded in the -file-field form field)")
+	inputType := flag.String("input-type", "", "Concrete type implementing the interface the handler takes as input, which the request body is decoded into, e.g. *Circle (required for interface inputs other than any)")
+	fileField := flag.String("file-field", "", "Name of the multipart form field holding the uploaded file passed to the handler with -input-source=multipart")
+	protoJSON := flag.Bool("protojson", false, "Experimental: decode and encode protobuf message inputs and outputs with protojson (requires type checking the handler)")
+	noTmpAdvisory := flag.Bool("no-tmp-advisory", false, "Don't warn about handlers writing to /tmp")
+	noReflectAdvisory := flag.Bool("no-reflect-advisory", false, "Don't warn about handlers passing their input to functions of package reflect")
+	noGlobalsAdvisory := flag.Bool("no-globals-advisory", false, "Don't warn about package-level variables the handler writes without synchronization")
+	failOnWarning := flag.Bool("fail-on-warning", false, "Exit with a non-zero status if any warnings were reported, even if the transformation succeeded")
+	emitAll := flag.String("emit-all", "", "Directory to write the transformed file, an invoke.sh example, and MIGRATION.md notes to, along with .ko.yaml and service.yaml at the module root, listing the written files (respects -force)")
+	emitOpenAPI := flag.String("emit-openapi", "", "Path to write an OpenAPI 3 document describing the migrated endpoint to (optional)")
+	emitInvoke := flag.String("emit-invoke", "", "Path to write a shell snippet sending a sample request derived from the handler input to the deployed function with func invoke to, or - for stdout with -output (optional)")
+	emitBench := flag.Bool("emit-bench", false, "Write a handle_bench_test.go benchmarking the migrated Handle method with a sample request derived from the handler input next to the output")
+	validateOutput := flag.Bool("validate-output", false, "Write a validate_output.go next to the output that, in builds with the validateoutput tag, logs where the JSON encoded handler output doesn't match the schema of its type")
+	emitNotes := flag.Bool("emit-notes", false, "Write a MIGRATION.md summarizing the changes, environment variables, and warnings of the migration next to the output")
+	emitReadme := flag.Bool("emit-readme", false, "Write a README.md describing the detected handler signature and how to build, deploy, and invoke the migrated function with func next to the output")
+	manifest := flag.Bool("manifest", false, "Write a func.yaml naming the function after the handler, with the go runtime and the http invocation (cloudevent with -style cloudevents), next to the output for deploying it with func")
+	emitConfig := flag.Bool("emit-config", false, "Generate a Config struct with a field per environment variable read with os.Getenv or os.LookupEnv, populated in New()")
+	emitKo := flag.Bool("emit-ko", false, "Write a .ko.yaml building the migrated main package to the module root")
+	emitSkaffold := flag.Bool("emit-skaffold", false, "Write a skaffold.yaml building the migrated main package with ko and deploying service.yaml to the module root")
+	emitService := flag.Bool("emit-service", false, "Write a service.yaml defining a Knative Service running the migrated main package on port 8080 to the module root, with resources sized like the Lambda function commented out")
+	emitGoMod := flag.String("emit-gomod", "", "Module path of a go.mod written next to -output, making the migrated function a module of its own that requires the modules of its imports at the versions of the input's module, e.g. example.com/function")
+	lambdaMemory := flag.Int("lambda-memory", 0, "With -emit-service, memory in MB configured for the Lambda function (128-10240), which the commented resources are derived from (defaults to Lambda's 128)")
+	force := flag.Bool("force", false, "Overwrite existing files written by the -emit-* flags")
+	jsonNumbers := flag.Bool("json-numbers", false, "Decode numbers in untyped handler inputs (e.g., map[string]interface{}) as json.Number instead of float64, keeping the precision of large integers")
+	strictJSON := flag.Bool("strict-json", false, "Answer request bodies with fields the handler input struct doesn't declare with 400, like API Gateway request validation rejecting extra fields")
+	prettyOutput := flag.Bool("pretty-output", false, "Indent the JSON encoded handler output, e.g. for debugging or admin endpoints")
+	statusFor := statusFlag{}
+	flag.Var(statusFor, "status-for", "Success status for an output type as pkg.Type=status, e.g. api.Created=201 (repeatable)")
+	var methods methodFlag
+	flag.Var(&methods, "method", "Request method accepted by the function, e.g. POST, answering others with 405 and an Allow header (repeatable, defaults to all)")
+	wrapContextTimeout := flag.Duration("wrap-context-timeout", 0, "Cancel the handler context and respond with 504 if the handler runs longer than this, e.g. 30s, like the Lambda function timeout (disabled by default)")
+	responseHeaders := headerFlag{}
+	flag.Var(responseHeaders, "response-header", "Static header set on every response as Name=value, e.g. Cache-Control=no-store, replacing headers added by API Gateway integration responses (repeatable)")
+	var legacyStartFuncs legacyStartFuncFlag
+	flag.Var(&legacyStartFuncs, "legacy-start-func", "Function of the lambda package besides Start that registers the handler given as its first argument, e.g. Handle in older or forked SDKs (repeatable, defaults to Handle and HandleFunction)")
+	var ceAttrs ceAttrFlag
+	flag.Var(&ceAttrs, "ce-attr", "Attribute of CloudEvents received in binary content mode (ce- headers) to store in the handler context, e.g. source, read with CloudEventAttribute(ctx, \"source\") (repeatable)")
+	responseSource := flag.String("response-source", "", "Source of the response events with -style cloudevents (defaults to the function name derived from the handler, as in its func.yaml)")
+	envelope := flag.Bool("envelope", false, "Treat the handler's single result as an envelope: respond with 500 if its Err or Error field is set, and encode its Data field (or only other exported field) otherwise")
+	maxConcurrency := flag.Int("max-concurrency", 0, "Answer requests with 429 Too Many Requests while this many are served by the function instance, like the reserved concurrency of a Lambda function (no limit if 0)")
+	metrics := flag.Bool("metrics", false, "Count handler invocations and failed ones by outcome (success, error, timeout, or panic) with the OpenTelemetry counters function.requests and function.errors")
+	injectLogger := flag.Bool("inject-logger", false, "Store a slog.Logger with the request method and path as attributes in the handler context, read with LoggerFromContext(ctx), and pass it to *slog.Logger handler parameters")
+	var routes routeFlag
+	flag.Var(&routes, "route", "Serve the handler registered in a file on a path as PATH=FILE[#HANDLER], e.g. /orders=cmd/orders/main.go, instead of a single -input file (repeatable)")
+	basePath := flag.String("base-path", "", "Path prefix the -route paths are served under, e.g. /api/v1 for an ingress or API Gateway stage mapped to it (requests outside it are answered with 404)")
+	formatOutput := flag.Bool("format", true, "Format the migrated code like gofmt, sorting its imports; code that can't be formatted is written as printed with a warning")
+	keepPositions := flag.Bool("keep-positions", false, "Emit //line directives attributing the code kept from the input file to its original lines, for coverage and debugging tools (existing //line directives are kept)")
+	// -dump-ast helps debugging the migrator itself and is left out of the usage message
+	dumpAST := flag.Bool("dump-ast", false, "Print the AST before and after the transformation to stderr, for debugging the migrator")
+	flag.Usage = usage("dump-ast")
 	flag.Parse()
 
-	if *inputFile == "" {
+	if *inputFile == "" && *dir == "" && len(routes) == 0 {
 		log.Fatal("Please provide an input file using -input flag")
 	}
-
-	// Read the input file
-	content, err := os.ReadFile(*inputFile)
-	if err != nil {
-		log.Fatalf("Failed to read input file: %v", err)
+	if len(routes) > 0 && (*emitAll != "" || *inputFile != "" || *dir != "" || *list || *dryValidate || *emitOpenAPI != "" || *emitInvoke != "" || *emitBench || *validateOutput || *emitKo || *emitSkaffold || *emitService || *emitGoMod != "" || *emitNotes || *emitReadme || *manifest) {
+		log.Fatal("-route can't be combined with -emit-all, -input, -dir, -list, -dry-validate, -emit-openapi, -emit-invoke, -emit-bench, -validate-output, -emit-ko, -emit-skaffold, -emit-service, -emit-gomod, -emit-notes, -emit-readme, or -manifest")
+	}
+	if *all && (*inputFile == "" || *handler != "" || *emitAll != "" || *dir != "" || len(routes) > 0 || *outputFormat == "patch" || *list || *dryValidate || *emitOpenAPI != "" || *emitInvoke != "" || *emitBench || *validateOutput || *emitKo || *emitSkaffold || *emitService || *emitGoMod != "" || *emitNotes || *emitReadme || *manifest) {
+		log.Fatal("-all requires -input and can't be combined with -handler, -emit-all, -dir, -route, -output-format=patch, -list, -dry-validate, -emit-openapi, -emit-invoke, -emit-bench, -validate-output, -emit-ko, -emit-skaffold, -emit-service, -emit-gomod, -emit-notes, -emit-readme, or -manifest")
+	}
+	if *dir != "" && (*emitAll != "" || *inputFile != "" || *outputFile != "" || *dryValidate || *emitOpenAPI != "" || *emitInvoke != "" || *emitBench || *validateOutput || *emitKo || *emitSkaffold || *emitService || *emitGoMod != "" || *emitNotes || *emitReadme || *manifest) {
+		log.Fatal("-dir can't be combined with -emit-all, -input, -output, -dry-validate, -emit-openapi, -emit-invoke, -emit-bench, -validate-output, -emit-ko, -emit-skaffold, -emit-service, -emit-gomod, -emit-notes, -emit-readme, or -manifest")
+	}
+	var handlerPattern *regexp.Regexp
+	if *handlerRegex != "" {
+		var err error
+		if handlerPattern, err = regexp.Compile(*handlerRegex); err != nil {
+			log.Fatalf("Invalid -handler-regex: %v", err)
+		}
+	}
+	if *outputFormat != "file" && *outputFormat != "patch" {
+		log.Fatalf("Invalid -output-format %q, must be file or patch", *outputFormat)
+	}
+	if *outputFormat == "patch" && (*dir != "" || len(routes) > 0) {
+		log.Fatal("-output-format=patch can't be combined with -dir or -route")
+	}
+	if *basePath != "" && len(routes) == 0 {
+		log.Fatal("-base-path requires -route")
+	}
+	if *outputFormat == "patch" && *outputFile != "" && sameFile(*inputFile, *outputFile) {
+		log.Fatal("-output-format=patch can't write the patch to the input file")
+	}
+	if *handlerPackage != "" && *inputFile == "" {
+		log.Fatal("-handler-package requires -input")
+	}
+	if *handlerPackage != "" {
+		if err := module.CheckImportPath(*handlerPackage); err != nil {
+			log.Fatalf("Invalid -handler-package: %v", err)
+		}
+	}
+	if *outputSuffix != "" && *dir == "" {
+		log.Fatal("-output-suffix requires -dir")
+	}
+	if len(excludeDirs) > 0 && *dir == "" {
+		log.Fatal("-exclude-dir requires -dir")
 	}
 
-	// Parse the Go source code
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, *inputFile, content, parser.ParseComments)
-	if err != nil {
-		log.Fatalf("Failed to parse Go file: %v", err)
+	// -emit-all composes the individual emit flags, writing the transformed file into the bundle directory
+	if *emitAll != "" {
+		if *outputFile != "" || *outputFormat == "patch" || *emitInvoke != "" || *list || *dryValidate {
+			log.Fatal("-emit-all can't be combined with -output, -output-format=patch, -emit-invoke, -list, or -dry-validate")
+		}
+		if err := os.MkdirAll(*emitAll, 0o755); err != nil {
+			log.Fatalf("Failed to create bundle directory: %v", err)
+		}
+		*outputFile = filepath.Join(*emitAll, filepath.Base(*inputFile))
+		*emitInvoke = filepath.Join(*emitAll, "invoke.sh")
+		*emitNotes, *emitKo, *emitService = true, true, true
+		if !*force {
+			if err := checkBundle(*emitAll, *outputFile, *emitInvoke, *emitGoMod != ""); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if *emitInvoke == "-" && *outputFile == "" {
+		log.Fatal("-emit-invoke - requires -output, as the transformed file is written to stdout otherwise")
+	}
+	if *emitGoMod != "" && (*outputFile == "" || *outputFormat == "patch") {
+		log.Fatal("-emit-gomod requires -output writing the transformed file to the directory of the new module")
+	}
+	if *emitGoMod != "" {
+		if err := module.CheckPath(*emitGoMod); err != nil {
+			log.Fatalf("Invalid -emit-gomod: %v", err)
+		}
+	}
+	if *lambdaMemory != 0 && !*emitService {
+		log.Fatal("-lambda-memory requires -emit-service")
+	}
+	if *lambdaMemory != 0 && (*lambdaMemory < 128 || *lambdaMemory > 10240) {
+		log.Fatalf("Invalid -lambda-memory %d, Lambda functions have 128 to 10240 MB", *lambdaMemory)
 	}
 
-	// Find the lambda.Start call and extract handler reference
-	handlerRef, err := findLambdaHandler(file)
-	if err != nil {
-		log.Fatalf("Failed to find lambda handler: %v", err)
+	var roles []string
+	if *signatureMap != "" {
+		roles = strings.Split(*signatureMap, ",")
+		for i := range roles {
+			roles[i] = strings.TrimSpace(roles[i])
+		}
 	}
 
-	fmt.Fprintf(os.Stderr, "Found Lambda handler: %s\n", handlerRef.QualifiedName)
+	opts := migrator.Options{
+		GenerateOptions: migrator.GenerateOptions{
+			Style:           *style,
+			LambdaModule:    strings.TrimSuffix(*lambdaImportPath, "/"),
+			InputSource:     *inputSource,
+			FileField:       *fileField,
+			InputType:       *inputType,
+			PoolBuffers:     *poolBuffers,
+			Recover:         *recoverPanics,
+			PanicStatus:     *panicStatus,
+			NoStack:         *noStack,
+			ProtoJSON:       *protoJSON,
+			PrettyOutput:    *prettyOutput,
+			JSONNumbers:     *jsonNumbers,
+			StrictJSON:      *strictJSON,
+			Readyz:          *readyz,
+			Config:          *emitConfig,
+			Methods:         methods,
+			CloudEventAttrs: ceAttrs,
+			ResponseSource:  *responseSource,
+			InjectLogger:    *injectLogger,
+			Envelope:        *envelope,
+			Metrics:         *metrics,
+			MaxConcurrency:  *maxConcurrency,
+			StatusFor:       statusFor,
+			ResponseHeaders: http.Header(responseHeaders),
+			Timeout:         *wrapContextTimeout,
+			ValidateOutput:  *validateOutput,
+		},
+		Filename:                 *inputFile,
+		Handler:                  *handler,
+		HandlerPackage:           *handlerPackage,
+		HandlerPattern:           handlerPattern,
+		LegacyStartFuncs:         legacyStartFuncs,
+		SignatureMap:             roles,
+		Normalize:                *normalize,
+		RewriteDeadline:          *rewriteDeadline,
+		DeadlineDefault:          *deadlineDefault,
+		AWSRegionEnv:             *awsRegionEnv,
+		NoAWSConfigAdvisory:      *noAWSConfigAdvisory,
+		StripXRay:                *stripXRay,
+		RenameConflictingImports: *renameConflictingImports,
+		BasePath:                 *basePath,
+		KeepPositions:            *keepPositions,
+		Format:                   *formatOutput,
+		NoTmpAdvisory:            *noTmpAdvisory,
+		NoGlobalsAdvisory:        *noGlobalsAdvisory,
+		NoReflectAdvisory:        *noReflectAdvisory,
+		FailOnWarning:            *failOnWarning,
+		Log:                      os.Stderr,
+	}
+	if *dumpAST {
+		opts.DumpAST = os.Stderr
+	}
+	if err := opts.Validate(); err != nil {
+		log.Fatalf("Invalid options: %v", err)
+	}
+
+	if *dir != "" && *list {
+		if err := listDir(os.Stdout, *dir, excludeDirs, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *dir != "" {
+		if err := migrateDir(os.Stdout, *dir, *outputSuffix, excludeDirs, opts, !*noBackup); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// Analyze the handler function signature
-	// First try AST-based analysis (works for handlers in the same file)
-	handlerSig, err := analyzeHandlerSignature(file, handlerRef.SimpleName)
-	if err != nil {
-		// If not found in AST, try type-based analysis (works for imported handlers)
-		fmt.Fprintf(os.Stderr, "Handler not found in file, trying type checker...\n")
-		handlerSig, err = analyzeHandlerSignatureWithTypes(*inputFile, file, handlerRef.SimpleName, fset)
+	if *all {
+		err := writeOutput(*outputFile, func(w io.Writer) error {
+			return migrateAll(w, *inputFile, opts)
+		})
 		if err != nil {
-			log.Fatalf("Failed to analyze handler signature: %v", err)
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Successfully transformed the Lambda handlers of %s to a Knative function\n", *inputFile)
+		return
 	}
 
-	// Transform the AST
-	transformAST(file, handlerRef.QualifiedName, handlerSig)
-
-	// Write the output
-	var output *os.File
-	if *outputFile != "" {
-		output, err = os.Create(*outputFile)
+	if len(routes) > 0 {
+		err := writeOutput(*outputFile, func(w io.Writer) error {
+			return migrateRoutes(w, routes, opts)
+		})
 		if err != nil {
-			log.Fatalf("Failed to create output file: %v", err)
+			log.Fatal(err)
 		}
-		defer output.Close()
-	} else {
-		output = os.Stdout
+		fmt.Fprintf(os.Stderr, "Successfully transformed %d Lambda handlers to a Knative function\n", len(routes))
+		return
 	}
 
-	// Print the modified AST
-	if err := printer.Fprint(output, fset, file); err != nil {
-		log.Fatalf("Failed to print modified code: %v", err)
+	// Read the input file
+	content, err := os.ReadFile(*inputFile)
+	if err != nil {
+		log.Fatalf("Failed to read input file: %v", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Successfully transformed Lambda handler to Knative function\n")
-}
-
-// HandlerSignature describes the Lambda handler function signature
-type HandlerSignature struct {
-	HasContext bool
-	HasInput   bool
-	HasOutput  bool
-	HasError   bool
-}
+	if *list {
+		if err := migrator.ListHandlers(os.Stdout, content, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-// analyzeHandlerSignature analyzes the handler function signature
-func analyzeHandlerSignature(file *ast.File, handlerName string) (*HandlerSignature, error) {
-	var sig *HandlerSignature
-
-	ast.Inspect(file, func(n ast.Node) bool {
-		if fn, ok := n.(*ast.FuncDecl); ok && fn.Name.Name == handlerName {
-			sig = &HandlerSignature{}
-
-			// Analyze parameters
-			if fn.Type.Params != nil && len(fn.Type.Params.List) > 0 {
-				numParams := len(fn.Type.Params.List)
-				if numParams >= 1 {
-					// Check if first param is context.Context
-					firstParam := fn.Type.Params.List[0]
-					if selExpr, ok := firstParam.Type.(*ast.SelectorExpr); ok {
-						if ident, ok := selExpr.X.(*ast.Ident); ok {
-							if ident.Name == "context" && selExpr.Sel.Name == "Context" {
-								sig.HasContext = true
-								if numParams == 2 {
-									sig.HasInput = true
-								}
-							}
-						}
-					} else if numParams == 1 {
-						// Single param that's not context
-						sig.HasInput = true
-					}
-				}
-			}
+	if *dryValidate {
+		if err := migrator.PrintPlan(os.Stdout, content, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-			// Analyze return values
-			if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
-				numResults := len(fn.Type.Results.List)
-				if numResults == 1 {
-					// Check if it's an error
-					if ident, ok := fn.Type.Results.List[0].Type.(*ast.Ident); ok {
-						if ident.Name == "error" {
-							sig.HasError = true
-						}
-					}
-				} else if numResults == 2 {
-					// (TOut, error)
-					sig.HasOutput = true
-					sig.HasError = true
-				}
-			}
+	if *emitOpenAPI != "" {
+		openAPIFile, err := os.Create(*emitOpenAPI)
+		if err != nil {
+			log.Fatalf("Failed to create OpenAPI file: %v", err)
+		}
+		defer openAPIFile.Close()
+		opts.OpenAPI = openAPIFile
+	}
 
-			return false
+	switch *emitInvoke {
+	case "":
+	case "-":
+		opts.Invoke = os.Stdout
+	default:
+		invokeFile, err := os.Create(*emitInvoke)
+		if err != nil {
+			log.Fatalf("Failed to create invoke example file: %v", err)
 		}
-		return true
-	})
+		defer invokeFile.Close()
+		opts.Invoke = invokeFile
+	}
 
-	if sig == nil {
-		return nil, fmt.Errorf("handler function %s not found", handlerName)
+	var bench bytes.Buffer
+	if *emitBench {
+		opts.Bench = &bench
 	}
 
-	return sig, nil
-}
+	var validator bytes.Buffer
+	if *validateOutput {
+		opts.OutputValidator = &validator
+	}
 
-// HandlerReference holds information about the lambda handler reference
-type HandlerReference struct {
-	SimpleName    string // Just the function name (e.g., "HandleRequest")
-	QualifiedName string // Full name including package if present (e.g., "handler.HandleRequest")
-}
+	var notes bytes.Buffer
+	if *emitNotes {
+		opts.Notes = &notes
+	}
 
-// findLambdaHandler searches for lambda.Start() call and returns the handler reference
-func findLambdaHandler(file *ast.File) (*HandlerReference, error) {
-	var handlerRef *HandlerReference
-	var foundMain bool
-
-	ast.Inspect(file, func(n ast.Node) bool {
-		// Look for the main function
-		if fn, ok := n.(*ast.FuncDecl); ok && fn.Name.Name == "main" {
-			foundMain = true
-			// Look for lambda.Start() call within main
-			ast.Inspect(fn.Body, func(n ast.Node) bool {
-				if callExpr, ok := n.(*ast.CallExpr); ok {
-					if selExpr, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
-						// Check if it's a call to lambda.Start
-						if ident, ok := selExpr.X.(*ast.Ident); ok {
-							if ident.Name == "lambda" && selExpr.Sel.Name == "Start" {
-								// Extract the handler function name
-								if len(callExpr.Args) > 0 {
-									// Check if it's a simple identifier (e.g., handleRequest)
-									if handlerIdent, ok := callExpr.Args[0].(*ast.Ident); ok {
-										handlerRef = &HandlerReference{
-											SimpleName:    handlerIdent.Name,
-											QualifiedName: handlerIdent.Name,
-										}
-										return false
-									}
-									// Check if it's a selector (e.g., handler.HandleRequest)
-									if handlerSel, ok := callExpr.Args[0].(*ast.SelectorExpr); ok {
-										if pkgIdent, ok := handlerSel.X.(*ast.Ident); ok {
-											handlerRef = &HandlerReference{
-												SimpleName:    handlerSel.Sel.Name,
-												QualifiedName: pkgIdent.Name + "." + handlerSel.Sel.Name,
-											}
-											return false
-										}
-									}
-								}
-							}
-						}
-					}
-				}
-				return true
-			})
-		}
-		return true
-	})
+	var readme bytes.Buffer
+	if *emitReadme {
+		opts.Readme = &readme
+	}
 
-	if !foundMain {
-		return nil, fmt.Errorf("main function not found")
+	var funcManifest migrator.FuncManifest
+	if *manifest {
+		opts.Manifest = &funcManifest
 	}
 
-	if handlerRef == nil {
-		return nil, fmt.Errorf("lambda.Start() call not found in main function")
+	// Migrating in place only replaces the input once the output is known to be valid
+	if *outputFormat == "patch" {
+		err = writePatch(*inputFile, *outputFile, content, opts)
+	} else if *outputFile != "" && sameFile(*inputFile, *outputFile) {
+		err = transformInPlace(*inputFile, content, opts, !*noBackup)
+	} else {
+		err = writeOutput(*outputFile, func(w io.Writer) error {
+			return migrator.TransformTo(w, content, opts)
+		})
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	var written []string
+	if *outputFile != "" {
+		written = append(written, *outputFile)
+	}
+	if *emitOpenAPI != "" {
+		written = append(written, *emitOpenAPI)
+	}
+	if *emitInvoke != "" && *emitInvoke != "-" {
+		written = append(written, *emitInvoke)
 	}
 
-	return handlerRef, nil
-}
+	// The migrated main package is the one the output is written to, or the input once a patch is applied
+	mainDir := filepath.Dir(*inputFile)
+	if *outputFile != "" && *outputFormat != "patch" {
+		mainDir = filepath.Dir(*outputFile)
+	}
 
-// transformAST modifies the AST to replace main() with Knative handler structure
-func transformAST(file *ast.File, handlerFuncName string, handlerSig *HandlerSignature) {
-	// Remove lambda import if present
-	removeLambdaImport(file)
-
-	// Add context, net/http, and io imports if not present and get their aliases
-	contextAlias, httpAlias, ioAlias := addRequiredImports(file, handlerSig)
-
-	// Find and transform the main function
-	for i, decl := range file.Decls {
-		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" {
-			// Create Handler struct, New function, and Handle method
-			handlerStruct := createHandlerStruct()
-			newFunc := createNewFunc()
-			handleMethod := createHandleMethod(handlerFuncName, contextAlias, httpAlias, ioAlias, handlerSig)
-
-			// Replace main with the new declarations
-			newDecls := make([]ast.Decl, 0, len(file.Decls)+2)
-			newDecls = append(newDecls, file.Decls[:i]...)
-			newDecls = append(newDecls, handlerStruct)
-			newDecls = append(newDecls, newFunc)
-			newDecls = append(newDecls, handleMethod)
-			newDecls = append(newDecls, file.Decls[i+1:]...)
-			file.Decls = newDecls
-			break
+	if *emitNotes {
+		path := filepath.Join(mainDir, "MIGRATION.md")
+		if err := writeNewFile(path, notes.Bytes(), *force); err != nil {
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Wrote migration notes to %s\n", path)
+		written = append(written, path)
 	}
-}
 
-// removeLambdaImport removes the AWS Lambda SDK import
-func removeLambdaImport(file *ast.File) {
-	for i, decl := range file.Decls {
-		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
-			// Filter out lambda imports
-			var newSpecs []ast.Spec
-			for _, spec := range genDecl.Specs {
-				if importSpec, ok := spec.(*ast.ImportSpec); ok {
-					importPath := strings.Trim(importSpec.Path.Value, `"`)
-					// Remove aws-lambda-go imports
-					if !strings.Contains(importPath, "aws-lambda-go") {
-						newSpecs = append(newSpecs, spec)
-					}
-				}
-			}
-			if len(newSpecs) == 0 {
-				// Remove the entire import declaration if empty
-				file.Decls = append(file.Decls[:i], file.Decls[i+1:]...)
-			} else {
-				genDecl.Specs = newSpecs
-			}
+	if *emitReadme {
+		path := filepath.Join(mainDir, "README.md")
+		if err := writeNewFile(path, readme.Bytes(), *force); err != nil {
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Wrote function README to %s\n", path)
+		written = append(written, path)
 	}
-}
 
-// importInfo holds information about a required import
-type importInfo struct {
-	path      string
-	alias     string
-	hasImport bool
-	needed    bool
-}
+	if *manifest {
+		path := filepath.Join(mainDir, "func.yaml")
+		if err := writeManifest(path, funcManifest.Name, funcManifest.Invocation, *force); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote func manifest to %s\n", path)
+		written = append(written, path)
+	}
 
-// checkImport checks if an import exists and captures its alias
-func checkImport(importSpec *ast.ImportSpec, info *importInfo) {
-	importPath := strings.Trim(importSpec.Path.Value, `"`)
-	if importPath == info.path {
-		info.hasImport = true
-		if importSpec.Name != nil {
-			info.alias = importSpec.Name.Name
+	if *emitBench {
+		path := filepath.Join(mainDir, "handle_bench_test.go")
+		if err := writeNewFile(path, bench.Bytes(), *force); err != nil {
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Wrote benchmark to %s\n", path)
+		written = append(written, path)
 	}
-}
 
-// createImportSpec creates an import spec from the import info
-func createImportSpec(path string) *ast.ImportSpec {
-	return &ast.ImportSpec{
-		Path: &ast.BasicLit{Kind: token.STRING, Value: `"` + path + `"`},
+	if *validateOutput {
+		path := filepath.Join(mainDir, "validate_output.go")
+		if err := writeNewFile(path, validator.Bytes(), *force); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote output validator to %s, build with -tags %s to enable it\n", path, migrator.ValidateOutputTag)
+		written = append(written, path)
 	}
-}
 
-// addRequiredImports adds required imports based on handler signature
-// Returns the package names/aliases to use for context, http, and io
-func addRequiredImports(file *ast.File, handlerSig *HandlerSignature) (contextAlias, httpAlias, ioAlias string) {
-	// Define required imports
-	imports := map[string]*importInfo{
-		"context":       {path: "context", alias: "context", needed: true},
-		"net/http":      {path: "net/http", alias: "http", needed: true},
-		"io":            {path: "io", alias: "io", needed: handlerSig.HasInput},
-		"encoding/json": {path: "encoding/json", alias: "json", needed: handlerSig.HasOutput},
-		"log":           {path: "log", alias: "log", needed: handlerSig.HasError},
-	}
-
-	// Check existing imports and capture aliases
-	for _, decl := range file.Decls {
-		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
-			for _, spec := range genDecl.Specs {
-				if importSpec, ok := spec.(*ast.ImportSpec); ok {
-					for _, info := range imports {
-						checkImport(importSpec, info)
-					}
-				}
-			}
+	// The go.mod is written first, so the module root of the other files is the new module's
+	if *emitGoMod != "" {
+		migrated, err := os.ReadFile(*outputFile)
+		if err != nil {
+			log.Fatalf("Failed to read output file: %v", err)
+		}
+		gomod, unresolved, err := migrator.GoMod(filepath.Dir(*inputFile), *emitGoMod, migrated)
+		if err != nil {
+			log.Fatalf("Failed to generate go.mod: %v", err)
+		}
+		path := filepath.Join(mainDir, "go.mod")
+		if err := writeNewFile(path, gomod, *force); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote go.mod to %s, run go mod tidy in %s to complete it\n", path, mainDir)
+		written = append(written, path)
+		for _, importPath := range unresolved {
+			fmt.Fprintf(os.Stderr, "Warning: no module required by the input's go.mod provides %s, copy the package into the new module or require its module\n", importPath)
 		}
 	}
 
-	// Collect missing imports that are needed
-	var missingImports []string
-	for _, info := range imports {
-		if info.needed && !info.hasImport {
-			missingImports = append(missingImports, info.path)
+	if *emitKo {
+		path, config, err := migrator.KoConfig(mainDir)
+		if err != nil {
+			log.Fatalf("Failed to generate ko config: %v", err)
+		}
+		if err := writeNewFile(path, config, *force); err != nil {
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Wrote ko config to %s\n", path)
+		written = append(written, path)
 	}
 
-	// Add missing imports
-	if len(missingImports) > 0 {
-		// Try to add to existing import declaration
-		for i, decl := range file.Decls {
-			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
-				for _, path := range missingImports {
-					genDecl.Specs = append(genDecl.Specs, createImportSpec(path))
-				}
-				file.Decls[i] = genDecl
-				return imports["context"].alias, imports["net/http"].alias, imports["io"].alias
-			}
+	if *emitSkaffold {
+		path, config, err := migrator.SkaffoldConfig(mainDir)
+		if err != nil {
+			log.Fatalf("Failed to generate Skaffold config: %v", err)
+		}
+		if err := writeNewFile(path, config, *force); err != nil {
+			log.Fatal(err)
 		}
+		fmt.Fprintf(os.Stderr, "Wrote Skaffold config to %s\n", path)
+		written = append(written, path)
+	}
 
-		// If no import declaration exists, create one with all needed imports
-		var specs []ast.Spec
-		for _, info := range imports {
-			if info.needed {
-				specs = append(specs, createImportSpec(info.path))
-			}
+	if *emitService {
+		path, manifest, err := migrator.ServiceManifest(mainDir, *lambdaMemory)
+		if err != nil {
+			log.Fatalf("Failed to generate Knative Service manifest: %v", err)
+		}
+		if err := writeNewFile(path, manifest, *force); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote Knative Service manifest to %s\n", path)
+		written = append(written, path)
+	}
+
+	if *emitAll != "" {
+		fmt.Fprintf(os.Stderr, "Wrote deployment bundle:\n")
+		for _, path := range written {
+			fmt.Fprintf(os.Stderr, "  %s\n", path)
 		}
-		newImport := &ast.GenDecl{Tok: token.IMPORT, Specs: specs}
-		file.Decls = append([]ast.Decl{newImport}, file.Decls...)
 	}
 
-	return imports["context"].alias, imports["net/http"].alias, imports["io"].alias
+	fmt.Fprintf(os.Stderr, "Successfully transformed Lambda handler to Knative function\n")
 }
 
-// createHandlerStruct creates the Handler struct declaration
-func createHandlerStruct() *ast.GenDecl {
-	return &ast.GenDecl{
-		Tok: token.TYPE,
-		Specs: []ast.Spec{
-			&ast.TypeSpec{
-				Name: ast.NewIdent("Handler"),
-				Type: &ast.StructType{
-					Fields: &ast.FieldList{},
-				},
-			},
-		},
+// checkBundle checks that none of the files -emit-all writes exist yet, so nothing is written if any would
+// be overwritten. The module root files are written to dir if it becomes a module of its own with -emit-gomod.
+func checkBundle(dir, outputFile, invokeFile string, newModule bool) error {
+	paths := []string{outputFile, invokeFile, filepath.Join(dir, "MIGRATION.md")}
+	if newModule {
+		paths = append(paths, filepath.Join(dir, ".ko.yaml"), filepath.Join(dir, "service.yaml"))
+	} else {
+		koPath, _, err := migrator.KoConfig(dir)
+		if err != nil {
+			return fmt.Errorf("failed to generate ko config: %w", err)
+		}
+		servicePath, _, err := migrator.ServiceManifest(dir, 0)
+		if err != nil {
+			return fmt.Errorf("failed to generate Knative Service manifest: %w", err)
+		}
+		paths = append(paths, koPath, servicePath)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+		}
 	}
+	return nil
 }
 
-// createNewFunc creates the New() function that returns *Handler
-func createNewFunc() *ast.FuncDecl {
-	return &ast.FuncDecl{
-		Name: ast.NewIdent("New"),
-		Type: &ast.FuncType{
-			Params: &ast.FieldList{},
-			Results: &ast.FieldList{
-				List: []*ast.Field{
-					{
-						Type: &ast.StarExpr{
-							X: ast.NewIdent("Handler"),
-						},
-					},
-				},
-			},
-		},
-		Body: &ast.BlockStmt{
-			List: []ast.Stmt{
-				&ast.ReturnStmt{
-					Results: []ast.Expr{
-						&ast.UnaryExpr{
-							Op: token.AND,
-							X: &ast.CompositeLit{
-								Type: ast.NewIdent("Handler"),
-							},
-						},
-					},
-				},
-			},
-		},
+// writeOutput writes the code transform writes to the output file, or stdout if there is none. The file is only
+// created once the transformation succeeded, so a failing one leaves an existing output untouched.
+func writeOutput(outputFile string, transform func(w io.Writer) error) error {
+	// Warnings don't invalidate the output, they are reported once it is written
+	var buf bytes.Buffer
+	transformErr := transform(&buf)
+	var warningsErr *migrator.WarningsError
+	if transformErr != nil && !errors.As(transformErr, &warningsErr) {
+		return transformErr
 	}
-}
 
-// createHandleMethod creates the Handle method for the Handler struct based on the handler signature
-func createHandleMethod(handlerFuncName, contextAlias, httpAlias, ioAlias string, handlerSig *HandlerSignature) *ast.FuncDecl {
-	// Build the body statements
-	var stmts []ast.Stmt
-
-	// Read request body if handler expects input
-	if handlerSig.HasInput {
-		stmts = append(stmts, &ast.AssignStmt{
-			Lhs: []ast.Expr{ast.NewIdent("body"), ast.NewIdent("_")},
-			Tok: token.DEFINE,
-			Rhs: []ast.Expr{
-				&ast.CallExpr{
-					Fun: &ast.SelectorExpr{
-						X:   ast.NewIdent(ioAlias),
-						Sel: ast.NewIdent("ReadAll"),
-					},
-					Args: []ast.Expr{
-						&ast.SelectorExpr{
-							X:   ast.NewIdent("r"),
-							Sel: ast.NewIdent("Body"),
-						},
-					},
-				},
-			},
-		})
+	if outputFile == "" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		return transformErr
+	}
+	if err := os.WriteFile(outputFile, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
 	}
+	return transformErr
+}
 
-	// Build handler call arguments
-	var handlerArgs []ast.Expr
-	if handlerSig.HasContext {
-		handlerArgs = append(handlerArgs, ast.NewIdent("ctx"))
+// writeNewFile writes data to the file at path, refusing to overwrite an existing file unless force is set
+func writeNewFile(path string, data []byte, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
 	}
-	if handlerSig.HasInput {
-		handlerArgs = append(handlerArgs, ast.NewIdent("body"))
+	f, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	} else if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
 	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
 
-	// Parse the handler function name to create the appropriate AST expression
-	// It could be either "handleRequest" or "handler.HandleRequest"
-	var handlerFuncExpr ast.Expr
-	if idx := strings.Index(handlerFuncName, "."); idx != -1 {
-		// Qualified name like "handler.HandleRequest"
-		pkgName := handlerFuncName[:idx]
-		funcName := handlerFuncName[idx+1:]
-		handlerFuncExpr = &ast.SelectorExpr{
-			X:   ast.NewIdent(pkgName),
-			Sel: ast.NewIdent(funcName),
-		}
-	} else {
-		// Simple name like "handleRequest"
-		handlerFuncExpr = ast.NewIdent(handlerFuncName)
-	}
-
-	// Call the handler and capture results
-	if handlerSig.HasOutput && handlerSig.HasError {
-		// result, err := handlerFuncName(args...)
-		stmts = append(stmts, &ast.AssignStmt{
-			Lhs: []ast.Expr{ast.NewIdent("result"), ast.NewIdent("err")},
-			Tok: token.DEFINE,
-			Rhs: []ast.Expr{
-				&ast.CallExpr{
-					Fun:  handlerFuncExpr,
-					Args: handlerArgs,
-				},
-			},
-		})
-	} else if handlerSig.HasError {
-		// err := handlerFuncName(args...)
-		stmts = append(stmts, &ast.AssignStmt{
-			Lhs: []ast.Expr{ast.NewIdent("err")},
-			Tok: token.DEFINE,
-			Rhs: []ast.Expr{
-				&ast.CallExpr{
-					Fun:  handlerFuncExpr,
-					Args: handlerArgs,
-				},
-			},
-		})
-	} else if handlerSig.HasOutput {
-		// result := handlerFuncName(args...)
-		stmts = append(stmts, &ast.AssignStmt{
-			Lhs: []ast.Expr{ast.NewIdent("result")},
-			Tok: token.DEFINE,
-			Rhs: []ast.Expr{
-				&ast.CallExpr{
-					Fun:  handlerFuncExpr,
-					Args: handlerArgs,
-				},
-			},
-		})
-	} else {
-		// handlerFuncName(args...)
-		stmts = append(stmts, &ast.ExprStmt{
-			X: &ast.CallExpr{
-				Fun:  handlerFuncExpr,
-				Args: handlerArgs,
-			},
-		})
+// sameFile reports whether both paths resolve to the same file
+func sameFile(path1, path2 string) bool {
+	info1, err1 := os.Stat(path1)
+	info2, err2 := os.Stat(path2)
+	if err1 == nil && err2 == nil {
+		return os.SameFile(info1, info2)
 	}
 
-	// Handle error if handler returns one
-	if handlerSig.HasError {
-		// if err != nil {
-		//     log.Printf("Handler error: %v", err)
-		//     w.WriteHeader(500)
-		//     return
-		// }
-		stmts = append(stmts, &ast.IfStmt{
-			Cond: &ast.BinaryExpr{
-				X:  ast.NewIdent("err"),
-				Op: token.NEQ,
-				Y:  ast.NewIdent("nil"),
-			},
-			Body: &ast.BlockStmt{
-				List: []ast.Stmt{
-					&ast.ExprStmt{
-						X: &ast.CallExpr{
-							Fun: &ast.SelectorExpr{
-								X:   ast.NewIdent("log"),
-								Sel: ast.NewIdent("Printf"),
-							},
-							Args: []ast.Expr{
-								&ast.BasicLit{
-									Kind:  token.STRING,
-									Value: `"Handler error: %v"`,
-								},
-								ast.NewIdent("err"),
-							},
-						},
-					},
-					&ast.ExprStmt{
-						X: &ast.CallExpr{
-							Fun: &ast.SelectorExpr{
-								X:   ast.NewIdent("w"),
-								Sel: ast.NewIdent("WriteHeader"),
-							},
-							Args: []ast.Expr{
-								&ast.BasicLit{
-									Kind:  token.INT,
-									Value: "500",
-								},
-							},
-						},
-					},
-					&ast.ReturnStmt{},
-				},
-			},
-		})
+	abs1, err1 := filepath.Abs(path1)
+	abs2, err2 := filepath.Abs(path2)
+	return err1 == nil && err2 == nil && abs1 == abs2
+}
+
+// transformInPlace migrates the file at path in place: the output is written to a temporary file, which
+// only replaces the original once it parses, keeping a .bak copy of the original if backup is set
+func transformInPlace(path string, content []byte, opts migrator.Options, backup bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary output file: %w", err)
 	}
+	defer os.Remove(tmp.Name())
 
-	// Handle output if handler returns one
-	if handlerSig.HasOutput {
-		// json.NewEncoder(w).Encode(result)
-		stmts = append(stmts, &ast.ExprStmt{
-			X: &ast.CallExpr{
-				Fun: &ast.SelectorExpr{
-					X: &ast.CallExpr{
-						Fun: &ast.SelectorExpr{
-							X:   ast.NewIdent("json"),
-							Sel: ast.NewIdent("NewEncoder"),
-						},
-						Args: []ast.Expr{ast.NewIdent("w")},
-					},
-					Sel: ast.NewIdent("Encode"),
-				},
-				Args: []ast.Expr{ast.NewIdent("result")},
-			},
-		})
+	// Warnings don't invalidate the output, they are reported once it is in place
+	transformErr := migrator.TransformTo(tmp, content, opts)
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary output file: %w", err)
+	}
+	var warningsErr *migrator.WarningsError
+	if transformErr != nil && !errors.As(transformErr, &warningsErr) {
+		return transformErr
 	}
 
-	return &ast.FuncDecl{
-		Recv: &ast.FieldList{
-			List: []*ast.Field{
-				{
-					Names: []*ast.Ident{ast.NewIdent("h")},
-					Type: &ast.StarExpr{
-						X: ast.NewIdent("Handler"),
-					},
-				},
-			},
-		},
-		Name: ast.NewIdent("Handle"),
-		Type: &ast.FuncType{
-			Params: &ast.FieldList{
-				List: []*ast.Field{
-					{
-						Names: []*ast.Ident{ast.NewIdent("ctx")},
-						Type: &ast.SelectorExpr{
-							X:   ast.NewIdent(contextAlias),
-							Sel: ast.NewIdent("Context"),
-						},
-					},
-					{
-						Names: []*ast.Ident{ast.NewIdent("w")},
-						Type: &ast.SelectorExpr{
-							X:   ast.NewIdent(httpAlias),
-							Sel: ast.NewIdent("ResponseWriter"),
-						},
-					},
-					{
-						Names: []*ast.Ident{ast.NewIdent("r")},
-						Type: &ast.StarExpr{
-							X: &ast.SelectorExpr{
-								X:   ast.NewIdent(httpAlias),
-								Sel: ast.NewIdent("Request"),
-							},
-						},
-					},
-				},
-			},
-		},
-		Body: &ast.BlockStmt{
-			List: stmts,
-		},
+	if _, err := parser.ParseFile(token.NewFileSet(), tmp.Name(), nil, parser.AllErrors); err != nil {
+		return fmt.Errorf("transformed code doesn't parse, leaving %s unchanged: %w", path, err)
 	}
-}
 
-// analyzeHandlerSignatureWithTypes uses the type checker to analyze handler signature
-// This works even if the handler is defined in another file or package
-func analyzeHandlerSignatureWithTypes(inputFile string, file *ast.File, handlerName string, fset *token.FileSet) (*HandlerSignature, error) {
-	// Get absolute path
-	absPath, err := filepath.Abs(inputFile)
+	info, err := os.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		return fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if backup {
+		if err := os.WriteFile(path+".bak", content, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write backup file: %w", err)
+		}
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set output file mode: %w", err)
 	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace input file: %w", err)
+	}
+	return transformErr
+}
 
-	// Use packages.Load to properly handle Go modules and imports
-	cfg := &packages.Config{
-		Mode: packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports,
-		Dir:  filepath.Dir(absPath),
+// writePatch writes a git patch migrating the input file to the output file, or stdout if there is none. The
+// patch refers to the input by its path relative to the root of the git repository containing it, so it
+// applies with git apply from there, falling back to the path relative to the working directory.
+func writePatch(inputFile, outputFile string, content []byte, opts migrator.Options) error {
+	var transformed bytes.Buffer
+	transformErr := migrator.TransformTo(&transformed, content, opts)
+	var warningsErr *migrator.WarningsError
+	if transformErr != nil && !errors.As(transformErr, &warningsErr) {
+		return transformErr
 	}
 
-	pkgs, err := packages.Load(cfg, ".")
+	info, err := os.Stat(inputFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load package: %w", err)
+		return fmt.Errorf("failed to stat input file: %w", err)
 	}
-
-	if len(pkgs) == 0 {
-		return nil, fmt.Errorf("no packages found")
+	path, err := patchPath(inputFile)
+	if err != nil {
+		return err
 	}
 
-	pkg := pkgs[0]
-	if len(pkg.Errors) > 0 {
-		// Log errors but continue - we might still find the handler
-		for _, err := range pkg.Errors {
-			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	output := os.Stdout
+	if outputFile != "" {
+		output, err = os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
 		}
+		defer output.Close()
 	}
+	if err := migrator.WritePatch(output, path, info.Mode(), content, transformed.Bytes()); err != nil {
+		return fmt.Errorf("failed to write patch: %w", err)
+	}
+	return transformErr
+}
 
-	// Find the handler function object in the package's type info
-	var handlerObj types.Object
-	if pkg.TypesInfo != nil {
-		// First check Defs (definitions in this package)
-		for id, obj := range pkg.TypesInfo.Defs {
-			if id.Name == handlerName {
-				if _, ok := obj.(*types.Func); ok {
-					handlerObj = obj
-					break
-				}
-			}
+// patchPath returns the slash-separated path of the file relative to the root of the git repository
+// containing it, or relative to the working directory if it isn't in one
+func patchPath(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input file: %w", err)
+	}
+	base, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			base = dir
+			break
 		}
-
-		// If not found locally, check Uses (imported symbols)
-		if handlerObj == nil {
-			for id, obj := range pkg.TypesInfo.Uses {
-				if id.Name == handlerName {
-					if _, ok := obj.(*types.Func); ok {
-						handlerObj = obj
-						break
-					}
-				}
-			}
+		if filepath.Dir(dir) == dir {
+			break
 		}
 	}
+	rel, err := filepath.Rel(base, abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input file: %w", err)
+	}
+	return filepath.ToSlash(rel), nil
+}
 
-	if handlerObj == nil {
-		return nil, fmt.Errorf("handler function %s not found in package or imports", handlerName)
+// statusFlag collects the output type to status mappings of repeated -status-for flags
+type statusFlag map[string]int
+
+func (f statusFlag) String() string {
+	var mappings []string
+	for typeName, status := range f {
+		mappings = append(mappings, typeName+"="+strconv.Itoa(status))
 	}
+	return strings.Join(mappings, ",")
+}
 
-	// Get the function signature
-	funcType, ok := handlerObj.Type().(*types.Signature)
-	if !ok {
-		return nil, fmt.Errorf("handler is not a function")
+func (f statusFlag) Set(value string) error {
+	typeName, status, ok := strings.Cut(value, "=")
+	if !ok || typeName == "" {
+		return fmt.Errorf("expected pkg.Type=status, got %q", value)
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid status %q: %w", status, err)
 	}
+	f[typeName] = code
+	return nil
+}
 
-	// Analyze the signature
-	sig := &HandlerSignature{}
+// methodFlag collects the request methods of repeated -method flags
+type methodFlag []string
 
-	// Check parameters
-	params := funcType.Params()
-	if params != nil && params.Len() > 0 {
-		// Check if first param is context.Context
-		firstParam := params.At(0)
-		if named, ok := firstParam.Type().(*types.Named); ok {
-			obj := named.Obj()
-			if obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context" {
-				sig.HasContext = true
-				if params.Len() == 2 {
-					sig.HasInput = true
-				}
-			}
-		} else if params.Len() == 1 {
-			// Single param that's not context
-			sig.HasInput = true
-		}
+func (f *methodFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *methodFlag) Set(value string) error {
+	// Methods are case-sensitive, but lowercase ones are most likely typos
+	*f = append(*f, strings.ToUpper(value))
+	return nil
+}
+
+// legacyStartFuncFlag collects the function names of repeated -legacy-start-func flags, replacing the defaults
+type legacyStartFuncFlag []string
+
+func (f *legacyStartFuncFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *legacyStartFuncFlag) Set(value string) error {
+	if !token.IsIdentifier(value) || !token.IsExported(value) {
+		return fmt.Errorf("%q is not the name of an exported function", value)
 	}
+	*f = append(*f, value)
+	return nil
+}
 
-	// Check return values
-	results := funcType.Results()
-	if results != nil && results.Len() > 0 {
-		if results.Len() == 1 {
-			// Check if it's an error
-			if results.At(0).Type().String() == "error" {
-				sig.HasError = true
-			}
-		} else if results.Len() == 2 {
-			// (TOut, error)
-			sig.HasOutput = true
-			sig.HasError = true
+// ceAttrFlag collects the CloudEvent attribute names of repeated -ce-attr flags
+type ceAttrFlag []string
+
+func (f *ceAttrFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *ceAttrFlag) Set(value string) error {
+	// Attribute names are lowercase, while header names are case-insensitive
+	*f = append(*f, strings.ToLower(value))
+	return nil
+}
+
+// headerFlag collects the static response headers of repeated -response-header flags
+type headerFlag http.Header
+
+func (f headerFlag) String() string {
+	var headers []string
+	for name, values := range f {
+		for _, value := range values {
+			headers = append(headers, name+"="+value)
 		}
 	}
+	return strings.Join(headers, ",")
+}
 
-	return sig, nil
+func (f headerFlag) Set(value string) error {
+	name, headerValue, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected Name=value, got %q", value)
+	}
+	http.Header(f).Add(strings.TrimSpace(name), strings.TrimSpace(headerValue))
+	return nil
 }
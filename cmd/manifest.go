@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+// funcSpecVersion is the version of the func.yaml schema the written manifests follow
+const funcSpecVersion = "0.36.0"
+
+// writeManifest writes the func.yaml the func CLI builds and deploys the migrated function with to path,
+// declaring the function name, its Go runtime, and how func invoke sends it requests (http or cloudevent):
+//
+//	specVersion: 0.36.0
+//	name: handle-request
+//	runtime: go
+//	invoke: http
+//
+// An existing file is only overwritten with force.
+func writeManifest(path, name, invocation string, force bool) error {
+	manifest := fmt.Sprintf(`# Build and deploy the migrated function with: func deploy
+specVersion: %s
+name: %s
+runtime: go
+invoke: %s
+`, funcSpecVersion, name, invocation)
+	return writeNewFile(path, []byte(manifest), force)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/creydr/knative-lambda-func-migrator-poc/pkg/migrator"
+)
+
+func TestWriteManifest(t *testing.T) {
+	tests := []struct {
+		handler string
+		style   string
+		want    string
+	}{
+		{
+			handler: "HandleRequest",
+			style:   migrator.StyleHandler,
+			want: `# Build and deploy the migrated function with: func deploy
+specVersion: 0.36.0
+name: handle-request
+runtime: go
+invoke: http
+`,
+		},
+		{
+			handler: "processS3Event",
+			style:   migrator.StyleCloudEvents,
+			want: `# Build and deploy the migrated function with: func deploy
+specVersion: 0.36.0
+name: process-s3-event
+runtime: go
+invoke: cloudevent
+`,
+		},
+		{
+			handler: "Handle_V2_API",
+			style:   migrator.StyleHandler,
+			want: `# Build and deploy the migrated function with: func deploy
+specVersion: 0.36.0
+name: handle-v2-api
+runtime: go
+invoke: http
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handler, func(t *testing.T) {
+			src := `package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func ` + tt.handler + `(ctx context.Context, in []byte) ([]byte, error) {
+	return in, nil
+}
+
+func main() {
+	lambda.Start(` + tt.handler + `)
+}
+`
+			var manifest migrator.FuncManifest
+			opts := migrator.DefaultOptions()
+			opts.Style = tt.style
+			opts.Manifest = &manifest
+			if err := migrator.TransformTo(io.Discard, []byte(src), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "func.yaml")
+			if err := writeManifest(path, manifest.Name, manifest.Invocation, false); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("func.yaml =\n%s\nwant\n%s", got, tt.want)
+			}
+
+			// An existing manifest is only overwritten with force
+			if err := writeManifest(path, manifest.Name, manifest.Invocation, false); err == nil {
+				t.Error("overwrote the existing func.yaml without force")
+			}
+			if err := writeManifest(path, manifest.Name, manifest.Invocation, true); err != nil {
+				t.Errorf("failed to overwrite the existing func.yaml with force: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/creydr/knative-lambda-func-migrator-poc/pkg/migrator"
+)
+
+// routeFlag collects the routes of repeated -route flags
+type routeFlag []migrator.Route
+
+func (f *routeFlag) String() string {
+	var routes []string
+	for _, route := range *f {
+		routes = append(routes, route.Path+"="+route.Filename)
+	}
+	return strings.Join(routes, ",")
+}
+
+func (f *routeFlag) Set(value string) error {
+	path, file, ok := strings.Cut(value, "=")
+	if !ok || path == "" || file == "" {
+		return fmt.Errorf("expected PATH=FILE[#HANDLER], got %q", value)
+	}
+	file, handler, _ := strings.Cut(file, "#")
+	*f = append(*f, migrator.Route{Path: path, Filename: file, Handler: handler})
+	return nil
+}
+
+// migrateRoutes reads the source of each route and writes the Knative function dispatching to their
+// handlers to w
+func migrateRoutes(w io.Writer, routes []migrator.Route, opts migrator.Options) error {
+	for i := range routes {
+		content, err := os.ReadFile(routes[i].Filename)
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		routes[i].Src = content
+	}
+	return migrator.TransformRoutesTo(w, routes, opts)
+}
@@ -2,9 +2,9 @@ module github.com/creydr/knative-lambda-func-migrator-poc
 
 go 1.25.3
 
-require golang.org/x/tools v0.38.0
-
 require (
-	golang.org/x/mod v0.29.0 // indirect
-	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/mod v0.29.0
+	golang.org/x/tools v0.38.0
 )
+
+require golang.org/x/sync v0.17.0 // indirect
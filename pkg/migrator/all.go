@@ -0,0 +1,193 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Source is a source file of a main package whose Lambda handlers TransformAllTo migrates
+type Source struct {
+	Src      []byte // Source of the file
+	Filename string // Path of the source file, needed to type check handlers declared in other files or packages
+}
+
+// TransformAllTo migrates every Lambda handler registered in the sources, e.g. the main files of a package
+// selected by build tags, into a single Knative function declaring a handler type per handler named after it
+// (e.g., OrdersHandler created by NewOrdersHandler for the handler orders), and writes its source to w. Like
+// with TransformRoutesTo, the imports and declarations of the other sources are merged into the first one
+// registering a handler, whose main function is replaced and whose build constraints are dropped. Sources
+// registering no handler are skipped; ErrNoHandler is returned if none does.
+func TransformAllTo(w io.Writer, sources []Source, opts Options) error {
+	if err := checkMergedOptions(opts); err != nil {
+		return err
+	}
+	if opts.Handler != "" {
+		return fmt.Errorf("every handler is migrated, a handler can't be selected")
+	}
+
+	report := newReporter(opts.Log, token.NewFileSet())
+	var migrations []*migration
+	for _, source := range sources {
+		sourceOpts := opts
+		sourceOpts.Filename = source.Filename
+		names, err := handlerNames(source.Src, sourceOpts)
+		if errors.Is(err, ErrNoHandler) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", source.Filename, err)
+		}
+
+		// The source is analyzed for each of its handlers, the analyses after the first only contribute the
+		// handler and the function a function literal handler was hoisted into
+		var first *migration
+		for _, name := range names {
+			sourceOpts.Handler = name
+			m, err := prepareMerged(report, source.Src, sourceOpts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source.Filename, err)
+			}
+			if first == nil {
+				first = m
+			} else {
+				removeOtherRegistrar(first, m)
+				keepImportsAndHoisted(m)
+			}
+			migrations = append(migrations, m)
+		}
+	}
+	if len(migrations) == 0 {
+		return fmt.Errorf("no source registers a handler: %w", ErrNoHandler)
+	}
+
+	dropBuildConstraints(report, migrations[0].file)
+	return transformMerged(w, report, migrations, opts, func(file *ast.File, declared map[string]bool) {
+		transformAllAST(file, migrations, declared, &opts.GenerateOptions)
+	})
+}
+
+// handlerNames returns the names of the distinct handlers registered in src that match the handler pattern
+func handlerNames(src []byte, opts Options) ([]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, opts.Filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	// Warnings are reported once the handlers are analyzed
+	handlerRefs, err := findMatchingHandlers(newReporter(nil, fset), file, opts.HandlerPattern, opts.lambdaModule(), opts.LegacyStartFuncs)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, handlerRef := range handlerRefs {
+		if !seen[handlerRef.QualifiedName] {
+			seen[handlerRef.QualifiedName] = true
+			names = append(names, handlerRef.QualifiedName)
+		}
+	}
+	return names, nil
+}
+
+// removeOtherRegistrar removes the init function registering the handler of another analysis of the same source
+// from the file of the first, whose main function is removed anyway
+func removeOtherRegistrar(first, other *migration) {
+	offset := other.fset.Position(other.handlerRef.registrar.Pos()).Offset
+	for _, decl := range first.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if ok && fn.Name.Name == "init" && fn != first.handlerRef.registrar && first.fset.Position(fn.Pos()).Offset == offset {
+			removeFunc(first.file, fn)
+			return
+		}
+	}
+}
+
+// keepImportsAndHoisted removes the declarations of the migrated file except its imports and the function its
+// handler was hoisted into, which are all another analysis of the same source doesn't already merge
+func keepImportsAndHoisted(m *migration) {
+	var decls []ast.Decl
+	for _, decl := range m.file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); (ok && genDecl.Tok == token.IMPORT) || decl == m.handlerRef.hoisted {
+			decls = append(decls, decl)
+		}
+	}
+	m.file.Decls = decls
+}
+
+// dropBuildConstraints removes the //go:build and // +build lines of the file, which would restrict the function
+// serving the handlers of every source to the builds of one
+func dropBuildConstraints(r *reporter, file *ast.File) {
+	var groups []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if group.Pos() < file.Package {
+			var comments []*ast.Comment
+			for _, comment := range group.List {
+				if constraint.IsGoBuild(comment.Text) || constraint.IsPlusBuild(comment.Text) {
+					r.logf("Dropping the build constraint %q of %s", comment.Text, r.fset.File(file.Pos()).Name())
+					continue
+				}
+				comments = append(comments, comment)
+			}
+			if len(comments) == 0 {
+				continue
+			}
+			group.List = comments
+		}
+		groups = append(groups, group)
+	}
+	file.Comments = groups
+}
+
+// transformAllAST replaces main() with a handler type, its constructor, and its Handle method for each migrated
+// handler
+func transformAllAST(file *ast.File, migrations []*migration, declared map[string]bool, opts *GenerateOptions) {
+	aliases := make(map[string]string)
+	poolBuffers := false
+	for _, m := range migrations {
+		for path, alias := range addRequiredImports(file, m.handlerSig, opts) {
+			aliases[path] = alias
+		}
+		poolBuffers = poolBuffers || poolsBuffers(m.handlerSig, opts)
+	}
+	anchorImports(file)
+
+	generated := make([]ast.Decl, 0, 3*len(migrations)+1)
+	if poolBuffers {
+		generated = append(generated, createBufferPoolDecl(aliases["sync"], aliases["bytes"]))
+	}
+	for _, m := range migrations {
+		typeName := handlerTypeName(m.handlerRef, declared)
+		handlerStruct, newFunc := createHandlerStruct(typeName), createNewFunc(typeName)
+		newFunc.Name = ast.NewIdent("New" + typeName)
+		if opts.MaxConcurrency > 0 {
+			addSemaphoreField(handlerStruct, newFunc, opts.MaxConcurrency)
+		}
+		handleMethod := createHandleMethod(copyExpr(m.handlerRef.Expr), aliases, m.handlerSig, opts)
+		handleMethod.Recv.List[0].Type = &ast.StarExpr{X: ast.NewIdent(typeName)}
+		generated = append(generated, handlerStruct, newFunc, handleMethod)
+	}
+	replaceRegistrar(file, migrations[0].handlerRef, generated)
+}
+
+// handlerTypeName returns an unused name for the type serving the handler (e.g., "OrdersHandler" for "orders",
+// or "AppHandler" for the lambda.Handler value app) whose constructor name is unused as well, and marks both as
+// used
+func handlerTypeName(handlerRef *HandlerReference, declared map[string]bool) string {
+	handlerName := handlerRef.baseName()
+	first, size := utf8.DecodeRuneInString(handlerName)
+	base := string(unicode.ToUpper(first)) + handlerName[size:] + "Handler"
+	name := base
+	for i := 2; declared[name] || declared["New"+name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	declared[name], declared["New"+name] = true, true
+	return name
+}
@@ -0,0 +1,281 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// apiGatewayRequestID and apiGatewayResponseID identify the API Gateway proxy integration event types
+const (
+	apiGatewayRequestID  = eventsPkgPath + ".APIGatewayProxyRequest"
+	apiGatewayResponseID = eventsPkgPath + ".APIGatewayProxyResponse"
+)
+
+// mapsAPIGatewayRequest reports whether the handler takes an API Gateway proxy request (or a pointer to one),
+// which is mapped from the HTTP request instead of decoded from its body
+func mapsAPIGatewayRequest(handlerSig *HandlerSignature) bool {
+	return handlerSig.HasInput && (handlerSig.InputTypeID == apiGatewayRequestID || handlerSig.InputTypeID == "*"+apiGatewayRequestID)
+}
+
+// mapsAPIGatewayResponse reports whether the handler returns an API Gateway proxy response (or a pointer to
+// one), which is written to the HTTP response instead of encoded as JSON
+func mapsAPIGatewayResponse(handlerSig *HandlerSignature) bool {
+	return handlerSig.HasOutput && (handlerSig.OutputTypeID == apiGatewayResponseID || handlerSig.OutputTypeID == "*"+apiGatewayResponseID)
+}
+
+// createAPIGatewayRequestStmts creates the statements mapping the HTTP request and its body, read before, into
+// an API Gateway proxy request. Like API Gateway, the single-value maps hold the last value of repeated
+// headers and query parameters:
+//
+//	query := r.URL.Query()
+//	req := events.APIGatewayProxyRequest{
+//		HTTPMethod:                      r.Method,
+//		Path:                            r.URL.Path,
+//		Headers:                         make(map[string]string, len(r.Header)),
+//		MultiValueHeaders:               r.Header,
+//		QueryStringParameters:           make(map[string]string, len(query)),
+//		MultiValueQueryStringParameters: query,
+//		Body:                            string(body),
+//	}
+//	for key, values := range r.Header {
+//		req.Headers[key] = values[len(values)-1]
+//	}
+//	for key, values := range query {
+//		req.QueryStringParameters[key] = values[len(values)-1]
+//	}
+func createAPIGatewayRequestStmts(eventsAlias string) []ast.Stmt {
+	requestField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent(field)}
+	}
+	urlField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: requestField("URL"), Sel: ast.NewIdent(field)}
+	}
+	makeMap := func(size ast.Expr) ast.Expr {
+		return &ast.CallExpr{
+			Fun: ast.NewIdent("make"),
+			Args: []ast.Expr{
+				&ast.MapType{Key: ast.NewIdent("string"), Value: ast.NewIdent("string")},
+				&ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{size}},
+			},
+		}
+	}
+	lastValues := func(field string, values ast.Expr) ast.Stmt {
+		return &ast.RangeStmt{
+			Key:   ast.NewIdent("key"),
+			Value: ast.NewIdent("values"),
+			Tok:   token.DEFINE,
+			X:     values,
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{&ast.IndexExpr{
+							X:     &ast.SelectorExpr{X: ast.NewIdent("req"), Sel: ast.NewIdent(field)},
+							Index: ast.NewIdent("key"),
+						}},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{&ast.IndexExpr{
+							X: ast.NewIdent("values"),
+							Index: &ast.BinaryExpr{
+								X:  &ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{ast.NewIdent("values")}},
+								Op: token.SUB,
+								Y:  &ast.BasicLit{Kind: token.INT, Value: "1"},
+							},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("query")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{Fun: urlField("Query")}},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("req")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CompositeLit{
+					Type: &ast.SelectorExpr{X: ast.NewIdent(eventsAlias), Sel: ast.NewIdent("APIGatewayProxyRequest")},
+					Elts: []ast.Expr{
+						&ast.KeyValueExpr{Key: ast.NewIdent("HTTPMethod"), Value: requestField("Method")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("Path"), Value: urlField("Path")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("Headers"), Value: makeMap(requestField("Header"))},
+						&ast.KeyValueExpr{Key: ast.NewIdent("MultiValueHeaders"), Value: requestField("Header")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("QueryStringParameters"), Value: makeMap(ast.NewIdent("query"))},
+						&ast.KeyValueExpr{Key: ast.NewIdent("MultiValueQueryStringParameters"), Value: ast.NewIdent("query")},
+						&ast.KeyValueExpr{
+							Key:   ast.NewIdent("Body"),
+							Value: &ast.CallExpr{Fun: ast.NewIdent("string"), Args: []ast.Expr{ast.NewIdent("body")}},
+						},
+					},
+				},
+			},
+		},
+		lastValues("Headers", requestField("Header")),
+		lastValues("QueryStringParameters", ast.NewIdent("query")),
+	}
+}
+
+// createAPIGatewayResponseStmts creates the statements writing an API Gateway proxy response to the HTTP
+// response. Like API Gateway, multi-value headers take precedence over single-value ones of the same name, base64
+// encoded bodies are decoded, and a zero status code is answered with 200:
+//
+//	respBody := []byte(result.Body)
+//	if result.IsBase64Encoded {
+//		decoded, err := base64.StdEncoding.DecodeString(result.Body)
+//		if err != nil {
+//			log.Printf("Invalid base64 response body: %v", err)
+//			w.WriteHeader(500)
+//			return
+//		}
+//		respBody = decoded
+//	}
+//	for key, values := range result.MultiValueHeaders {
+//		for _, value := range values {
+//			w.Header().Add(key, value)
+//		}
+//	}
+//	for key, value := range result.Headers {
+//		if _, ok := result.MultiValueHeaders[key]; !ok {
+//			w.Header().Set(key, value)
+//		}
+//	}
+//	if result.StatusCode != 0 {
+//		w.WriteHeader(result.StatusCode)
+//	}
+//	w.Write(respBody)
+//
+// A nil response pointer responds with no content:
+//
+//	if result == nil {
+//		w.WriteHeader(204)
+//		return
+//	}
+func createAPIGatewayResponseStmts(base64Alias, logAlias string, pointer bool) []ast.Stmt {
+	resultField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("result"), Sel: ast.NewIdent(field)}
+	}
+	header := func(method string, args ...ast.Expr) ast.Stmt {
+		return &ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")}},
+					Sel: ast.NewIdent(method),
+				},
+				Args: args,
+			},
+		}
+	}
+
+	var stmts []ast.Stmt
+	if pointer {
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("result"), Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(204)},
+		})
+	}
+
+	decodeErrStmts := append([]ast.Stmt{
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(logAlias), Sel: ast.NewIdent("Printf")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"Invalid base64 response body: %v"`}, ast.NewIdent("err")},
+			},
+		},
+	}, createWriteStatusStmts(500)...)
+
+	return append(stmts,
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("respBody")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{Fun: &ast.ArrayType{Elt: ast.NewIdent("byte")}, Args: []ast.Expr{resultField("Body")}}},
+		},
+		&ast.IfStmt{
+			Cond: resultField("IsBase64Encoded"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{ast.NewIdent("decoded"), ast.NewIdent("err")},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{
+							&ast.CallExpr{
+								Fun: &ast.SelectorExpr{
+									X:   &ast.SelectorExpr{X: ast.NewIdent(base64Alias), Sel: ast.NewIdent("StdEncoding")},
+									Sel: ast.NewIdent("DecodeString"),
+								},
+								Args: []ast.Expr{resultField("Body")},
+							},
+						},
+					},
+					&ast.IfStmt{
+						Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+						Body: &ast.BlockStmt{List: decodeErrStmts},
+					},
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{ast.NewIdent("respBody")},
+						Tok: token.ASSIGN,
+						Rhs: []ast.Expr{ast.NewIdent("decoded")},
+					},
+				},
+			},
+		},
+		&ast.RangeStmt{
+			Key:   ast.NewIdent("key"),
+			Value: ast.NewIdent("values"),
+			Tok:   token.DEFINE,
+			X:     resultField("MultiValueHeaders"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.RangeStmt{
+						Key:   ast.NewIdent("_"),
+						Value: ast.NewIdent("value"),
+						Tok:   token.DEFINE,
+						X:     ast.NewIdent("values"),
+						Body:  &ast.BlockStmt{List: []ast.Stmt{header("Add", ast.NewIdent("key"), ast.NewIdent("value"))}},
+					},
+				},
+			},
+		},
+		&ast.RangeStmt{
+			Key:   ast.NewIdent("key"),
+			Value: ast.NewIdent("value"),
+			Tok:   token.DEFINE,
+			X:     resultField("Headers"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.IfStmt{
+						Init: &ast.AssignStmt{
+							Lhs: []ast.Expr{ast.NewIdent("_"), ast.NewIdent("ok")},
+							Tok: token.DEFINE,
+							Rhs: []ast.Expr{&ast.IndexExpr{X: resultField("MultiValueHeaders"), Index: ast.NewIdent("key")}},
+						},
+						Cond: &ast.UnaryExpr{Op: token.NOT, X: ast.NewIdent("ok")},
+						Body: &ast.BlockStmt{List: []ast.Stmt{header("Set", ast.NewIdent("key"), ast.NewIdent("value"))}},
+					},
+				},
+			},
+		},
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: resultField("StatusCode"), Op: token.NEQ, Y: &ast.BasicLit{Kind: token.INT, Value: "0"}},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("WriteHeader")},
+							Args: []ast.Expr{resultField("StatusCode")},
+						},
+					},
+				},
+			},
+		},
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Write")},
+				Args: []ast.Expr{ast.NewIdent("respBody")},
+			},
+		},
+	)
+}
@@ -0,0 +1,158 @@
+package migrator
+
+import (
+	"go/ast"
+)
+
+// copyExpr returns a deep copy of a type or value expression without position information,
+// so it can be placed into generated code without confusing the printer
+func copyExpr(expr ast.Expr) ast.Expr {
+	return substituteExpr(expr, nil)
+}
+
+// typeParamSubstitutions maps the names of type parameters to the given type arguments
+func typeParamSubstitutions(typeParams *ast.FieldList, typeArgs []ast.Expr) map[string]ast.Expr {
+	if typeParams == nil || len(typeArgs) == 0 {
+		return nil
+	}
+
+	subst := make(map[string]ast.Expr)
+	i := 0
+	for _, field := range typeParams.List {
+		for _, name := range field.Names {
+			if i < len(typeArgs) {
+				subst[name.Name] = typeArgs[i]
+			}
+			i++
+		}
+	}
+	return subst
+}
+
+// substituteExpr returns a position-free deep copy of expr in which identifiers named in subst are replaced
+func substituteExpr(expr ast.Expr, subst map[string]ast.Expr) ast.Expr {
+	sub := func(e ast.Expr) ast.Expr {
+		return substituteExpr(e, subst)
+	}
+
+	switch e := expr.(type) {
+	case nil:
+		return nil
+	case *ast.Ident:
+		if replacement, ok := subst[e.Name]; ok {
+			return copyExpr(replacement)
+		}
+		return ast.NewIdent(e.Name)
+	case *ast.BasicLit:
+		return &ast.BasicLit{Kind: e.Kind, Value: e.Value}
+	case *ast.SelectorExpr:
+		// The selected name is never a type parameter
+		return &ast.SelectorExpr{X: sub(e.X), Sel: ast.NewIdent(e.Sel.Name)}
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: sub(e.X)}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{X: sub(e.X)}
+	case *ast.UnaryExpr:
+		return &ast.UnaryExpr{Op: e.Op, X: sub(e.X)}
+	case *ast.BinaryExpr:
+		return &ast.BinaryExpr{X: sub(e.X), Op: e.Op, Y: sub(e.Y)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: sub(e.Elt)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: sub(e.Len), Elt: sub(e.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: sub(e.Key), Value: sub(e.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: e.Dir, Value: sub(e.Value)}
+	case *ast.IndexExpr:
+		return &ast.IndexExpr{X: sub(e.X), Index: sub(e.Index)}
+	case *ast.IndexListExpr:
+		indices := make([]ast.Expr, len(e.Indices))
+		for i, index := range e.Indices {
+			indices[i] = sub(index)
+		}
+		return &ast.IndexListExpr{X: sub(e.X), Indices: indices}
+	case *ast.CallExpr:
+		args := make([]ast.Expr, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = sub(arg)
+		}
+		return &ast.CallExpr{Fun: sub(e.Fun), Args: args}
+	case *ast.CompositeLit:
+		elts := make([]ast.Expr, len(e.Elts))
+		for i, elt := range e.Elts {
+			elts[i] = sub(elt)
+		}
+		return &ast.CompositeLit{Type: sub(e.Type), Elts: elts}
+	case *ast.KeyValueExpr:
+		return &ast.KeyValueExpr{Key: sub(e.Key), Value: sub(e.Value)}
+	case *ast.FuncType:
+		return &ast.FuncType{Params: substituteFieldList(e.Params, subst), Results: substituteFieldList(e.Results, subst)}
+	case *ast.StructType:
+		return &ast.StructType{Fields: oneLineBraces(substituteFieldList(e.Fields, subst))}
+	case *ast.InterfaceType:
+		return &ast.InterfaceType{Methods: oneLineBraces(substituteFieldList(e.Methods, subst))}
+	}
+
+	// Other expressions don't appear in handler references, signatures, or generated results and are kept as-is
+	return expr
+}
+
+// substituteFieldList returns a position-free deep copy of a field list with substituted types
+func substituteFieldList(fields *ast.FieldList, subst map[string]ast.Expr) *ast.FieldList {
+	if fields == nil {
+		return nil
+	}
+
+	list := make([]*ast.Field, len(fields.List))
+	for i, field := range fields.List {
+		var names []*ast.Ident
+		for _, name := range field.Names {
+			names = append(names, ast.NewIdent(name.Name))
+		}
+		var tag *ast.BasicLit
+		if field.Tag != nil {
+			tag = &ast.BasicLit{Kind: field.Tag.Kind, Value: field.Tag.Value}
+		}
+		list[i] = &ast.Field{Names: names, Type: substituteExpr(field.Type, subst), Tag: tag}
+	}
+	return &ast.FieldList{List: list}
+}
+
+// oneLineBraces gives the braces of an empty field list positions on the same line, as they are printed on
+// separate lines otherwise (e.g., interface {\n} instead of interface{})
+func oneLineBraces(fields *ast.FieldList) *ast.FieldList {
+	if fields != nil && len(fields.List) == 0 {
+		fields.Opening, fields.Closing = 1, 1
+	}
+	return fields
+}
+
+// usesIdent reports whether any of the statements refers to the identifier with the given name
+func usesIdent(stmts []ast.Stmt, name string) bool {
+	for _, stmt := range stmts {
+		found := false
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+				found = true
+			}
+			return !found
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// paramTypes returns the type of each parameter declared by the fields, repeating the type of
+// fields declaring several names (e.g., a, b string)
+func paramTypes(fields []*ast.Field) []ast.Expr {
+	var list []ast.Expr
+	for _, field := range fields {
+		for range max(len(field.Names), 1) {
+			list = append(list, field.Type)
+		}
+	}
+	return list
+}
@@ -0,0 +1,44 @@
+package migrator
+
+import "go/ast"
+
+const (
+	// cancelFuncID, logLoggerID, and slogLoggerID identify the types of the parameters some frameworks pass
+	// besides the context and input
+	cancelFuncID = "context.CancelFunc"
+	logLoggerID  = "*log.Logger"
+	slogLoggerID = "*log/slog.Logger"
+)
+
+// auxiliaryRole returns the role of a parameter of the identified type that frameworks pass besides the
+// context and input, like a function cancelling the context or a logger, or "" if it has none
+func auxiliaryRole(typeID string) string {
+	switch typeID {
+	case cancelFuncID:
+		return SignatureRoleCancel
+	case logLoggerID, slogLoggerID:
+		return SignatureRoleLogger
+	}
+	return ""
+}
+
+// setAuxiliary records that the handler takes a parameter of the auxiliary role
+func setAuxiliary(sig *HandlerSignature, role, typeID string) {
+	switch role {
+	case SignatureRoleCancel:
+		sig.HasCancel = true
+	case SignatureRoleLogger:
+		sig.LoggerTypeID = typeID
+	}
+}
+
+// createDefaultLoggerExpr creates the expression passing the default logger of the identified type:
+//
+//	slog.Default()
+func createDefaultLoggerExpr(loggerTypeID string, aliases map[string]string) ast.Expr {
+	pkg := aliases["log"]
+	if loggerTypeID == slogLoggerID {
+		pkg = aliases["log/slog"]
+	}
+	return &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: ast.NewIdent("Default")}}
+}
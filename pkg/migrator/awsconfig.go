@@ -0,0 +1,57 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// awsConfigPkgPath is the import path of the config package of the AWS SDK for Go v2
+const awsConfigPkgPath = "github.com/aws/aws-sdk-go-v2/config"
+
+// findAWSConfigCalls finds the config.LoadDefaultConfig calls in the file, which resolve the credentials and
+// region from the environment Lambda sets up for the function's execution role
+func findAWSConfigCalls(file *ast.File) []*ast.CallExpr {
+	var calls []*ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if callExpr, ok := n.(*ast.CallExpr); ok && qualifiedFuncName(file, callExpr.Fun) == awsConfigPkgPath+".LoadDefaultConfig" {
+			calls = append(calls, callExpr)
+		}
+		return true
+	})
+	return calls
+}
+
+// setsRegion reports whether the config.LoadDefaultConfig call passes a config.WithRegion option
+func setsRegion(file *ast.File, call *ast.CallExpr) bool {
+	for _, arg := range call.Args {
+		if argCall, ok := arg.(*ast.CallExpr); ok && qualifiedFuncName(file, argCall.Fun) == awsConfigPkgPath+".WithRegion" {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteAWSConfigCalls passes the region read from the environment variable to the config.LoadDefaultConfig
+// calls not setting one, as Knative doesn't set AWS_REGION like Lambda does:
+//
+//	config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+func rewriteAWSConfigCalls(file *ast.File, calls []*ast.CallExpr, regionEnv string) {
+	var osAlias string
+	for _, call := range calls {
+		if setsRegion(file, call) || call.Ellipsis.IsValid() {
+			continue
+		}
+		if osAlias == "" {
+			osAlias = addImport(file, "os")
+		}
+		configAlias := call.Fun.(*ast.SelectorExpr).X.(*ast.Ident).Name
+		call.Args = append(call.Args, &ast.CallExpr{
+			Fun: &ast.SelectorExpr{X: ast.NewIdent(configAlias), Sel: ast.NewIdent("WithRegion")},
+			Args: []ast.Expr{&ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(osAlias), Sel: ast.NewIdent("Getenv")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(regionEnv)}},
+			}},
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package migrator
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeBenchmark writes a test file of the migrated package benchmarking the generated Handle method with a
+// sample request, whose body is a sample of the handler input like the one of the invoke example
+func writeBenchmark(w io.Writer, packageName string, handlerSig *HandlerSignature, opts *GenerateOptions) error {
+	if opts.InputSource == InputSourceMultipart {
+		return fmt.Errorf("benchmarks of handlers reading the input from a multipart upload are not supported")
+	}
+
+	method := http.MethodPost
+	if len(opts.Methods) > 0 {
+		method = opts.Methods[0]
+	}
+
+	body := ""
+	if handlerSig.HasInput && handlerSig.InputTypeID != cloudFrontRequestID && !mapsAPIGatewayRequest(handlerSig) {
+		sample, err := sampleInput(handlerSig)
+		if err != nil {
+			return err
+		}
+		body = string(sample)
+	}
+	bodyLit := strconv.Quote(body)
+	if !strings.Contains(body, "`") {
+		bodyLit = "`" + body + "`"
+	}
+
+	// Func instances run the initialization of main in Start, and take the context from the request
+	start := ""
+	ctxArg := "context.Background(), "
+	if opts.Style == StyleFuncInstance {
+		ctxArg = ""
+		start = `if err := h.Start(context.Background(), nil); err != nil {
+		b.Fatal(err)
+	}
+	defer h.Stop(context.Background())
+	`
+	}
+
+	src := fmt.Sprintf(`package %s
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// BenchmarkHandle measures how fast the migrated handler serves a sample request
+func BenchmarkHandle(b *testing.B) {
+	h := New()
+	%sbody := %s
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(%q, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		h.Handle(%shttptest.NewRecorder(), req)
+	}
+}
+`, packageName, start, bodyLit, method, ctxArg)
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
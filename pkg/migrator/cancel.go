@@ -0,0 +1,78 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// createCancelOnDisconnectStmts creates the statements deriving the handler context from ctx, cancelled
+// as soon as the client disconnects and the request context is done, or once the timeout elapsed if given:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	stop := context.AfterFunc(r.Context(), cancel)
+//	defer stop()
+func createCancelOnDisconnectStmts(contextAlias string, timeout ast.Expr) []ast.Stmt {
+	return append(createDeriveContextStmts(contextAlias, timeout),
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("stop")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("AfterFunc")},
+					Args: []ast.Expr{
+						&ast.CallExpr{
+							Fun: &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Context")},
+						},
+						ast.NewIdent("cancel"),
+					},
+				},
+			},
+		},
+		&ast.DeferStmt{
+			Call: &ast.CallExpr{Fun: ast.NewIdent("stop")},
+		},
+	)
+}
+
+// createDeriveContextStmts creates the statements deriving a cancellable context from ctx, cancelled once the
+// timeout elapsed if given:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+//	defer cancel()
+func createDeriveContextStmts(contextAlias string, timeout ast.Expr) []ast.Stmt {
+	derive := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("WithCancel")},
+		Args: []ast.Expr{ast.NewIdent("ctx")},
+	}
+	if timeout != nil {
+		derive.Fun.(*ast.SelectorExpr).Sel = ast.NewIdent("WithTimeout")
+		derive.Args = append(derive.Args, timeout)
+	}
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("ctx"), ast.NewIdent("cancel")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{derive},
+		},
+		&ast.DeferStmt{
+			Call: &ast.CallExpr{Fun: ast.NewIdent("cancel")},
+		},
+	}
+}
+
+// createRequestContextStmt creates the statement taking the context of the request, for Handle methods that
+// aren't passed one:
+//
+//	ctx := r.Context()
+func createRequestContextStmt() ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{ast.NewIdent("ctx")},
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{
+			&ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Context")},
+			},
+		},
+	}
+}
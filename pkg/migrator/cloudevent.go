@@ -0,0 +1,104 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+const (
+	// cloudEventKeyTypeName is the type of the context keys the CloudEvent attributes are stored under
+	cloudEventKeyTypeName = "cloudEventAttributeKey"
+	// cloudEventAccessorName is the function handlers read the CloudEvent attributes from their context with
+	cloudEventAccessorName = "CloudEventAttribute"
+)
+
+// isCloudEventAttributeName reports whether name is a valid CloudEvents attribute name, consisting of
+// lowercase letters and digits
+func isCloudEventAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// createCloudEventContextStmts creates the statements storing the attributes of the CloudEvent carried by
+// the request in binary content mode, i.e. in ce- headers, in the handler context:
+//
+//	ctx = context.WithValue(ctx, cloudEventAttributeKey("source"), r.Header.Get("ce-source"))
+func createCloudEventContextStmts(contextAlias string, attrs []string) []ast.Stmt {
+	var stmts []ast.Stmt
+	for _, attr := range attrs {
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("ctx")},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("WithValue")},
+				Args: []ast.Expr{
+					ast.NewIdent("ctx"),
+					&ast.CallExpr{
+						Fun:  ast.NewIdent(cloudEventKeyTypeName),
+						Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(attr)}},
+					},
+					&ast.CallExpr{
+						Fun: &ast.SelectorExpr{
+							X:   &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Header")},
+							Sel: ast.NewIdent("Get"),
+						},
+						Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote("ce-" + attr)}},
+					},
+				},
+			}},
+		})
+	}
+	return stmts
+}
+
+// createCloudEventDecls creates the context key type of the CloudEvent attributes and the function handlers
+// read them with, which returns an empty string for attributes the request didn't carry:
+//
+//	type cloudEventAttributeKey string
+//
+//	func CloudEventAttribute(ctx context.Context, name string) string {
+//		value, _ := ctx.Value(cloudEventAttributeKey(name)).(string)
+//		return value
+//	}
+func createCloudEventDecls(contextAlias string) []ast.Decl {
+	keyType := &ast.GenDecl{
+		Tok:   token.TYPE,
+		Specs: []ast.Spec{&ast.TypeSpec{Name: ast.NewIdent(cloudEventKeyTypeName), Type: ast.NewIdent("string")}},
+	}
+	accessor := &ast.FuncDecl{
+		Name: ast.NewIdent(cloudEventAccessorName),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{List: []*ast.Field{
+				{Names: []*ast.Ident{ast.NewIdent("ctx")}, Type: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("Context")}},
+				{Names: []*ast.Ident{ast.NewIdent("name")}, Type: ast.NewIdent("string")},
+			}},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("string")}}},
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("value"), ast.NewIdent("_")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.TypeAssertExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("ctx"), Sel: ast.NewIdent("Value")},
+						Args: []ast.Expr{&ast.CallExpr{
+							Fun:  ast.NewIdent(cloudEventKeyTypeName),
+							Args: []ast.Expr{ast.NewIdent("name")},
+						}},
+					},
+					Type: ast.NewIdent("string"),
+				}},
+			},
+			&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("value")}},
+		}},
+	}
+	return []ast.Decl{keyType, accessor}
+}
@@ -0,0 +1,224 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+const (
+	// eventsPkgPath is the import path of the AWS Lambda event types
+	eventsPkgPath = "github.com/aws/aws-lambda-go/events"
+
+	// cloudFrontRequestID and cloudFrontResponseID identify the Lambda@Edge event types
+	cloudFrontRequestID  = eventsPkgPath + ".CloudFrontRequest"
+	cloudFrontResponseID = eventsPkgPath + ".CloudFrontResponse"
+)
+
+// createCloudFrontRequestStmts creates the statements mapping the HTTP request into a CloudFront request
+// event, with header names lower-cased as CloudFront does:
+//
+//	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+//	req := events.CloudFrontRequest{
+//		ClientIP:    clientIP,
+//		QueryString: r.URL.RawQuery,
+//		URI:         r.URL.Path,
+//		Method:      r.Method,
+//		Headers:     make(map[string][]events.CloudFrontHeader, len(r.Header)),
+//	}
+//	for key, values := range r.Header {
+//		name := strings.ToLower(key)
+//		for _, value := range values {
+//			req.Headers[name] = append(req.Headers[name], events.CloudFrontHeader{Key: key, Value: value})
+//		}
+//	}
+func createCloudFrontRequestStmts(aliases map[string]string) []ast.Stmt {
+	eventsAlias := aliases[eventsPkgPath]
+	header := func() ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent(eventsAlias), Sel: ast.NewIdent("CloudFrontHeader")}
+	}
+	reqHeader := func() ast.Expr {
+		return &ast.IndexExpr{
+			X:     &ast.SelectorExpr{X: ast.NewIdent("req"), Sel: ast.NewIdent("Headers")},
+			Index: ast.NewIdent("name"),
+		}
+	}
+	requestField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent(field)}
+	}
+	urlField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: requestField("URL"), Sel: ast.NewIdent(field)}
+	}
+
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("clientIP"), ast.NewIdent("_"), ast.NewIdent("_")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent(aliases["net"]), Sel: ast.NewIdent("SplitHostPort")},
+					Args: []ast.Expr{requestField("RemoteAddr")},
+				},
+			},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("req")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CompositeLit{
+					Type: &ast.SelectorExpr{X: ast.NewIdent(eventsAlias), Sel: ast.NewIdent("CloudFrontRequest")},
+					Elts: []ast.Expr{
+						&ast.KeyValueExpr{Key: ast.NewIdent("ClientIP"), Value: ast.NewIdent("clientIP")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("QueryString"), Value: urlField("RawQuery")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("URI"), Value: urlField("Path")},
+						&ast.KeyValueExpr{Key: ast.NewIdent("Method"), Value: requestField("Method")},
+						&ast.KeyValueExpr{
+							Key: ast.NewIdent("Headers"),
+							Value: &ast.CallExpr{
+								Fun: ast.NewIdent("make"),
+								Args: []ast.Expr{
+									&ast.MapType{Key: ast.NewIdent("string"), Value: &ast.ArrayType{Elt: header()}},
+									&ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{requestField("Header")}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		&ast.RangeStmt{
+			Key:   ast.NewIdent("key"),
+			Value: ast.NewIdent("values"),
+			Tok:   token.DEFINE,
+			X:     requestField("Header"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{ast.NewIdent("name")},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{
+							&ast.CallExpr{
+								Fun:  &ast.SelectorExpr{X: ast.NewIdent(aliases["strings"]), Sel: ast.NewIdent("ToLower")},
+								Args: []ast.Expr{ast.NewIdent("key")},
+							},
+						},
+					},
+					&ast.RangeStmt{
+						Key:   ast.NewIdent("_"),
+						Value: ast.NewIdent("value"),
+						Tok:   token.DEFINE,
+						X:     ast.NewIdent("values"),
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								&ast.AssignStmt{
+									Lhs: []ast.Expr{reqHeader()},
+									Tok: token.ASSIGN,
+									Rhs: []ast.Expr{
+										&ast.CallExpr{
+											Fun: ast.NewIdent("append"),
+											Args: []ast.Expr{
+												reqHeader(),
+												&ast.CompositeLit{
+													Type: header(),
+													Elts: []ast.Expr{
+														&ast.KeyValueExpr{Key: ast.NewIdent("Key"), Value: ast.NewIdent("key")},
+														&ast.KeyValueExpr{Key: ast.NewIdent("Value"), Value: ast.NewIdent("value")},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// createCloudFrontResponseStmts creates the statements writing a CloudFront response event to the HTTP response:
+//
+//	for _, headers := range result.Headers {
+//		for _, header := range headers {
+//			w.Header().Add(header.Key, header.Value)
+//		}
+//	}
+//	if status, err := strconv.Atoi(result.Status); err == nil {
+//		w.WriteHeader(status)
+//	}
+//	w.Write([]byte(result.Body))
+func createCloudFrontResponseStmts(strconvAlias string) []ast.Stmt {
+	resultField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("result"), Sel: ast.NewIdent(field)}
+	}
+	headerField := func(field string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("header"), Sel: ast.NewIdent(field)}
+	}
+
+	return []ast.Stmt{
+		&ast.RangeStmt{
+			Key:   ast.NewIdent("_"),
+			Value: ast.NewIdent("headers"),
+			Tok:   token.DEFINE,
+			X:     resultField("Headers"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.RangeStmt{
+						Key:   ast.NewIdent("_"),
+						Value: ast.NewIdent("header"),
+						Tok:   token.DEFINE,
+						X:     ast.NewIdent("headers"),
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								&ast.ExprStmt{
+									X: &ast.CallExpr{
+										Fun: &ast.SelectorExpr{
+											X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")}},
+											Sel: ast.NewIdent("Add"),
+										},
+										Args: []ast.Expr{headerField("Key"), headerField("Value")},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		&ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("status"), ast.NewIdent("err")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{
+					&ast.CallExpr{
+						Fun:  &ast.SelectorExpr{X: ast.NewIdent(strconvAlias), Sel: ast.NewIdent("Atoi")},
+						Args: []ast.Expr{resultField("Status")},
+					},
+				},
+			},
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("WriteHeader")},
+							Args: []ast.Expr{ast.NewIdent("status")},
+						},
+					},
+				},
+			},
+		},
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Write")},
+				Args: []ast.Expr{
+					&ast.CallExpr{
+						Fun:  &ast.ArrayType{Elt: ast.NewIdent("byte")},
+						Args: []ast.Expr{resultField("Body")},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,73 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// addSemaphoreField adds the semaphore limiting the requests served concurrently to the generated struct and
+// creates it in New with room for the given number of requests:
+//
+//	type Handler struct {
+//		sem chan struct{}
+//	}
+//
+//	func New() *Handler {
+//		return &Handler{sem: make(chan struct{}, 10)}
+//	}
+func addSemaphoreField(handlerStruct *ast.GenDecl, newFunc *ast.FuncDecl, maxConcurrency int) {
+	// The empty struct braces need positions on the same line to be printed as struct{}
+	semType := func() ast.Expr {
+		return &ast.ChanType{Dir: ast.SEND | ast.RECV, Value: &ast.StructType{Fields: &ast.FieldList{Opening: 1, Closing: 1}}}
+	}
+
+	structType := handlerStruct.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	structType.Fields.List = append(structType.Fields.List, &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent("sem")},
+		Type:  semType(),
+	})
+
+	handlerLit := newFunc.Body.List[0].(*ast.ReturnStmt).Results[0].(*ast.UnaryExpr).X.(*ast.CompositeLit)
+	handlerLit.Elts = append(handlerLit.Elts, &ast.KeyValueExpr{
+		Key: ast.NewIdent("sem"),
+		Value: &ast.CallExpr{
+			Fun:  ast.NewIdent("make"),
+			Args: []ast.Expr{semType(), &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(maxConcurrency)}},
+		},
+	})
+}
+
+// createAcquireSemaphoreStmt creates the statement taking a slot of the semaphore for the request, released by
+// a deferred call so that panics and early returns give it back as well, and answering with 429 if all slots
+// are taken:
+//
+//	select {
+//	case h.sem <- struct{}{}:
+//		defer func() { <-h.sem }()
+//	default:
+//		w.WriteHeader(429)
+//		return
+//	}
+func createAcquireSemaphoreStmt() ast.Stmt {
+	sem := func() ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("h"), Sel: ast.NewIdent("sem")}
+	}
+	return &ast.SelectStmt{
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.CommClause{
+				Comm: &ast.SendStmt{
+					Chan:  sem(),
+					Value: &ast.CompositeLit{Type: &ast.StructType{Fields: &ast.FieldList{Opening: 1, Closing: 1}}},
+				},
+				Body: []ast.Stmt{&ast.DeferStmt{Call: &ast.CallExpr{Fun: &ast.FuncLit{
+					Type: &ast.FuncType{Params: &ast.FieldList{}},
+					Body: &ast.BlockStmt{List: []ast.Stmt{
+						&ast.ExprStmt{X: &ast.UnaryExpr{Op: token.ARROW, X: sem()}},
+					}},
+				}}}},
+			},
+			&ast.CommClause{Body: createWriteStatusStmts(429)},
+		}},
+	}
+}
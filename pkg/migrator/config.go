@@ -0,0 +1,110 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// configLoaderName is the name of the generated function populating the Config struct
+const configLoaderName = "loadConfig"
+
+// configEnvVars returns the environment variables to generate the Config struct for, if requested
+func configEnvVars(file *ast.File, opts *GenerateOptions) []string {
+	if !opts.Config {
+		return nil
+	}
+	return findEnvVars(file)
+}
+
+// configFieldNames returns the exported Go field names of the environment variables
+// (e.g., "TableName" for TABLE_NAME), made unique in order of the variables
+func configFieldNames(envVars []string) []string {
+	names := make([]string, len(envVars))
+	used := make(map[string]bool)
+	for i, envVar := range envVars {
+		var name strings.Builder
+		for _, word := range strings.FieldsFunc(envVar, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			word = strings.ToLower(word)
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+		base := name.String()
+		if base == "" || !unicode.IsLetter(rune(base[0])) {
+			base = "Env" + base
+		}
+		names[i] = base
+		for n := 2; used[names[i]]; n++ {
+			names[i] = base + strconv.Itoa(n)
+		}
+		used[names[i]] = true
+	}
+	return names
+}
+
+// createConfigDecls creates the Config struct with a field per environment variable and its loader:
+//
+//	type Config struct {
+//		TableName string
+//	}
+//
+//	func loadConfig() Config {
+//		return Config{
+//			TableName: os.Getenv("TABLE_NAME"),
+//		}
+//	}
+func createConfigDecls(envVars []string, osAlias string) (*ast.GenDecl, *ast.FuncDecl) {
+	fields := &ast.FieldList{}
+	var elts []ast.Expr
+	for i, name := range configFieldNames(envVars) {
+		fields.List = append(fields.List, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  ast.NewIdent("string"),
+		})
+		elts = append(elts, &ast.KeyValueExpr{
+			Key: ast.NewIdent(name),
+			Value: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(osAlias), Sel: ast.NewIdent("Getenv")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(envVars[i])}},
+			},
+		})
+	}
+
+	configStruct := &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{Name: ast.NewIdent("Config"), Type: &ast.StructType{Fields: fields}},
+		},
+	}
+	loader := &ast.FuncDecl{
+		Name: ast.NewIdent(configLoaderName),
+		Type: &ast.FuncType{
+			Params:  &ast.FieldList{},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("Config")}}},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{Results: []ast.Expr{&ast.CompositeLit{Type: ast.NewIdent("Config"), Elts: elts}}},
+			},
+		},
+	}
+	return configStruct, loader
+}
+
+// addConfigField adds the Config field to the Handler struct and populates it in New
+func addConfigField(handlerStruct *ast.GenDecl, newFunc *ast.FuncDecl) {
+	structType := handlerStruct.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	structType.Fields.List = append(structType.Fields.List, &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent("Config")},
+		Type:  ast.NewIdent("Config"),
+	})
+
+	handlerLit := newFunc.Body.List[0].(*ast.ReturnStmt).Results[0].(*ast.UnaryExpr).X.(*ast.CompositeLit)
+	handlerLit.Elts = append(handlerLit.Elts, &ast.KeyValueExpr{
+		Key:   ast.NewIdent("Config"),
+		Value: &ast.CallExpr{Fun: ast.NewIdent(configLoaderName)},
+	})
+}
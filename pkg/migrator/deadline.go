@@ -0,0 +1,175 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"time"
+)
+
+// deadlineHelperName is the name of the generated helper replacing ctx.Deadline() calls
+const deadlineHelperName = "requestDeadline"
+
+// deadlineCall is a ctx.Deadline() call found in the handler body
+type deadlineCall struct {
+	*ast.CallExpr
+	contextAlias string // Package name context is imported as in the handler's file
+}
+
+// findDeadlineCalls finds ctx.Deadline() calls on the context parameter of a handler declared in the file
+func findDeadlineCalls(file *ast.File, handlerName string) []deadlineCall {
+	var calls []deadlineCall
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.Name != handlerName || fn.Body == nil || len(fn.Type.Params.List) == 0 {
+			continue
+		}
+
+		// The first parameter must be a named context.Context
+		ctxParam := fn.Type.Params.List[0]
+		if !isContextExpr(file, ctxParam.Type) || len(ctxParam.Names) == 0 {
+			continue
+		}
+		contextIdent := ctxParam.Type.(*ast.SelectorExpr).X.(*ast.Ident)
+		ctxName := ctxParam.Names[0].Name
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			callExpr, ok := n.(*ast.CallExpr)
+			if !ok || len(callExpr.Args) != 0 {
+				return true
+			}
+			if sel, ok := callExpr.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Deadline" {
+				if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == ctxName {
+					calls = append(calls, deadlineCall{CallExpr: callExpr, contextAlias: contextIdent.Name})
+				}
+			}
+			return true
+		})
+	}
+
+	return calls
+}
+
+// rewriteDeadlineCalls replaces ctx.Deadline() calls with calls to a generated helper that falls back to
+// the given default, so remaining-time computations keep working when the request context has no deadline
+func rewriteDeadlineCalls(file *ast.File, calls []deadlineCall, defaultTimeout time.Duration) {
+	if len(calls) == 0 {
+		return
+	}
+
+	for _, call := range calls {
+		ctxExpr := call.Fun.(*ast.SelectorExpr).X
+		call.Fun = ast.NewIdent(deadlineHelperName)
+		call.Args = []ast.Expr{ctxExpr}
+	}
+
+	timeAlias := addImport(file, "time")
+	file.Decls = append(file.Decls, createDeadlineHelper(calls[0].contextAlias, timeAlias, defaultTimeout))
+}
+
+// createDeadlineHelper creates the helper returning the context deadline or now plus the default timeout:
+//
+//	func requestDeadline(ctx context.Context) (time.Time, bool) {
+//		if deadline, ok := ctx.Deadline(); ok {
+//			return deadline, ok
+//		}
+//		return time.Now().Add(5 * time.Minute), true
+//	}
+func createDeadlineHelper(contextAlias, timeAlias string, defaultTimeout time.Duration) *ast.FuncDecl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent(deadlineHelperName),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{ast.NewIdent("ctx")},
+						Type: &ast.SelectorExpr{
+							X:   ast.NewIdent(contextAlias),
+							Sel: ast.NewIdent("Context"),
+						},
+					},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{
+					{Type: &ast.SelectorExpr{X: ast.NewIdent(timeAlias), Sel: ast.NewIdent("Time")}},
+					{Type: ast.NewIdent("bool")},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.IfStmt{
+					Init: &ast.AssignStmt{
+						Lhs: []ast.Expr{ast.NewIdent("deadline"), ast.NewIdent("ok")},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{
+							&ast.CallExpr{
+								Fun: &ast.SelectorExpr{X: ast.NewIdent("ctx"), Sel: ast.NewIdent("Deadline")},
+							},
+						},
+					},
+					Cond: ast.NewIdent("ok"),
+					Body: &ast.BlockStmt{
+						List: []ast.Stmt{
+							&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("deadline"), ast.NewIdent("ok")}},
+						},
+					},
+				},
+				&ast.ReturnStmt{
+					Results: []ast.Expr{
+						&ast.CallExpr{
+							Fun: &ast.SelectorExpr{
+								X: &ast.CallExpr{
+									Fun: &ast.SelectorExpr{X: ast.NewIdent(timeAlias), Sel: ast.NewIdent("Now")},
+								},
+								Sel: ast.NewIdent("Add"),
+							},
+							Args: []ast.Expr{
+								createDurationExpr(timeAlias, defaultTimeout),
+							},
+						},
+						ast.NewIdent("true"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// durationUnits are the units of the time package durations are expressed in, from the largest
+var durationUnits = []struct {
+	name string
+	d    time.Duration
+}{
+	{"Hour", time.Hour},
+	{"Minute", time.Minute},
+	{"Second", time.Second},
+	{"Millisecond", time.Millisecond},
+	{"Microsecond", time.Microsecond},
+	{"Nanosecond", time.Nanosecond},
+}
+
+// createDurationExpr creates the expression of the duration in the largest unit it is a whole multiple of,
+// leaving out a factor of 1:
+//
+//	5 * time.Minute
+func createDurationExpr(timeAlias string, d time.Duration) ast.Expr {
+	unit := durationUnits[len(durationUnits)-1]
+	for _, u := range durationUnits {
+		if d%u.d == 0 {
+			unit = u
+			break
+		}
+	}
+	unitExpr := &ast.SelectorExpr{X: ast.NewIdent(timeAlias), Sel: ast.NewIdent(unit.name)}
+	if d == unit.d {
+		return unitExpr
+	}
+	return &ast.BinaryExpr{
+		X:  &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(int64(d/unit.d), 10)},
+		Op: token.MUL,
+		Y:  unitExpr,
+	}
+}
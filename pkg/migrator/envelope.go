@@ -0,0 +1,126 @@
+package migrator
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// errNoEnvelope is returned when the envelope convention is requested for a handler whose single result isn't
+// a struct with an error field
+var errNoEnvelope = errors.New("the envelope convention requires a handler returning only a struct (or a pointer to one) with an Err or Error field implementing error and a Data field or one other exported field")
+
+// errorInterface is the interface the error field of an envelope implements
+var errorInterface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// envelopeOutput describes a handler result carrying the handler error in a field instead of a separate result
+type envelopeOutput struct {
+	errField  string
+	dataField string
+	typeExpr  ast.Expr // Envelope type as an expression valid in the migrated file
+	pointer   bool     // Envelope is returned by pointer
+	dataType  types.Type
+	qf        types.Qualifier
+}
+
+// findEnvelope returns the envelope the type-checked result is, or nil if it isn't a struct or a pointer to one
+// with an exported Err or Error field implementing error. Its data field is the one named Data, or the only
+// other exported field.
+func findEnvelope(t types.Type, qf types.Qualifier) *envelopeOutput {
+	envelope := &envelopeOutput{typeExpr: typeExpr(types.TypeString(t, qf)), qf: qf}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+		envelope.pointer = true
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+
+	var others []*types.Var
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		switch {
+		case !field.Exported():
+		case (field.Name() == "Err" || field.Name() == "Error") && types.Implements(field.Type(), errorInterface) && envelope.errField == "":
+			envelope.errField = field.Name()
+		case field.Name() == "Data":
+			envelope.dataField = field.Name()
+			envelope.dataType = field.Type()
+		default:
+			others = append(others, field)
+		}
+	}
+	if envelope.dataField == "" && len(others) == 1 {
+		envelope.dataField = others[0].Name()
+		envelope.dataType = others[0].Type()
+	}
+	if envelope.errField == "" || envelope.dataField == "" {
+		return nil
+	}
+	return envelope
+}
+
+// useEnvelope makes the data field of the envelope result the handler output, so it is encoded like the
+// output of handlers returning it along with an error
+func (s *HandlerSignature) useEnvelope() error {
+	if s.envelope == nil {
+		return errNoEnvelope
+	}
+	s.EnvelopeErrField = s.envelope.errField
+	s.EnvelopeDataField = s.envelope.dataField
+	setOutputType(s, s.envelope.dataType, s.envelope.qf)
+	return nil
+}
+
+// createEnvelopeStmts creates the statements responding with 500 if the envelope returned by the handler
+// carries an error, running the onError statements first, and declaring its data as the result otherwise:
+//
+//	if envelope.Err != nil {
+//		log.Printf("Handler error: %v", envelope.Err)
+//		w.WriteHeader(500)
+//		return
+//	}
+//	result := envelope.Data
+//
+// A nil envelope pointer responds with no content:
+//
+//	if envelope == nil {
+//		w.WriteHeader(204)
+//		return
+//	}
+func createEnvelopeStmts(logAlias string, handlerSig *HandlerSignature, onError ...ast.Stmt) []ast.Stmt {
+	field := func(name string) ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent("envelope"), Sel: ast.NewIdent(name)}
+	}
+
+	var stmts []ast.Stmt
+	if handlerSig.envelope.pointer {
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("envelope"), Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(204)},
+		})
+	}
+
+	errStmts := append(onError,
+		&ast.ExprStmt{X: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{X: ast.NewIdent(logAlias), Sel: ast.NewIdent("Printf")},
+			Args: []ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: `"Handler error: %v"`},
+				field(handlerSig.EnvelopeErrField),
+			},
+		}},
+	)
+	return append(stmts,
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: field(handlerSig.EnvelopeErrField), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: append(errStmts, createWriteStatusStmts(500)...)},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("result")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{field(handlerSig.EnvelopeDataField)},
+		},
+	)
+}
@@ -0,0 +1,209 @@
+package migrator
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+const (
+	// cloudEventsPkgPath is the import path of the CloudEvents SDK the Handle method of StyleCloudEvents takes
+	// events of
+	cloudEventsPkgPath = "github.com/cloudevents/sdk-go/v2"
+	// uuidPkgPath is the import path of the package generating the IDs of the response events
+	uuidPkgPath = "github.com/google/uuid"
+)
+
+// errCloudEventsHandler is returned for handlers whose input or output is mapped from or to the HTTP request or
+// response, which the events served with StyleCloudEvents don't carry
+var errCloudEventsHandler = errors.New("the cloudevents style only passes the event data to the handler and returns its output as the data of the response event, it can't serve handlers writing the response, taking CloudFront or API Gateway proxy requests, or returning CloudFront or API Gateway proxy responses or streamed outputs")
+
+// checkCloudEventsHandler returns errCloudEventsHandler if the handler relies on the HTTP request or response
+func checkCloudEventsHandler(handlerSig *HandlerSignature) error {
+	if handlerSig.HasWriter || handlerSig.InputTypeID == cloudFrontRequestID || mapsAPIGatewayRequest(handlerSig) ||
+		handlerSig.OutputTypeID == cloudFrontResponseID || mapsAPIGatewayResponse(handlerSig) || streamsOutput(handlerSig) {
+		return errCloudEventsHandler
+	}
+	return nil
+}
+
+// createCloudEventsHandleMethod creates the Handle method of StyleCloudEvents, which decodes the data of the
+// event into the handler input and returns the handler output as the JSON data of a response event with a new
+// ID and the response source of the options. Errors of the handler are returned to the func runtime, which
+// answers them with 500:
+//
+//	func (h *Handler) Handle(ctx context.Context, event cloudevents.Event) (*cloudevents.Event, error) {
+//		var input MyEvent
+//		if err := event.DataAs(&input); err != nil {
+//			return nil, cloudevents.NewHTTPResult(400, "failed to decode event data: %v", err)
+//		}
+//		result, err := handleRequest(ctx, input)
+//		if err != nil {
+//			return nil, err
+//		}
+//		response := cloudevents.NewEvent()
+//		response.SetID(uuid.New().String())
+//		response.SetSource("handle-request")
+//		response.SetType(event.Type() + ".response")
+//		if err := response.SetData(cloudevents.ApplicationJSON, result); err != nil {
+//			return nil, err
+//		}
+//		return &response, nil
+//	}
+//
+// Byte slice inputs are passed the raw event data, and handlers returning no output or a nil pointer respond
+// with no event.
+func createCloudEventsHandleMethod(handlerFuncExpr ast.Expr, aliases map[string]string, handlerSig *HandlerSignature, opts *GenerateOptions) *ast.FuncDecl {
+	ceAlias := aliases[cloudEventsPkgPath]
+	eventCall := func(recv, method string, args ...ast.Expr) *ast.CallExpr {
+		return &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent(recv), Sel: ast.NewIdent(method)}, Args: args}
+	}
+	returnStmt := func(event, err ast.Expr) ast.Stmt {
+		return &ast.ReturnStmt{Results: []ast.Expr{event, err}}
+	}
+
+	var stmts []ast.Stmt
+	if handlerSig.HasCancel {
+		stmts = append(stmts, createDeriveContextStmts(aliases["context"], nil)...)
+	}
+
+	// Decode the event data into the input, unless it is passed the raw data
+	var inputArg ast.Expr = eventCall("event", "Data")
+	var decodeType ast.Expr
+	switch {
+	case decodesConcreteInput(handlerSig, opts):
+		decodeType = typeExpr(opts.InputType)
+	case decodesPointerInput(handlerSig, opts):
+		decodeType = handlerSig.InputTypeExpr
+	case decodesValueInput(handlerSig, opts):
+		decodeType = handlerSig.InputTypeExpr
+	}
+	if decodeType != nil {
+		inputArg = ast.NewIdent("input")
+		if star, ok := decodeType.(*ast.StarExpr); ok {
+			decodeType = star.X
+			inputArg = &ast.UnaryExpr{Op: token.AND, X: inputArg}
+		}
+		stmts = append(stmts,
+			&ast.DeclStmt{Decl: &ast.GenDecl{
+				Tok:   token.VAR,
+				Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent("input")}, Type: decodeType}},
+			}},
+			&ast.IfStmt{
+				Init: &ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("err")},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{eventCall("event", "DataAs", &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("input")})},
+				},
+				Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+				Body: &ast.BlockStmt{List: []ast.Stmt{returnStmt(ast.NewIdent("nil"), eventCall(ceAlias, "NewHTTPResult",
+					&ast.BasicLit{Kind: token.INT, Value: "400"},
+					&ast.BasicLit{Kind: token.STRING, Value: `"failed to decode event data: %v"`},
+					ast.NewIdent("err"),
+				))}},
+			},
+		)
+	}
+
+	// Build handler call arguments in the order of the parameter roles, the writer role is rejected before
+	var handlerArgs []ast.Expr
+	for _, role := range handlerSig.paramRoles() {
+		switch role {
+		case SignatureRoleContext:
+			handlerArgs = append(handlerArgs, ast.NewIdent("ctx"))
+		case SignatureRoleInput:
+			handlerArgs = append(handlerArgs, inputArg)
+		case SignatureRoleBody:
+			handlerArgs = append(handlerArgs, eventCall("event", "Data"))
+		case SignatureRoleCancel:
+			handlerArgs = append(handlerArgs, ast.NewIdent("cancel"))
+		case SignatureRoleLogger:
+			handlerArgs = append(handlerArgs, createDefaultLoggerExpr(handlerSig.LoggerTypeID, aliases))
+		}
+	}
+
+	// Call the handler and return its error
+	call := &ast.CallExpr{Fun: handlerFuncExpr, Args: handlerArgs}
+	var results []ast.Expr
+	if handlerSig.HasOutput {
+		results = append(results, ast.NewIdent("result"))
+	}
+	if handlerSig.HasError {
+		results = append(results, ast.NewIdent("err"))
+	}
+	if len(results) > 0 {
+		stmts = append(stmts, &ast.AssignStmt{Lhs: results, Tok: token.DEFINE, Rhs: []ast.Expr{call}})
+	} else {
+		stmts = append(stmts, &ast.ExprStmt{X: call})
+	}
+	if handlerSig.HasError {
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: []ast.Stmt{returnStmt(ast.NewIdent("nil"), ast.NewIdent("err"))}},
+		})
+	}
+
+	// Respond with an event carrying the output
+	if handlerSig.HasOutput {
+		if _, ok := handlerSig.OutputTypeExpr.(*ast.StarExpr); ok {
+			stmts = append(stmts, &ast.IfStmt{
+				Cond: &ast.BinaryExpr{X: ast.NewIdent("result"), Op: token.EQL, Y: ast.NewIdent("nil")},
+				Body: &ast.BlockStmt{List: []ast.Stmt{returnStmt(ast.NewIdent("nil"), ast.NewIdent("nil"))}},
+			})
+		}
+		stmts = append(stmts,
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("response")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{eventCall(ceAlias, "NewEvent")},
+			},
+			&ast.ExprStmt{X: eventCall("response", "SetID", &ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: eventCall(aliases[uuidPkgPath], "New"), Sel: ast.NewIdent("String")},
+			})},
+			&ast.ExprStmt{X: eventCall("response", "SetSource", &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(opts.ResponseSource)})},
+			&ast.ExprStmt{X: eventCall("response", "SetType", &ast.BinaryExpr{
+				X:  eventCall("event", "Type"),
+				Op: token.ADD,
+				Y:  &ast.BasicLit{Kind: token.STRING, Value: `".response"`},
+			})},
+			&ast.IfStmt{
+				Init: &ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("err")},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{eventCall("response", "SetData",
+						&ast.SelectorExpr{X: ast.NewIdent(ceAlias), Sel: ast.NewIdent("ApplicationJSON")},
+						ast.NewIdent("result"),
+					)},
+				},
+				Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+				Body: &ast.BlockStmt{List: []ast.Stmt{returnStmt(ast.NewIdent("nil"), ast.NewIdent("err"))}},
+			},
+			returnStmt(&ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("response")}, ast.NewIdent("nil")),
+		)
+	} else {
+		stmts = append(stmts, returnStmt(ast.NewIdent("nil"), ast.NewIdent("nil")))
+	}
+
+	eventType := func() ast.Expr {
+		return &ast.SelectorExpr{X: ast.NewIdent(ceAlias), Sel: ast.NewIdent("Event")}
+	}
+	return &ast.FuncDecl{
+		Recv: &ast.FieldList{
+			List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("h")}, Type: &ast.StarExpr{X: ast.NewIdent(generatedTypeName(opts))}}},
+		},
+		Name: ast.NewIdent("Handle"),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{Names: []*ast.Ident{ast.NewIdent("ctx")}, Type: &ast.SelectorExpr{X: ast.NewIdent(aliases["context"]), Sel: ast.NewIdent("Context")}},
+					{Names: []*ast.Ident{ast.NewIdent("event")}, Type: eventType()},
+				},
+			},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: &ast.StarExpr{X: eventType()}}, {Type: ast.NewIdent("error")}},
+			},
+		},
+		Body: &ast.BlockStmt{List: stmts},
+	}
+}
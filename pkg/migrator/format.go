@@ -0,0 +1,20 @@
+package migrator
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/imports"
+)
+
+// formatSource formats the printed code like goimports without adding or removing imports: the imports are
+// sorted and grouped, standard library packages first and a blank line before third-party ones, and the spacing
+// of the generated declarations is normalized like gofmt does. Code that can't be formatted, e.g. as the source
+// doesn't parse once transformed, is returned as printed with a warning.
+func formatSource(r *reporter, src []byte) []byte {
+	formatted, err := imports.Process("", src, &imports.Options{Comments: true, TabIndent: true, TabWidth: 8, FormatOnly: true})
+	if err != nil {
+		r.warnf(token.NoPos, "failed to format the migrated code, writing it as printed: %v", err)
+		return src
+	}
+	return formatted
+}
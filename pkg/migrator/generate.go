@@ -0,0 +1,585 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+)
+
+// transformAST modifies the AST to replace main() with Knative handler structure
+func transformAST(file *ast.File, handlerRef *HandlerReference, handlerSig *HandlerSignature, opts *GenerateOptions) {
+	// Imports only main referred to are removed once it is transformed
+	refsBefore := packageRefs(file)
+
+	// Remove lambda import if present
+	removeLambdaImport(file, opts.lambdaModule())
+
+	// Add context, net/http, and io imports if not present and get their aliases
+	aliases := addRequiredImports(file, handlerSig, opts)
+	anchorImports(file)
+
+	// Response events are sourced from the function named after the handler unless a source is given
+	if opts.Style == StyleCloudEvents && opts.ResponseSource == "" {
+		sourced := *opts
+		sourced.ResponseSource = manifestName(handlerRef)
+		opts = &sourced
+	}
+
+	// Remove main if the handler is registered in an init function, which main never ran after
+	for _, fn := range handlerRef.removedFuncs(file)[1:] {
+		removeFunc(file, fn)
+	}
+
+	// A hoisted function literal handler is declared before the generated code, which its comments would
+	// otherwise be printed in as the generated declarations have no positions
+	if handlerRef.hoisted != nil {
+		file.Decls = slices.DeleteFunc(file.Decls, func(decl ast.Decl) bool { return decl == handlerRef.hoisted })
+	}
+
+	// Find and transform the function registering the handler
+	for i, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn == handlerRef.registrar {
+			// Create Handler struct, New function, and Handle method
+			typeName := generatedTypeName(opts)
+			handlerStruct := createHandlerStruct(typeName)
+			newFunc := createNewFunc(typeName)
+			handleMethod := createHandleMethod(copyExpr(handlerRef.Expr), aliases, handlerSig, opts)
+
+			// Replace the function with the new declarations
+			newDecls := make([]ast.Decl, 0, len(file.Decls)+5)
+			newDecls = append(newDecls, file.Decls[:i]...)
+
+			// main is kept as the initialization run by Start with StyleFuncInstance, and as New otherwise, along
+			// with its comments, while the comments of the removed statements are dropped
+			start, deferred, _ := instanceHooks(file, fn, handlerRef.Expr)
+			var hooks []ast.Decl
+			if opts.Style == StyleFuncInstance {
+				hooks = createInstanceHooks(deferred, aliases["context"], opts.Readyz)
+			}
+			var moved []ast.Node
+			if handlerRef.hoisted != nil {
+				moved = append(moved, handlerRef.hoisted)
+			}
+			dropComments(file, fn.Body, fn.Body.List, start, moved...)
+			if opts.Readyz {
+				addReadyField(handlerStruct, aliases["sync/atomic"])
+			}
+			if opts.MaxConcurrency > 0 {
+				addSemaphoreField(handlerStruct, newFunc, opts.MaxConcurrency)
+			}
+			envVars := configEnvVars(file, opts)
+			if len(envVars) > 0 {
+				addConfigField(handlerStruct, newFunc)
+			}
+			generated := []ast.Decl{handlerStruct, newFunc}
+			if opts.Style == StyleFuncInstance {
+				newDecls = append(newDecls, createInitializeFunc(fn, start))
+			} else if len(start) > 0 {
+				// Like the initialization, New keeping the setup is declared before the generated code, which its
+				// comments would otherwise be printed in
+				newDecls = append(newDecls, createSetupNewFunc(fn, start, newFunc))
+				generated = []ast.Decl{handlerStruct}
+			}
+			if handlerRef.hoisted != nil {
+				newDecls = append(newDecls, handlerRef.hoisted)
+			}
+
+			if poolsBuffers(handlerSig, opts) {
+				newDecls = append(newDecls, createBufferPoolDecl(aliases["sync"], aliases["bytes"]))
+			}
+			if validatesOutput(handlerSig, opts) {
+				newDecls = append(newDecls, createValidateOutputDecl())
+			}
+			if len(envVars) > 0 {
+				configStruct, loader := createConfigDecls(envVars, aliases["os"])
+				newDecls = append(newDecls, configStruct)
+				newDecls = append(newDecls, generated...)
+				newDecls = append(newDecls, loader)
+			} else {
+				newDecls = append(newDecls, generated...)
+			}
+			newDecls = append(newDecls, handleMethod)
+			newDecls = append(newDecls, hooks...)
+			if len(opts.CloudEventAttrs) > 0 {
+				newDecls = append(newDecls, createCloudEventDecls(aliases["context"])...)
+			}
+			if opts.InjectLogger {
+				newDecls = append(newDecls, createLoggerDecls(aliases["context"], aliases["log/slog"])...)
+			}
+			if opts.Metrics {
+				newDecls = append(newDecls, createMetricsDecls(aliases[otelPkgPath], aliases[otelMetricPkgPath])...)
+			}
+			newDecls = append(newDecls, file.Decls[i+1:]...)
+			file.Decls = newDecls
+			break
+		}
+	}
+
+	removeUnusedImports(file, refsBefore, packageRefs(file))
+}
+
+// createHandlerStruct creates the declaration of the struct type serving the requests (e.g., Handler)
+func createHandlerStruct(typeName string) *ast.GenDecl {
+	return &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{
+			&ast.TypeSpec{
+				Name: ast.NewIdent(typeName),
+				Type: &ast.StructType{
+					Fields: &ast.FieldList{},
+				},
+			},
+		},
+	}
+}
+
+// createNewFunc creates the New() function that returns a pointer to the generated type (e.g., *Handler)
+func createNewFunc(typeName string) *ast.FuncDecl {
+	return &ast.FuncDecl{
+		Name: ast.NewIdent("New"),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{},
+			Results: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Type: &ast.StarExpr{
+							X: ast.NewIdent(typeName),
+						},
+					},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.ReturnStmt{
+					Results: []ast.Expr{
+						&ast.UnaryExpr{
+							Op: token.AND,
+							X: &ast.CompositeLit{
+								Type: ast.NewIdent(typeName),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// createHandleMethod creates the Handle method for the generated struct based on the handler signature
+func createHandleMethod(handlerFuncExpr ast.Expr, aliases map[string]string, handlerSig *HandlerSignature, opts *GenerateOptions) *ast.FuncDecl {
+	if opts.Style == StyleCloudEvents {
+		method := createCloudEventsHandleMethod(handlerFuncExpr, aliases, handlerSig, opts)
+		avoidShadowing(method, handlerFuncExpr)
+		return method
+	}
+	contextAlias, httpAlias, ioAlias := aliases["context"], aliases["net/http"], aliases["io"]
+
+	// Build the body statements
+	var stmts []ast.Stmt
+
+	// Answer readiness probes before any other work, so they aren't subject to restrictions on requests
+	if opts.Readyz {
+		stmts = append(stmts, createReadyzStmt())
+	}
+
+	// Set the static response headers first, so every response carries them
+	if len(opts.ResponseHeaders) > 0 {
+		stmts = append(stmts, createResponseHeaderStmts(opts.ResponseHeaders)...)
+	}
+
+	// Reject disallowed methods before doing any other work
+	if len(opts.Methods) > 0 {
+		stmts = append(stmts, createMethodCheckStmt(opts.Methods))
+	}
+
+	// Reject requests over the concurrency limit before doing any work for them
+	if opts.MaxConcurrency > 0 {
+		stmts = append(stmts, createAcquireSemaphoreStmt())
+	}
+
+	// Recover from panics next, so they are caught wherever they happen
+	if opts.Recover {
+		stmts = append(stmts, createRecoverStmt(aliases["runtime/debug"], aliases["log"], opts.PanicStatus, !opts.NoStack))
+	}
+
+	// Stop the handler from working on requests abandoned by the client or exceeding the timeout
+	var timeout ast.Expr
+	if opts.Timeout > 0 {
+		timeout = createDurationExpr(aliases["time"], opts.Timeout)
+	}
+	// (the request context func instances derive theirs from is already done once the client disconnects)
+	if (handlerSig.HasContext || handlerSig.HasCancel) && opts.Style != StyleFuncInstance {
+		stmts = append(stmts, createCancelOnDisconnectStmts(contextAlias, timeout)...)
+	} else if timeout != nil || handlerSig.HasCancel {
+		stmts = append(stmts, createDeriveContextStmts(contextAlias, timeout)...)
+	}
+
+	// Pass the attributes of the CloudEvent carried by the request on to the handler
+	if len(opts.CloudEventAttrs) > 0 {
+		stmts = append(stmts, createCloudEventContextStmts(contextAlias, opts.CloudEventAttrs)...)
+	}
+
+	// Pass a logger carrying the attributes of the request on to the handler
+	if opts.InjectLogger {
+		stmts = append(stmts, createLoggerContextStmts(contextAlias, aliases["log/slog"])...)
+	}
+
+	// Read request body if handler expects input, or map the request into a CloudFront event, and map it into an
+	// API Gateway proxy request along with the body
+	var inputArg ast.Expr = ast.NewIdent("body")
+	if handlerSig.HasInput {
+		if handlerSig.InputTypeID == cloudFrontRequestID {
+			stmts = append(stmts, createCloudFrontRequestStmts(aliases)...)
+			inputArg = ast.NewIdent("req")
+		} else {
+			stmts = append(stmts, createReadBodyStmts(aliases, opts)...)
+		}
+		if mapsAPIGatewayRequest(handlerSig) {
+			stmts = append(stmts, createAPIGatewayRequestStmts(aliases[eventsPkgPath])...)
+			inputArg = ast.NewIdent("req")
+			if handlerSig.InputIsPointer {
+				inputArg = &ast.UnaryExpr{Op: token.AND, X: inputArg}
+			}
+		} else if decodesProtoInput(handlerSig, opts) {
+			stmts = append(stmts, createProtoUnmarshalStmts(handlerSig.InputTypeExpr, aliases[protojsonPkgPath])...)
+			inputArg = ast.NewIdent("in")
+		} else if decodesConcreteInput(handlerSig, opts) {
+			// Pointer types are decoded into the value they point to like pointer inputs
+			concrete := typeExpr(opts.InputType)
+			if star, ok := concrete.(*ast.StarExpr); ok {
+				stmts = append(stmts, createDecodeInputStmts(star.X, aliases, opts)...)
+				inputArg = &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("input")}
+			} else {
+				stmts = append(stmts, createDecodeInputStmts(concrete, aliases, opts)...)
+				inputArg = ast.NewIdent("input")
+			}
+		} else if decodesPointerInput(handlerSig, opts) {
+			stmts = append(stmts, createDecodeInputStmts(handlerSig.InputTypeExpr.(*ast.StarExpr).X, aliases, opts)...)
+			inputArg = &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("input")}
+		} else if decodesValueInput(handlerSig, opts) {
+			stmts = append(stmts, createDecodeInputStmts(handlerSig.InputTypeExpr, aliases, opts)...)
+			inputArg = ast.NewIdent("input")
+		}
+	}
+
+	// Build handler call arguments in the order of the parameter roles
+	var handlerArgs []ast.Expr
+	for _, role := range handlerSig.paramRoles() {
+		switch role {
+		case SignatureRoleContext:
+			handlerArgs = append(handlerArgs, ast.NewIdent("ctx"))
+		case SignatureRoleInput:
+			handlerArgs = append(handlerArgs, inputArg)
+		case SignatureRoleBody:
+			handlerArgs = append(handlerArgs, ast.NewIdent("body"))
+		case SignatureRoleWriter:
+			handlerArgs = append(handlerArgs, ast.NewIdent("w"))
+		case SignatureRoleCancel:
+			handlerArgs = append(handlerArgs, ast.NewIdent("cancel"))
+		case SignatureRoleLogger:
+			if injectsLoggerParam(handlerSig, opts) {
+				handlerArgs = append(handlerArgs, ast.NewIdent("logger"))
+			} else {
+				handlerArgs = append(handlerArgs, createDefaultLoggerExpr(handlerSig.LoggerTypeID, aliases))
+			}
+		}
+	}
+
+	// Count the invocation by its outcome once Handle returns, which is a panic unless the handler returned
+	if opts.Metrics {
+		stmts = append(stmts, createRecordOutcomeStmts(aliases[otelMetricPkgPath], aliases[otelAttributePkgPath])...)
+	}
+
+	// Call the handler and capture results
+	callIndex := len(stmts)
+	if handlerSig.HasOutput && handlerSig.HasError {
+		// result, err := handlerFuncName(args...)
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("result"), ast.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  handlerFuncExpr,
+					Args: handlerArgs,
+				},
+			},
+		})
+	} else if handlerSig.HasError {
+		// err := handlerFuncName(args...)
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  handlerFuncExpr,
+					Args: handlerArgs,
+				},
+			},
+		})
+	} else if handlerSig.HasOutput {
+		// result := handlerFuncName(args...), or envelope := handlerFuncName(args...) for envelope results
+		resultName := "result"
+		if handlerSig.EnvelopeErrField != "" {
+			resultName = "envelope"
+		}
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(resultName)},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  handlerFuncExpr,
+					Args: handlerArgs,
+				},
+			},
+		})
+	} else {
+		// handlerFuncName(args...)
+		stmts = append(stmts, &ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  handlerFuncExpr,
+				Args: handlerArgs,
+			},
+		})
+	}
+
+	// Stop waiting for the handler once the timeout elapsed
+	if opts.Timeout > 0 {
+		var onTimeout []ast.Stmt
+		if opts.Metrics {
+			onTimeout = append(onTimeout, createOutcomeStmt(outcomeTimeout))
+		}
+		stmts = append(stmts[:callIndex], createTimeoutCallStmts(stmts[callIndex], handlerSig, onTimeout...)...)
+	}
+
+	// Handle error if handler returns one
+	if handlerSig.HasError {
+		// if err != nil {
+		//     log.Printf("Handler error: %v", err)
+		//     w.WriteHeader(500)
+		//     return
+		// }
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  ast.NewIdent("err"),
+				Op: token.NEQ,
+				Y:  ast.NewIdent("nil"),
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun: &ast.SelectorExpr{
+								X:   ast.NewIdent(aliases["log"]),
+								Sel: ast.NewIdent("Printf"),
+							},
+							Args: []ast.Expr{
+								&ast.BasicLit{
+									Kind:  token.STRING,
+									Value: `"Handler error: %v"`,
+								},
+								ast.NewIdent("err"),
+							},
+						},
+					},
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun: &ast.SelectorExpr{
+								X:   ast.NewIdent("w"),
+								Sel: ast.NewIdent("WriteHeader"),
+							},
+							Args: []ast.Expr{
+								&ast.BasicLit{
+									Kind:  token.INT,
+									Value: "500",
+								},
+							},
+						},
+					},
+					&ast.ReturnStmt{},
+				},
+			},
+		})
+		if opts.Metrics {
+			errBody := stmts[len(stmts)-1].(*ast.IfStmt).Body
+			errBody.List = append([]ast.Stmt{createOutcomeStmt(outcomeError)}, errBody.List...)
+		}
+	}
+
+	// Respond with the error an envelope result carries, and unwrap its data otherwise
+	if handlerSig.EnvelopeErrField != "" {
+		var onError []ast.Stmt
+		if opts.Metrics {
+			onError = append(onError, createOutcomeStmt(outcomeError))
+		}
+		stmts = append(stmts, createEnvelopeStmts(aliases["log"], handlerSig, onError...)...)
+	}
+	if opts.Metrics {
+		stmts = append(stmts, createOutcomeStmt(outcomeSuccess))
+	}
+
+	// Handle output if handler returns one
+	if handlerSig.HasOutput {
+		outputIndex := len(stmts)
+		switch {
+		case handlerSig.OutputTypeID == cloudFrontResponseID:
+			stmts = append(stmts, createCloudFrontResponseStmts(aliases["strconv"])...)
+		case mapsAPIGatewayResponse(handlerSig):
+			stmts = append(stmts, createAPIGatewayResponseStmts(aliases["encoding/base64"], aliases["log"], handlerSig.OutputTypeID == "*"+apiGatewayResponseID)...)
+		case streamsOutput(handlerSig):
+			stmts = append(stmts, createStreamResultStmts(ioAlias, aliases["log"], handlerSig, successStatus(handlerSig, opts))...)
+		case handlerSig.RawOutput:
+			stmts = append(stmts, createRawResultStmts(successStatus(handlerSig, opts))...)
+		case encodesProtoOutput(handlerSig, opts):
+			stmts = append(stmts, createProtoMarshalStmts(aliases[protojsonPkgPath], aliases["log"], successStatus(handlerSig, opts))...)
+		default:
+			if emptyMapOutput(handlerSig, opts) {
+				stmts = append(stmts, createEmptyMapStmt(handlerSig.OutputTypeExpr))
+			} else if pointerCollectionOutput(handlerSig, opts) {
+				stmts = append(stmts, createPointerCollectionStmts(handlerSig.OutputTypeExpr.(*ast.StarExpr).X)...)
+			}
+			if validatesOutput(handlerSig, opts) {
+				stmts = append(stmts, createValidateOutputStmt())
+			}
+			stmts = append(stmts, createEncodeResultStmts(aliases["encoding/json"], successStatus(handlerSig, opts), opts.PrettyOutput)...)
+		}
+
+		// An assigned result that no output statement uses doesn't compile
+		if !usesIdent(stmts[outputIndex:], "result") {
+			stmts = append(stmts, &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("_")},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{ast.NewIdent("result")},
+			})
+		}
+	}
+
+	// Func instances implement Handle(http.ResponseWriter, *http.Request), taking the context from the request
+	if opts.Style == StyleFuncInstance && usesIdent(stmts, "ctx") {
+		stmts = append([]ast.Stmt{createRequestContextStmt()}, stmts...)
+	}
+
+	method := &ast.FuncDecl{
+		Recv: &ast.FieldList{
+			List: []*ast.Field{
+				{
+					Names: []*ast.Ident{ast.NewIdent("h")},
+					Type: &ast.StarExpr{
+						X: ast.NewIdent(generatedTypeName(opts)),
+					},
+				},
+			},
+		},
+		Name: ast.NewIdent("Handle"),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{
+				List: []*ast.Field{
+					{
+						Names: []*ast.Ident{ast.NewIdent("w")},
+						Type: &ast.SelectorExpr{
+							X:   ast.NewIdent(httpAlias),
+							Sel: ast.NewIdent("ResponseWriter"),
+						},
+					},
+					{
+						Names: []*ast.Ident{ast.NewIdent("r")},
+						Type: &ast.StarExpr{
+							X: &ast.SelectorExpr{
+								X:   ast.NewIdent(httpAlias),
+								Sel: ast.NewIdent("Request"),
+							},
+						},
+					},
+				},
+			},
+		},
+		Body: &ast.BlockStmt{
+			List: stmts,
+		},
+	}
+	if opts.Style != StyleFuncInstance {
+		ctxParam := &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent("ctx")},
+			Type: &ast.SelectorExpr{
+				X:   ast.NewIdent(contextAlias),
+				Sel: ast.NewIdent("Context"),
+			},
+		}
+		method.Type.Params.List = append([]*ast.Field{ctxParam}, method.Type.Params.List...)
+	}
+	avoidShadowing(method, handlerFuncExpr)
+	return method
+}
+
+// createEncodeResultStmts creates the statements encoding the handler result as JSON response, writing
+// the status first if one is given:
+//
+//	w.WriteHeader(status)
+//	json.NewEncoder(w).Encode(result)
+//
+// Pretty output is indented and declares its content type:
+//
+//	w.Header().Set("Content-Type", "application/json")
+//	w.WriteHeader(status)
+//	enc := json.NewEncoder(w)
+//	enc.SetIndent("", "  ")
+//	enc.Encode(result)
+func createEncodeResultStmts(jsonAlias string, status int, pretty bool) []ast.Stmt {
+	newEncoder := &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   ast.NewIdent(jsonAlias),
+			Sel: ast.NewIdent("NewEncoder"),
+		},
+		Args: []ast.Expr{ast.NewIdent("w")},
+	}
+	encode := func(enc ast.Expr) ast.Stmt {
+		return &ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: enc, Sel: ast.NewIdent("Encode")},
+				Args: []ast.Expr{ast.NewIdent("result")},
+			},
+		}
+	}
+
+	var stmts []ast.Stmt
+	if pretty {
+		stmts = append(stmts, &ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")},
+					},
+					Sel: ast.NewIdent("Set"),
+				},
+				Args: []ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: `"Content-Type"`},
+					&ast.BasicLit{Kind: token.STRING, Value: `"application/json"`},
+				},
+			},
+		})
+	}
+	if status != 0 {
+		stmts = append(stmts, createWriteHeaderStmt(status))
+	}
+	if !pretty {
+		return append(stmts, encode(newEncoder))
+	}
+
+	return append(stmts,
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("enc")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{newEncoder},
+		},
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent("enc"), Sel: ast.NewIdent("SetIndent")},
+				Args: []ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: `""`},
+					&ast.BasicLit{Kind: token.STRING, Value: `"  "`},
+				},
+			},
+		},
+		encode(ast.NewIdent("enc")),
+	)
+}
@@ -0,0 +1,108 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// syncMethods are the methods whose calls mark a function as synchronizing its accesses to shared state
+var syncMethods = map[string]bool{"Lock": true, "RLock": true}
+
+// findGlobalWrites finds the first write to each package-level variable declared in the file by the handler
+// declared in the file and the functions of the file it calls, directly or indirectly. Lambda runs one request
+// at a time per instance, while Knative may serve requests concurrently, so such writes may race now. Writes
+// in functions locking a mutex are considered synchronized.
+func findGlobalWrites(file *ast.File, handlerName string) []*ast.Ident {
+	funcs := make(map[string]*ast.FuncDecl)
+	var queue []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+			if fn.Recv == nil {
+				funcs[fn.Name.Name] = fn
+			}
+			if fn.Name.Name == handlerName {
+				queue = append(queue, fn)
+			}
+		}
+	}
+
+	first := make(map[*ast.Object]*ast.Ident)
+	visited := make(map[*ast.FuncDecl]bool)
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		if visited[fn] {
+			continue
+		}
+		visited[fn] = true
+
+		var writes []*ast.Ident
+		synchronized := false
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				if n.Tok != token.DEFINE {
+					for _, lhs := range n.Lhs {
+						writes = append(writes, rootIdent(lhs))
+					}
+				}
+			case *ast.IncDecStmt:
+				writes = append(writes, rootIdent(n.X))
+			case *ast.CallExpr:
+				switch fun := n.Fun.(type) {
+				case *ast.Ident:
+					if callee, ok := funcs[fun.Name]; ok && (fun.Obj == nil || fun.Obj.Kind == ast.Fun) {
+						queue = append(queue, callee)
+					} else if fun.Name == "delete" && fun.Obj == nil && len(n.Args) > 0 {
+						writes = append(writes, rootIdent(n.Args[0]))
+					}
+				case *ast.SelectorExpr:
+					synchronized = synchronized || syncMethods[fun.Sel.Name]
+				}
+			}
+			return true
+		})
+		if synchronized {
+			continue
+		}
+		for _, ident := range writes {
+			if ident == nil || ident.Obj == nil || ident.Obj.Kind != ast.Var || file.Scope.Lookup(ident.Name) != ident.Obj {
+				continue
+			}
+			if prev, ok := first[ident.Obj]; !ok || ident.Pos() < prev.Pos() {
+				first[ident.Obj] = ident
+			}
+		}
+	}
+
+	idents := make([]*ast.Ident, 0, len(first))
+	for _, ident := range first {
+		idents = append(idents, ident)
+	}
+	sort.Slice(idents, func(i, j int) bool {
+		return idents[i].Pos() < idents[j].Pos()
+	})
+	return idents
+}
+
+// rootIdent returns the variable an assigned expression writes to (e.g., cache for cache[key] or
+// stats.count), or nil if it doesn't write to a variable
+func rootIdent(expr ast.Expr) *ast.Ident {
+	for {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package migrator
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// GoMod returns a minimal go.mod making the migrated file a module of its own with the given module path. It
+// requires the modules providing the packages the migrated file imports, at the versions the module
+// containing the input file in inputDir requires, and declares the same go version, or the one of the
+// running toolchain if the input isn't part of a module. The imports no required module provides (e.g.,
+// packages of the input's module) are returned, as the new module can't resolve them.
+func GoMod(inputDir, modulePath string, migrated []byte) ([]byte, []string, error) {
+	if err := module.CheckPath(modulePath); err != nil {
+		return nil, nil, fmt.Errorf("invalid module path: %w", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", migrated, parser.ImportsOnly)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse migrated code: %w", err)
+	}
+
+	goVersion := strings.TrimPrefix(runtime.Version(), "go")
+	var requires []*modfile.Require
+	var inputModule string
+	absDir, err := filepath.Abs(inputDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if root, ok := findModuleRoot(absDir); ok {
+		path := filepath.Join(root, "go.mod")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read go.mod: %w", err)
+		}
+		inputMod, err := modfile.ParseLax(path, data, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if inputMod.Go != nil {
+			goVersion = inputMod.Go.Version
+		}
+		if inputMod.Module != nil {
+			inputModule = inputMod.Module.Mod.Path
+		}
+		requires = inputMod.Require
+	}
+
+	gomod := &modfile.File{}
+	if err := gomod.AddModuleStmt(modulePath); err != nil {
+		return nil, nil, err
+	}
+	if err := gomod.AddGoStmt(goVersion); err != nil {
+		return nil, nil, err
+	}
+
+	var unresolved []string
+	required := make(map[string]bool)
+	for _, importSpec := range file.Imports {
+		importPath, err := strconv.Unquote(importSpec.Path.Value)
+		if err != nil || isStdlibImport(importPath) {
+			continue
+		}
+		req := providingModule(requires, importPath)
+		// Packages of the input's module are only provided by modules nested in it
+		if req == nil || (inModule(inputModule, importPath) && !inModule(inputModule, req.Mod.Path)) {
+			unresolved = append(unresolved, importPath)
+			continue
+		}
+		if !required[req.Mod.Path] {
+			required[req.Mod.Path] = true
+			gomod.AddNewRequire(req.Mod.Path, req.Mod.Version, false)
+		}
+	}
+	gomod.Cleanup()
+
+	data, err := gomod.Format()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to format go.mod: %w", err)
+	}
+	return data, unresolved, nil
+}
+
+// isStdlibImport reports whether the import path refers to a standard library package, whose first path
+// element has no dot unlike those of module paths
+func isStdlibImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// providingModule returns the requirement of the module providing the imported package, i.e. the one with
+// the longest path the import path is in, or nil if there is none
+func providingModule(requires []*modfile.Require, importPath string) *modfile.Require {
+	var provider *modfile.Require
+	for _, req := range requires {
+		if inModule(req.Mod.Path, importPath) && (provider == nil || len(req.Mod.Path) > len(provider.Mod.Path)) {
+			provider = req
+		}
+	}
+	return provider
+}
+
+// inModule reports whether the import path refers to a package of the module
+func inModule(modulePath, importPath string) bool {
+	return modulePath != "" && (importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/"))
+}
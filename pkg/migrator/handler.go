@@ -0,0 +1,337 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrNoHandler is returned for sources that don't register a Lambda handler in their main or init functions
+var ErrNoHandler = errors.New("no lambda handler found")
+
+// HandlerReference holds information about the lambda handler reference
+type HandlerReference struct {
+	SimpleName    string     // Just the function name (e.g., "HandleRequest")
+	QualifiedName string     // Full name including package and type arguments if present (e.g., "handler.HandleRequest[Event]")
+	TypeArgs      []ast.Expr // Type arguments of an explicit generic instantiation (e.g., [Event])
+	Expr          ast.Expr   // The handler expression as passed to lambda.Start
+	Package       string     // Import path of the package declaring the handler if given explicitly (e.g., with -handler-package)
+	Decorated     bool       // The handler is the result of a decorator call (e.g., withAuth(handleRequest)), SimpleName names the decorator
+	Invoked       bool       // The handler is the Invoke method of a value implementing lambda.Handler (e.g., passed to lambda.StartHandler)
+
+	registrar *ast.FuncDecl // Function calling lambda.Start, main or an init function
+	fileScope *ast.Scope    // Scope of the file registering the handler, declaring the receivers of method value handlers
+	startCtx  ast.Expr      // Context passed to the start function along with the handler (e.g., by lambda.StartWithContext), nil if none
+	hoisted   *ast.FuncDecl // Function a function literal handler was hoisted into, nil if the handler isn't one
+}
+
+// handlerReferenceFromExpr creates the handler reference for an expression passed to lambda.Start,
+// returning nil if the expression is not supported
+func handlerReferenceFromExpr(expr ast.Expr) *HandlerReference {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		// Simple identifier (e.g., handleRequest)
+		return &HandlerReference{
+			SimpleName:    e.Name,
+			QualifiedName: e.Name,
+			Expr:          e,
+		}
+	case *ast.SelectorExpr:
+		// Selector (e.g., handler.HandleRequest)
+		if pkgIdent, ok := e.X.(*ast.Ident); ok {
+			return &HandlerReference{
+				SimpleName:    e.Sel.Name,
+				QualifiedName: pkgIdent.Name + "." + e.Sel.Name,
+				Expr:          e,
+			}
+		}
+	case *ast.IndexExpr:
+		// Generic instantiation with a single type argument (e.g., Handle[MyEvent])
+		return genericHandlerReference(e, e.X, []ast.Expr{e.Index})
+	case *ast.IndexListExpr:
+		// Generic instantiation with multiple type arguments (e.g., Handle[MyEvent, MyResponse])
+		return genericHandlerReference(e, e.X, e.Indices)
+	case *ast.FuncLit:
+		// Function literal (e.g., func(ctx context.Context, e MyEvent) error { ... }), named once hoisted
+		return &HandlerReference{
+			SimpleName:    literalHandlerName,
+			QualifiedName: literalHandlerName,
+			Expr:          e,
+		}
+	case *ast.CallExpr:
+		// Decorator returning the handler (e.g., withAuth(handleRequest)), whose signature is its result type
+		handlerRef := handlerReferenceFromExpr(e.Fun)
+		if handlerRef == nil || handlerRef.Decorated {
+			return nil
+		}
+		handlerRef.QualifiedName = types.ExprString(e)
+		handlerRef.Expr = e
+		handlerRef.Decorated = true
+		return handlerRef
+	}
+	return nil
+}
+
+// genericHandlerReference creates the handler reference for an explicitly instantiated generic function
+func genericHandlerReference(expr, fun ast.Expr, typeArgs []ast.Expr) *HandlerReference {
+	handlerRef := handlerReferenceFromExpr(fun)
+	if handlerRef == nil || handlerRef.TypeArgs != nil {
+		return nil
+	}
+
+	handlerRef.QualifiedName = types.ExprString(expr)
+	handlerRef.TypeArgs = typeArgs
+	handlerRef.Expr = expr
+	return handlerRef
+}
+
+// literal returns the function literal passed as the handler, or nil if it isn't one
+func (h *HandlerReference) literal() *ast.FuncLit {
+	literal, _ := h.Expr.(*ast.FuncLit)
+	return literal
+}
+
+// funcExpr returns the expression referring to the handler function, or to the decorator of decorated
+// handlers, without type arguments
+func (h *HandlerReference) funcExpr() ast.Expr {
+	expr := h.Expr
+	if call, ok := expr.(*ast.CallExpr); ok && h.Decorated {
+		expr = call.Fun
+	}
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	return expr
+}
+
+// receiver returns the name of the variable declared in the file scope the handler is a method value of
+// (e.g., "svc" for svc.Handle), or an empty string for other handlers
+func (h *HandlerReference) receiver() string {
+	if ident := h.receiverIdent(); ident != nil && h.fileScope != nil && h.fileScope.Lookup(ident.Name) == ident.Obj {
+		return ident.Name
+	}
+	return ""
+}
+
+// localReceiver returns the name of the variable local to the registering function the handler is a method
+// value of (e.g., "app" for app.Handle after app := &App{}), or an empty string for other handlers
+func (h *HandlerReference) localReceiver() string {
+	if ident := h.receiverIdent(); ident != nil && h.receiver() == "" {
+		return ident.Name
+	}
+	return ""
+}
+
+// receiverIdent returns the identifier of the variable the handler is a method value of, or nil for other
+// handlers
+func (h *HandlerReference) receiverIdent() *ast.Ident {
+	if selExpr, ok := h.funcExpr().(*ast.SelectorExpr); ok {
+		if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Var {
+			return ident
+		}
+	}
+	return nil
+}
+
+// baseName returns the name generated declarations and files serving the handler are named after, the name of
+// the variable for lambda.Handler values (e.g., "app" for app.Invoke) and the simple name otherwise
+func (h *HandlerReference) baseName() string {
+	if receiver := h.receiver(); h.Invoked && receiver != "" {
+		return receiver
+	}
+	return h.SimpleName
+}
+
+// importPath returns the import path of the package the handler is selected from (e.g., the path of the
+// handler import for handler.HandleRequest), or an empty string for other handlers
+func (h *HandlerReference) importPath(file *ast.File) string {
+	if selExpr, ok := h.funcExpr().(*ast.SelectorExpr); ok {
+		if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj == nil {
+			return importPathForName(file, ident.Name)
+		}
+	}
+	return ""
+}
+
+// findLambdaHandler searches for lambda.Start() calls and returns the reference of the handler with the given
+// simple or qualified name. Without a name the first handler is used, warning if there are more to choose from
+// (e.g., when handlers are registered conditionally)
+func findLambdaHandler(r *reporter, file *ast.File, name string, pattern *regexp.Regexp, lambdaModule string, startFuncs []string) (*HandlerReference, error) {
+	handlerRefs, err := findMatchingHandlers(r, file, pattern, lambdaModule, startFuncs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(handlerRefs))
+	for i, handlerRef := range handlerRefs {
+		if name != "" && (handlerRef.QualifiedName == name || handlerRef.SimpleName == name) {
+			return handlerRef, nil
+		}
+		names[i] = handlerRef.QualifiedName
+	}
+
+	if name != "" {
+		return nil, fmt.Errorf("handler %s not found, detected handlers: %s", name, strings.Join(names, ", "))
+	}
+	if len(handlerRefs) > 1 {
+		r.warnf(handlerRefs[1].Expr.Pos(), "found %d lambda.Start() calls (%s), e.g. registered conditionally; migrating only %s, select another one with -handler", len(handlerRefs), strings.Join(names, ", "), names[0])
+	}
+	return handlerRefs[0], nil
+}
+
+// DefaultLegacyStartFuncs are the functions of the lambda package besides Start that older code registers its
+// handler with, passing it as their first argument
+var DefaultLegacyStartFuncs = []string{"Handle", "HandleFunction"}
+
+// startFuncHandlerArg returns the index of the argument the function of the lambda package takes the handler
+// as, and whether the handler is a value implementing lambda.Handler rather than a function. The start
+// functions are Start, StartWithOptions, StartWithContext, StartHandler, StartHandlerWithContext, and the
+// legacy start functions taking the handler as their first argument (DefaultLegacyStartFuncs if nil).
+func startFuncHandlerArg(name string, legacyStartFuncs []string) (index int, handlerValue bool, ok bool) {
+	if legacyStartFuncs == nil {
+		legacyStartFuncs = DefaultLegacyStartFuncs
+	}
+	switch {
+	case name == "Start" || name == "StartWithOptions" || slices.Contains(legacyStartFuncs, name):
+		return 0, false, true
+	case name == "StartWithContext":
+		return 1, false, true
+	case name == "StartHandler":
+		return 0, true, true
+	case name == "StartHandlerWithContext":
+		return 1, true, true
+	}
+	return 0, false, false
+}
+
+// startedHandlerReference creates the reference of the handler passed to a start function of the lambda
+// package, returning nil if it is not supported. Values implementing lambda.Handler are referred to by their
+// Invoke method, unless they wrap a handler function with lambda.NewHandler, which is unwrapped.
+func startedHandlerReference(file *ast.File, arg ast.Expr, handlerValue bool, lambdaModule string) *HandlerReference {
+	if !handlerValue {
+		return handlerReferenceFromExpr(arg)
+	}
+	if callExpr, ok := arg.(*ast.CallExpr); ok && len(callExpr.Args) > 0 {
+		switch qualifiedFuncName(file, callExpr.Fun) {
+		case lambdaModule + "/lambda.NewHandler", lambdaModule + "/lambda.NewHandlerWithOptions":
+			return handlerReferenceFromExpr(callExpr.Args[0])
+		}
+	}
+
+	// The method name is positioned after the value, within the start call
+	handlerRef := handlerReferenceFromExpr(&ast.SelectorExpr{X: arg, Sel: &ast.Ident{NamePos: arg.End(), Name: "Invoke"}})
+	if handlerRef != nil {
+		handlerRef.Invoked = true
+	}
+	return handlerRef
+}
+
+// isEmptyContext reports whether the expression is a context.Background() or context.TODO() call, whose
+// context carries no values and is never cancelled
+func isEmptyContext(file *ast.File, expr ast.Expr) bool {
+	callExpr, ok := expr.(*ast.CallExpr)
+	if !ok || len(callExpr.Args) != 0 {
+		return false
+	}
+	name := qualifiedFuncName(file, callExpr.Fun)
+	return name == "context.Background" || name == "context.TODO"
+}
+
+// findMatchingHandlers finds the Lambda handlers whose simple name matches the pattern, or all of them if it is nil
+func findMatchingHandlers(r *reporter, file *ast.File, pattern *regexp.Regexp, lambdaModule string, startFuncs []string) ([]*HandlerReference, error) {
+	handlerRefs, err := findLambdaHandlers(r, file, lambdaModule, startFuncs)
+	if err != nil || pattern == nil {
+		return handlerRefs, err
+	}
+
+	var matching []*HandlerReference
+	names := make([]string, len(handlerRefs))
+	for i, handlerRef := range handlerRefs {
+		if pattern.MatchString(handlerRef.SimpleName) {
+			matching = append(matching, handlerRef)
+		}
+		names[i] = handlerRef.QualifiedName
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no handler matches %s, detected handlers: %s: %w", pattern, strings.Join(names, ", "), ErrNoHandler)
+	}
+	return matching, nil
+}
+
+// findLambdaHandlers searches for all lambda.Start() calls in main and the init functions and returns their handler
+// references in source order. The lambda package is the one of the given AWS Lambda SDK module, whose other start
+// functions (e.g., StartWithContext) and legacy start functions taking the handler as their first argument (e.g.,
+// Handle) are recognized as well. Other calls of the package whose name suggests they start a handler are reported
+// for manual review.
+func findLambdaHandlers(r *reporter, file *ast.File, lambdaModule string, startFuncs []string) ([]*HandlerReference, error) {
+	var handlerRefs []*HandlerReference
+	var foundMain bool
+	literalNames := make(map[string]bool)
+
+	for _, decl := range file.Decls {
+		// Look for the main function, or init functions registering the handler at initialization
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || (fn.Name.Name != "main" && fn.Name.Name != "init") || fn.Body == nil {
+			continue
+		}
+		foundMain = foundMain || fn.Name.Name == "main"
+
+		// Look for lambda.Start() calls within the function
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if callExpr, ok := n.(*ast.CallExpr); ok {
+				if selExpr, ok := callExpr.Fun.(*ast.SelectorExpr); ok {
+					// Check if it's a call to lambda.Start, whatever name the lambda package is imported under
+					if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj == nil && importPathForName(file, ident.Name) == lambdaModule+"/lambda" {
+						if index, handlerValue, ok := startFuncHandlerArg(selExpr.Sel.Name, startFuncs); ok {
+							// Extract the handler reference
+							if len(callExpr.Args) > index {
+								if handlerRef := startedHandlerReference(file, callExpr.Args[index], handlerValue, lambdaModule); handlerRef != nil {
+									handlerRef.registrar, handlerRef.fileScope = fn, file.Scope
+									if handlerRef.literal() != nil {
+										handlerRef.SimpleName = unusedLiteralHandlerName(file, literalNames)
+										handlerRef.QualifiedName = handlerRef.SimpleName
+									}
+									if index > 0 {
+										handlerRef.startCtx = callExpr.Args[0]
+									}
+									handlerRefs = append(handlerRefs, handlerRef)
+									return false
+								}
+							}
+						} else if strings.Contains(selExpr.Sel.Name, "Start") || strings.Contains(selExpr.Sel.Name, "Handle") {
+							r.warnf(callExpr.Pos(), "%s.%s may start a Lambda handler but isn't recognized, review it manually or add it with -legacy-start-func if it takes the handler as its first argument", ident.Name, selExpr.Sel.Name)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	if len(handlerRefs) == 0 && !foundMain {
+		return nil, fmt.Errorf("main function not found: %w", ErrNoHandler)
+	}
+
+	if len(handlerRefs) == 0 {
+		return nil, fmt.Errorf("lambda.Start() call not found in main or init functions: %w", ErrNoHandler)
+	}
+
+	return handlerRefs, nil
+}
+
+// removedFuncs returns the functions the transformation removes: the one registering the handler, and main
+// if that is an init function, as lambda.Start never returns and main never ran
+func (h *HandlerReference) removedFuncs(file *ast.File) []*ast.FuncDecl {
+	removed := []*ast.FuncDecl{h.registrar}
+	if main := findMainFunc(file); main != nil && main != h.registrar {
+		removed = append(removed, main)
+	}
+	return removed
+}
@@ -0,0 +1,59 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// createResponseHeaderStmts creates the statements setting the static response headers, adding the
+// further values of repeated ones:
+//
+//	w.Header().Set("Cache-Control", "no-store")
+//	w.Header().Add("Cache-Control", "private")
+func createResponseHeaderStmts(headers http.Header) []ast.Stmt {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var stmts []ast.Stmt
+	for _, name := range names {
+		for i, value := range headers[name] {
+			method := "Add"
+			if i == 0 {
+				method = "Set"
+			}
+			stmts = append(stmts, &ast.ExprStmt{
+				X: &ast.CallExpr{
+					Fun: &ast.SelectorExpr{
+						X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")}},
+						Sel: ast.NewIdent(method),
+					},
+					Args: []ast.Expr{
+						&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name)},
+						&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(value)},
+					},
+				},
+			})
+		}
+	}
+	return stmts
+}
+
+// isHeaderName reports whether name is a valid header field name, i.e. a non-empty HTTP token
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
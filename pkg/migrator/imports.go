@@ -0,0 +1,445 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errImportConflict is returned when the source uses the name of a package the generated code imports for
+// another package or a declaration, unless the import is renamed
+var errImportConflict = errors.New("the names of imports the generated code needs are taken")
+
+// removeLambdaImport removes the imports of the lambda package of the AWS Lambda SDK module. Its other packages,
+// e.g. events, are removed along with the other imports only main referred to.
+func removeLambdaImport(file *ast.File, lambdaModule string) {
+	removeImports(file, func(importSpec *ast.ImportSpec) bool {
+		return isRemovedLambdaImport(strings.Trim(importSpec.Path.Value, `"`), lambdaModule)
+	})
+}
+
+// removeUnusedImports removes the imports whose name was referenced before the transformation but no longer
+// is, e.g. fmt when only main used it, and returns their paths. Imports whose name wasn't referenced before are
+// kept, as their package may be declared under another name than assumed.
+func removeUnusedImports(file *ast.File, refsBefore, refsAfter map[string]bool) []string {
+	var removed []string
+	removeImports(file, func(importSpec *ast.ImportSpec) bool {
+		if isUnusedImport(importSpec, refsBefore, refsAfter) {
+			removed = append(removed, strings.Trim(importSpec.Path.Value, `"`))
+			return true
+		}
+		return false
+	})
+	return removed
+}
+
+// isUnusedImport reports whether the import's name was referenced before the transformation but no longer is.
+// Dot and blank imports are never unused, as they aren't referenced by name.
+func isUnusedImport(importSpec *ast.ImportSpec, refsBefore, refsAfter map[string]bool) bool {
+	name := importName(importSpec)
+	return name != "." && name != "_" && refsBefore[name] && !refsAfter[name]
+}
+
+// removeImports removes the imports matching remove, and the import declarations left empty
+func removeImports(file *ast.File, remove func(*ast.ImportSpec) bool) {
+	var decls []ast.Decl
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			var newSpecs []ast.Spec
+			for _, spec := range genDecl.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok && !remove(importSpec) {
+					newSpecs = append(newSpecs, spec)
+				}
+			}
+			if len(newSpecs) == 0 {
+				// Remove the entire import declaration if empty
+				continue
+			}
+			genDecl.Specs = newSpecs
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+}
+
+// packageRefs returns the names qualifying identifiers in the nodes, i.e. the names imports are referenced by.
+// Selectors on identifiers resolved to local declarations (e.g., a variable's fields) are skipped.
+func packageRefs(nodes ...ast.Node) map[string]bool {
+	refs := make(map[string]bool)
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if selExpr, ok := n.(*ast.SelectorExpr); ok {
+				if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj == nil {
+					refs[ident.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	return refs
+}
+
+// lambdaModulePath is the module path of the AWS Lambda SDK
+const lambdaModulePath = "github.com/aws/aws-lambda-go"
+
+// lambdaModule returns the module path of the AWS Lambda SDK
+func (o *GenerateOptions) lambdaModule() string {
+	if o.LambdaModule == "" {
+		return lambdaModulePath
+	}
+	return o.LambdaModule
+}
+
+// isRemovedLambdaImport reports whether the import path is the one of the lambda package of the AWS Lambda SDK
+// module, which the migration removes regardless of its uses. The other packages of the module are kept as long
+// as they are referenced, e.g. events for the handler's input and output types.
+func isRemovedLambdaImport(importPath, lambdaModule string) bool {
+	return importPath == lambdaModule+"/lambda"
+}
+
+// assumedPackageName returns the name a package imported without an alias is assumed to be declared with:
+// the last element of its import path without a major version suffix and go- prefix, up to the first
+// character not valid in identifiers (e.g., "yaml" for gopkg.in/yaml.v3, "redis" for .../go-redis/redis/v9)
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(importPath) != "." {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// requireTypeImports marks the imports of the packages referenced by the type expression as needed
+func requireTypeImports(imports map[string]*importInfo, handlerSig *HandlerSignature, typeExpr ast.Expr) {
+	for _, name := range typeExprPackages(typeExpr) {
+		if importPath, ok := handlerSig.TypeImports[name]; ok {
+			if info, ok := imports[importPath]; ok {
+				info.needed = true
+			} else {
+				imports[importPath] = &importInfo{path: importPath, alias: name, needed: true}
+			}
+		}
+	}
+}
+
+// importInfo holds information about a required import
+type importInfo struct {
+	path      string
+	alias     string
+	hasImport bool
+	needed    bool
+}
+
+// checkImport checks if an import exists and captures its alias. Dot and blank imports don't count, as the
+// generated code can't qualify names with them, so the package is imported again under its name.
+func checkImport(importSpec *ast.ImportSpec, info *importInfo) {
+	importPath := strings.Trim(importSpec.Path.Value, `"`)
+	if importSpec.Name != nil && (importSpec.Name.Name == "." || importSpec.Name.Name == "_") {
+		return
+	}
+	if importPath == info.path {
+		info.hasImport = true
+		if importSpec.Name != nil {
+			info.alias = importSpec.Name.Name
+		}
+	}
+}
+
+// createImportSpec creates an import spec from the import info, naming the import if the alias differs from
+// the package name
+func createImportSpec(path, alias string) *ast.ImportSpec {
+	importSpec := &ast.ImportSpec{
+		Path: &ast.BasicLit{Kind: token.STRING, Value: `"` + path + `"`},
+	}
+	if alias != assumedPackageName(path) {
+		importSpec.Name = ast.NewIdent(alias)
+	}
+	return importSpec
+}
+
+// fileScopeNames returns the names declared in the file scope or the package block other than by imports of
+// the given path, i.e. the names an import of that path added to the file can't be referenced by
+func fileScopeNames(file *ast.File, importPath string) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.ImportSpec:
+					if name := importName(s); strings.Trim(s.Path.Value, `"`) != importPath && name != "." && name != "_" {
+						names[name] = true
+					}
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, ident := range s.Names {
+						names[ident.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// conventionalImportNames are the names packages the generated code imports are conventionally imported under,
+// for those whose package name isn't descriptive (e.g., v2 for the CloudEvents SDK)
+var conventionalImportNames = map[string]string{
+	cloudEventsPkgPath: "cloudevents",
+}
+
+// generatedImportName returns the name the generated code imports the package under if the file doesn't use it, its
+// conventional name if it has one and its assumed package name otherwise
+func generatedImportName(importPath string) string {
+	if name, ok := conventionalImportNames[importPath]; ok {
+		return name
+	}
+	return assumedPackageName(importPath)
+}
+
+// importAlias returns the name to import the package under: its name, unless the file uses it for another
+// package or a declaration, in which case standard library packages are prefixed with std (e.g., stdjson)
+// and others get the lowest numeric suffix not in use
+func importAlias(file *ast.File, importPath string) string {
+	name := generatedImportName(importPath)
+	taken := fileScopeNames(file, importPath)
+	if !taken[name] {
+		return name
+	}
+	if alias := "std" + name; isStdlibImport(importPath) && !taken[alias] {
+		return alias
+	}
+	for i := 2; ; i++ {
+		if alias := name + strconv.Itoa(i); !taken[alias] {
+			return alias
+		}
+	}
+}
+
+// importConflicts returns the imports to add whose package name the file already uses for another package or
+// a declaration, described as the import path followed by the name (e.g., "encoding/json" as json)
+func importConflicts(file *ast.File, importPaths []string) []string {
+	var conflicts []string
+	for _, importPath := range importPaths {
+		if name := generatedImportName(importPath); fileScopeNames(file, importPath)[name] {
+			conflicts = append(conflicts, fmt.Sprintf("%q as %s", importPath, name))
+		}
+	}
+	slices.Sort(conflicts)
+	return conflicts
+}
+
+// addImport adds a single import if not present and returns the name to reference the package by
+func addImport(file *ast.File, importPath string) string {
+	info := &importInfo{path: importPath, alias: importAlias(file, importPath)}
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			for _, spec := range genDecl.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok {
+					checkImport(importSpec, info)
+				}
+			}
+		}
+	}
+	if info.hasImport {
+		return info.alias
+	}
+
+	// Try to add to existing import declaration, otherwise create a new one
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			genDecl.Specs = append(genDecl.Specs, createImportSpec(importPath, info.alias))
+			return info.alias
+		}
+	}
+	newImport := &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{createImportSpec(importPath, info.alias)}}
+	file.Decls = append([]ast.Decl{newImport}, file.Decls...)
+	return info.alias
+}
+
+// addRequiredImports adds required imports based on handler signature
+// Returns the package names/aliases to use for the needed imports, keyed by import path
+func addRequiredImports(file *ast.File, handlerSig *HandlerSignature, opts *GenerateOptions) map[string]string {
+	imports := planRequiredImports(file, handlerSig, opts)
+
+	// Collect missing imports that are needed
+	var missingImports []*importInfo
+	for _, info := range imports {
+		if info.needed && !info.hasImport {
+			missingImports = append(missingImports, info)
+		}
+	}
+
+	// Add missing imports
+	if len(missingImports) > 0 {
+		// Try to add to existing import declaration
+		for i, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+				for _, info := range missingImports {
+					genDecl.Specs = append(genDecl.Specs, createImportSpec(info.path, info.alias))
+				}
+				file.Decls[i] = genDecl
+				return importAliases(imports)
+			}
+		}
+
+		// If no import declaration exists, create one with all needed imports
+		var specs []ast.Spec
+		for _, info := range imports {
+			if info.needed {
+				specs = append(specs, createImportSpec(info.path, info.alias))
+			}
+		}
+		newImport := &ast.GenDecl{Tok: token.IMPORT, Specs: specs}
+		file.Decls = append([]ast.Decl{newImport}, file.Decls...)
+	}
+
+	return importAliases(imports)
+}
+
+// anchorImports positions the imports added to the file at its last original import, moving those of standard
+// library packages after its last original standard library import and positioning them there instead. The
+// printer otherwise advances past the comments following the imports while printing the added ones and moves
+// them into the import declaration, and formatSource would sort standard library imports appended to the
+// third-party ones into a group of their own among them.
+func anchorImports(file *ast.File) {
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		specs := make([]ast.Spec, 0, len(genDecl.Specs))
+		var anchor, stdlibAnchor token.Pos
+		stdlibEnd := 0
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			importPath, _ := strconv.Unquote(importSpec.Path.Value)
+			if importSpec.Path.ValuePos.IsValid() {
+				anchor = importSpec.Path.ValuePos
+				if isStdlibImport(importPath) {
+					stdlibAnchor, stdlibEnd = anchor, len(specs)+1
+				}
+				specs = append(specs, spec)
+				continue
+			}
+			pos := anchor
+			if isStdlibImport(importPath) && stdlibAnchor.IsValid() {
+				pos = stdlibAnchor
+				specs = slices.Insert(specs, stdlibEnd, spec)
+				stdlibEnd++
+			} else {
+				specs = append(specs, spec)
+			}
+			importSpec.Path.ValuePos = pos
+			if importSpec.Name != nil {
+				importSpec.Name.NamePos = pos
+			}
+		}
+		genDecl.Specs = specs
+	}
+}
+
+// importAliases returns the package names/aliases of the given imports, keyed by import path
+func importAliases(imports map[string]*importInfo) map[string]string {
+	aliases := make(map[string]string, len(imports))
+	for path, info := range imports {
+		aliases[path] = info.alias
+	}
+	return aliases
+}
+
+// planRequiredImports determines the imports needed by the generated code, keyed by import path,
+// and captures the aliases of those already present without modifying the file
+func planRequiredImports(file *ast.File, handlerSig *HandlerSignature, opts *GenerateOptions) map[string]*importInfo {
+	cloudFrontInput := handlerSig.HasInput && handlerSig.InputTypeID == cloudFrontRequestID
+	cloudFrontOutput := handlerSig.OutputTypeID == cloudFrontResponseID
+	apiGatewayInput := mapsAPIGatewayRequest(handlerSig)
+	apiGatewayOutput := mapsAPIGatewayResponse(handlerSig)
+	cloudEvents := opts.Style == StyleCloudEvents
+	readBody := handlerSig.HasInput && !cloudFrontInput && !cloudEvents
+	negotiateInput := readBody && opts.InputSource == InputSourceAuto
+	poolBuffers := readBody && opts.PoolBuffers
+	protoInput := decodesProtoInput(handlerSig, opts)
+	protoOutput := encodesProtoOutput(handlerSig, opts)
+	decodeInput := decodesInput(handlerSig, opts)
+	streamOutput := streamsOutput(handlerSig)
+
+	// Define required imports
+	imports := map[string]*importInfo{
+		"context":            {path: "context", alias: "context", needed: true},
+		"net/http":           {path: "net/http", alias: "http", needed: !cloudEvents},
+		"io":                 {path: "io", alias: "io", needed: (readBody && !poolBuffers) || streamOutput},
+		"encoding/json":      {path: "encoding/json", alias: "json", needed: (handlerSig.HasOutput && !cloudEvents && !cloudFrontOutput && !apiGatewayOutput && !protoOutput && !streamOutput && !handlerSig.RawOutput) || negotiateInput || (decodeInput && !cloudEvents)},
+		"log":                {path: "log", alias: "log", needed: (handlerSig.HasError && !cloudEvents) || handlerSig.EnvelopeErrField != "" || opts.Recover || streamOutput || apiGatewayOutput || handlerSig.LoggerTypeID == logLoggerID},
+		"log/slog":           {path: "log/slog", alias: "slog", needed: handlerSig.LoggerTypeID == slogLoggerID || opts.InjectLogger},
+		"runtime/debug":      {path: "runtime/debug", alias: "debug", needed: opts.Recover && !opts.NoStack},
+		"os":                 {path: "os", alias: "os", needed: len(configEnvVars(file, opts)) > 0},
+		"time":               {path: "time", alias: "time", needed: opts.Timeout > 0},
+		"sync/atomic":        {path: "sync/atomic", alias: "atomic", needed: opts.Readyz},
+		"mime":               {path: "mime", alias: "mime", needed: negotiateInput},
+		"bytes":              {path: "bytes", alias: "bytes", needed: poolBuffers || (decodeInput && usesJSONDecoder(opts))},
+		"sync":               {path: "sync", alias: "sync", needed: poolBuffers},
+		"net":                {path: "net", alias: "net", needed: cloudFrontInput},
+		"strings":            {path: "strings", alias: "strings", needed: cloudFrontInput},
+		"strconv":            {path: "strconv", alias: "strconv", needed: cloudFrontOutput},
+		"encoding/base64":    {path: "encoding/base64", alias: "base64", needed: apiGatewayOutput},
+		eventsPkgPath:        {path: eventsPkgPath, alias: "events", needed: cloudFrontInput || apiGatewayInput},
+		cloudEventsPkgPath:   {path: cloudEventsPkgPath, alias: "cloudevents", needed: cloudEvents},
+		uuidPkgPath:          {path: uuidPkgPath, alias: "uuid", needed: cloudEvents && handlerSig.HasOutput},
+		protojsonPkgPath:     {path: protojsonPkgPath, alias: "protojson", needed: protoInput || protoOutput},
+		otelPkgPath:          {path: otelPkgPath, alias: "otel", needed: opts.Metrics},
+		otelMetricPkgPath:    {path: otelMetricPkgPath, alias: "metric", needed: opts.Metrics},
+		otelAttributePkgPath: {path: otelAttributePkgPath, alias: "attribute", needed: opts.Metrics},
+	}
+
+	// The decoded protobuf, pointer, standard library, and untyped inputs, empty map and pointer collection outputs, and outputs of handlers wrapped with a timeout
+	// are declared by their types, whose packages may not be imported yet
+	if protoInput || decodeInput {
+		requireTypeImports(imports, handlerSig, handlerSig.InputTypeExpr)
+	}
+	if emptyMapOutput(handlerSig, opts) || pointerCollectionOutput(handlerSig, opts) || (opts.Timeout > 0 && handlerSig.HasOutput) {
+		requireTypeImports(imports, handlerSig, handlerSig.OutputTypeExpr)
+	}
+
+	// Check existing imports and capture aliases
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			for _, spec := range genDecl.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok {
+					for _, info := range imports {
+						checkImport(importSpec, info)
+					}
+				}
+			}
+		}
+	}
+
+	// The imports to add are renamed if their name is taken (see importConflicts), except those of the handler
+	// types, whose expressions refer to them by name
+	for _, info := range imports {
+		if info.needed && !info.hasImport && !slices.Contains(slices.Collect(maps.Values(handlerSig.TypeImports)), info.path) {
+			info.alias = importAlias(file, info.path)
+		}
+	}
+
+	return imports
+}
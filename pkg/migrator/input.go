@@ -0,0 +1,555 @@
+package migrator
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+const (
+	// InputSourceBody passes the raw request body to the handler
+	InputSourceBody = "body"
+	// InputSourceAuto negotiates between JSON and form data based on the Content-Type header
+	InputSourceAuto = "auto"
+	// InputSourceMultipart passes the content of a file uploaded as multipart/form-data
+	InputSourceMultipart = "multipart"
+)
+
+// maxMultipartMemory is the number of bytes of a multipart form kept in memory, the rest is stored on disk. It
+// is a whole number of MiB, generated as such (e.g., 32 << 20).
+const maxMultipartMemory = 32 << 20
+
+// bufferPoolName is the name of the generated package-level pool of body buffers
+const bufferPoolName = "bodyBufferPool"
+
+// createReadBodyStmts creates the statements declaring the handler input as body
+func createReadBodyStmts(aliases map[string]string, opts *GenerateOptions) []ast.Stmt {
+	ioAlias := aliases["io"]
+	var stmts []ast.Stmt
+	if opts.PoolBuffers {
+		stmts = append(stmts, createAcquireBufferStmts(aliases["bytes"])...)
+	}
+
+	switch opts.InputSource {
+	case InputSourceAuto:
+		return append(stmts, createNegotiateBodyStmts(aliases, opts)...)
+	case InputSourceMultipart:
+		stmts = append(stmts, createOpenFormFileStmts(opts.FileField)...)
+		return append(stmts, createReadAllStmts(token.DEFINE, ast.NewIdent("file"), ioAlias, opts)...)
+	}
+	return append(stmts, createReadAllStmts(token.DEFINE, requestBody(), ioAlias, opts)...)
+}
+
+// requestBody returns the r.Body expression
+func requestBody() ast.Expr {
+	return &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Body")}
+}
+
+// createReadAllStmts creates the statements reading the whole src reader into body, either
+// through a pooled buffer or with io.ReadAll(src)
+func createReadAllStmts(tok token.Token, src ast.Expr, ioAlias string, opts *GenerateOptions) []ast.Stmt {
+	if opts.PoolBuffers {
+		// buf.ReadFrom(src)
+		// body := buf.Bytes()
+		return []ast.Stmt{
+			&ast.ExprStmt{
+				X: &ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent("buf"), Sel: ast.NewIdent("ReadFrom")},
+					Args: []ast.Expr{src},
+				},
+			},
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("body")},
+				Tok: tok,
+				Rhs: []ast.Expr{
+					&ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("buf"), Sel: ast.NewIdent("Bytes")}},
+				},
+			},
+		}
+	}
+
+	// body, _ := io.ReadAll(src)
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("body"), ast.NewIdent("_")},
+			Tok: tok,
+			Rhs: []ast.Expr{createReadAllCall(src, ioAlias)},
+		},
+	}
+}
+
+// createAcquireBufferStmts creates the statements borrowing a reset buffer from the pool, which is
+// returned by a deferred call so that early returns give it back as well:
+//
+//	buf := bodyBufferPool.Get().(*bytes.Buffer)
+//	buf.Reset()
+//	defer bodyBufferPool.Put(buf)
+func createAcquireBufferStmts(bytesAlias string) []ast.Stmt {
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("buf")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.TypeAssertExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent(bufferPoolName), Sel: ast.NewIdent("Get")},
+					},
+					Type: &ast.StarExpr{X: &ast.SelectorExpr{X: ast.NewIdent(bytesAlias), Sel: ast.NewIdent("Buffer")}},
+				},
+			},
+		},
+		&ast.ExprStmt{
+			X: &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("buf"), Sel: ast.NewIdent("Reset")}},
+		},
+		&ast.DeferStmt{
+			Call: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(bufferPoolName), Sel: ast.NewIdent("Put")},
+				Args: []ast.Expr{ast.NewIdent("buf")},
+			},
+		},
+	}
+}
+
+// errInterfaceInput is returned for handlers taking an interface input without a concrete type to decode the
+// request body into, as JSON can only decode into interfaces without methods
+var errInterfaceInput = errors.New("JSON can't decode the request body into an interface, give the concrete type implementing it to decode into (see -input-type)")
+
+// isInterfaceInput reports whether the type-checked input is an interface with methods, unlike any
+func isInterfaceInput(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	return ok && !iface.Empty()
+}
+
+// declaresInterface reports whether the input type expression is an interface with methods, either literal or
+// declared in the file
+func declaresInterface(expr ast.Expr) bool {
+	if ident, ok := expr.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Typ {
+		if typeSpec, ok := ident.Obj.Decl.(*ast.TypeSpec); ok {
+			expr = typeSpec.Type
+		}
+	}
+	iface, ok := expr.(*ast.InterfaceType)
+	return ok && iface.Methods.NumFields() > 0
+}
+
+// decodesConcreteInput reports whether the request body is decoded into the concrete type given for an
+// interface input, which is passed to the handler
+func decodesConcreteInput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return handlerSig.HasInput && handlerSig.InputIsInterface && opts.InputType != ""
+}
+
+// decodesPointerInput reports whether the request body is decoded into the value pointed to by the input
+func decodesPointerInput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	_, ok := handlerSig.InputTypeExpr.(*ast.StarExpr)
+	return handlerSig.HasInput && handlerSig.InputIsPointer && ok && !decodesProtoInput(handlerSig, opts) && !mapsAPIGatewayRequest(handlerSig)
+}
+
+// decodesValueInput reports whether the request body is decoded into an input passed by value (e.g., OrderEvent
+// or map[string]any), which is every input but the byte slices the body is passed as and the CloudFront and API
+// Gateway proxy requests mapped from the request
+func decodesValueInput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	if !handlerSig.HasInput || handlerSig.InputIsPointer || handlerSig.InputIsInterface || handlerSig.InputTypeExpr == nil || decodesProtoInput(handlerSig, opts) ||
+		handlerSig.InputTypeID == cloudFrontRequestID || mapsAPIGatewayRequest(handlerSig) {
+		return false
+	}
+
+	// Byte slice types declared in other packages can only be recognized with type information, apart from
+	// json.RawMessage
+	if handlerSig.inputType != nil {
+		return !isByteSlice(handlerSig.inputType)
+	}
+	return handlerSig.InputTypeID != "encoding/json.RawMessage" && !isByteSliceExpr(handlerSig.InputTypeExpr)
+}
+
+// isByteSliceExpr reports whether the type expression is a byte slice, either literal or declared in the file
+// (e.g., type Payload []byte)
+func isByteSliceExpr(expr ast.Expr) bool {
+	if ident, ok := expr.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Typ {
+		if typeSpec, ok := ident.Obj.Decl.(*ast.TypeSpec); ok {
+			return isByteSliceExpr(typeSpec.Type)
+		}
+	}
+	slice, ok := expr.(*ast.ArrayType)
+	if !ok || slice.Len != nil {
+		return false
+	}
+	elt, ok := slice.Elt.(*ast.Ident)
+	return ok && elt.Obj == nil && (elt.Name == "byte" || elt.Name == "uint8")
+}
+
+// decodesInput reports whether the request body is decoded into the handler input with encoding/json
+func decodesInput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return decodesConcreteInput(handlerSig, opts) || decodesPointerInput(handlerSig, opts) || decodesValueInput(handlerSig, opts)
+}
+
+// usesJSONDecoder reports whether the input is decoded with a configured json.Decoder instead of json.Unmarshal
+func usesJSONDecoder(opts *GenerateOptions) bool {
+	return opts.JSONNumbers || opts.StrictJSON
+}
+
+// createDecodeInputStmts creates the statements decoding the JSON request body into a value of the given type,
+// which is passed to the handler, or its address for pointer inputs:
+//
+//	var input MyEvent
+//	if err := json.Unmarshal(body, &input); err != nil {
+//		w.WriteHeader(400)
+//		return
+//	}
+//
+// With JSONNumbers, numbers decoded into untyped values (e.g., of a map[string]any) are json.Number instead of
+// float64, and with StrictJSON, fields the input struct doesn't declare are answered with 400:
+//
+//	var input MyEvent
+//	dec := json.NewDecoder(bytes.NewReader(body))
+//	dec.UseNumber()
+//	dec.DisallowUnknownFields()
+//	if err := dec.Decode(&input); err != nil {
+//		w.WriteHeader(400)
+//		return
+//	}
+func createDecodeInputStmts(valueType ast.Expr, aliases map[string]string, opts *GenerateOptions) []ast.Stmt {
+	stmts := []ast.Stmt{
+		&ast.DeclStmt{
+			Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{
+					&ast.ValueSpec{
+						Names: []*ast.Ident{ast.NewIdent("input")},
+						Type:  copyExpr(valueType),
+					},
+				},
+			},
+		},
+	}
+
+	jsonAlias := aliases["encoding/json"]
+	decode := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: ast.NewIdent(jsonAlias), Sel: ast.NewIdent("Unmarshal")},
+		Args: []ast.Expr{ast.NewIdent("body"), &ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("input")}},
+	}
+	if usesJSONDecoder(opts) {
+		stmts = append(stmts, &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("dec")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent(jsonAlias), Sel: ast.NewIdent("NewDecoder")},
+				Args: []ast.Expr{&ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent(aliases["bytes"]), Sel: ast.NewIdent("NewReader")},
+					Args: []ast.Expr{ast.NewIdent("body")},
+				}},
+			}},
+		})
+		configure := func(method string) ast.Stmt {
+			return &ast.ExprStmt{X: &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("dec"), Sel: ast.NewIdent(method)}}}
+		}
+		if opts.JSONNumbers {
+			stmts = append(stmts, configure("UseNumber"))
+		}
+		if opts.StrictJSON {
+			stmts = append(stmts, configure("DisallowUnknownFields"))
+		}
+		decode = &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent("dec"), Sel: ast.NewIdent("Decode")},
+			Args: []ast.Expr{&ast.UnaryExpr{Op: token.AND, X: ast.NewIdent("input")}},
+		}
+	}
+
+	return append(stmts, &ast.IfStmt{
+		Init: &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{decode},
+		},
+		Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+		Body: &ast.BlockStmt{List: createWriteStatusStmts(400)},
+	})
+}
+
+// poolsBuffers reports whether the request body of the handler is read into a pooled buffer
+func poolsBuffers(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return handlerSig.HasInput && handlerSig.InputTypeID != cloudFrontRequestID && opts.PoolBuffers
+}
+
+// createBufferPoolDecl creates the package-level pool of body buffers:
+//
+//	var bodyBufferPool = sync.Pool{
+//		New: func() any {
+//			return new(bytes.Buffer)
+//		},
+//	}
+func createBufferPoolDecl(syncAlias, bytesAlias string) *ast.GenDecl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names: []*ast.Ident{ast.NewIdent(bufferPoolName)},
+				Values: []ast.Expr{
+					&ast.CompositeLit{
+						Type: &ast.SelectorExpr{X: ast.NewIdent(syncAlias), Sel: ast.NewIdent("Pool")},
+						Elts: []ast.Expr{
+							&ast.KeyValueExpr{
+								Key: ast.NewIdent("New"),
+								Value: &ast.FuncLit{
+									Type: &ast.FuncType{
+										Params:  &ast.FieldList{},
+										Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("any")}}},
+									},
+									Body: &ast.BlockStmt{
+										List: []ast.Stmt{
+											&ast.ReturnStmt{
+												Results: []ast.Expr{
+													&ast.CallExpr{
+														Fun: ast.NewIdent("new"),
+														Args: []ast.Expr{
+															&ast.SelectorExpr{X: ast.NewIdent(bytesAlias), Sel: ast.NewIdent("Buffer")},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// createReadAllCall creates the io.ReadAll(src) call
+func createReadAllCall(src ast.Expr, ioAlias string) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   ast.NewIdent(ioAlias),
+			Sel: ast.NewIdent("ReadAll"),
+		},
+		Args: []ast.Expr{src},
+	}
+}
+
+// createOpenFormFileStmts creates the statements opening the file uploaded in the given multipart form field:
+//
+//	if err := r.ParseMultipartForm(32 << 20); err != nil {
+//		w.WriteHeader(400)
+//		return
+//	}
+//	file, _, fileErr := r.FormFile("field")
+//	if fileErr != nil {
+//		w.WriteHeader(400)
+//		return
+//	}
+//	defer file.Close()
+func createOpenFormFileStmts(field string) []ast.Stmt {
+	return []ast.Stmt{
+		&ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("err")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{
+					&ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("ParseMultipartForm")},
+						Args: []ast.Expr{&ast.BinaryExpr{
+							X:  &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(maxMultipartMemory >> 20)},
+							Op: token.SHL,
+							Y:  &ast.BasicLit{Kind: token.INT, Value: "20"},
+						}},
+					},
+				},
+			},
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(400)},
+		},
+		// The error gets its own name to not clash with the error returned by the handler
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("file"), ast.NewIdent("_"), ast.NewIdent("fileErr")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("FormFile")},
+					Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(field)}},
+				},
+			},
+		},
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("fileErr"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(400)},
+		},
+		&ast.DeferStmt{
+			Call: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent("file"), Sel: ast.NewIdent("Close")},
+			},
+		},
+	}
+}
+
+// createNegotiateBodyStmts creates a switch on the request media type passing JSON bodies through as-is and
+// converting form data into a JSON object, so both end up in the same input struct:
+//
+//	var body []byte
+//	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+//	case "", "application/json":
+//		body, _ = io.ReadAll(r.Body)
+//	case "application/x-www-form-urlencoded":
+//		if err := r.ParseForm(); err != nil {
+//			w.WriteHeader(400)
+//			return
+//		}
+//		form := make(map[string]string, len(r.PostForm))
+//		for key := range r.PostForm {
+//			form[key] = r.PostForm.Get(key)
+//		}
+//		body, _ = json.Marshal(form)
+//	default:
+//		w.WriteHeader(415)
+//		return
+//	}
+func createNegotiateBodyStmts(aliases map[string]string, opts *GenerateOptions) []ast.Stmt {
+	ioAlias := aliases["io"]
+	postForm := &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("PostForm")}
+
+	return []ast.Stmt{
+		&ast.DeclStmt{
+			Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{
+					&ast.ValueSpec{
+						Names: []*ast.Ident{ast.NewIdent("body")},
+						Type:  &ast.ArrayType{Elt: ast.NewIdent("byte")},
+					},
+				},
+			},
+		},
+		&ast.SwitchStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("mediaType"), ast.NewIdent("_"), ast.NewIdent("_")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{
+					&ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent(aliases["mime"]), Sel: ast.NewIdent("ParseMediaType")},
+						Args: []ast.Expr{
+							&ast.CallExpr{
+								Fun: &ast.SelectorExpr{
+									X:   &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Header")},
+									Sel: ast.NewIdent("Get"),
+								},
+								Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: `"Content-Type"`}},
+							},
+						},
+					},
+				},
+			},
+			Tag: ast.NewIdent("mediaType"),
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.CaseClause{
+						List: []ast.Expr{
+							&ast.BasicLit{Kind: token.STRING, Value: `""`},
+							&ast.BasicLit{Kind: token.STRING, Value: `"application/json"`},
+						},
+						Body: createReadAllStmts(token.ASSIGN, requestBody(), ioAlias, opts),
+					},
+					&ast.CaseClause{
+						List: []ast.Expr{
+							&ast.BasicLit{Kind: token.STRING, Value: `"application/x-www-form-urlencoded"`},
+						},
+						Body: []ast.Stmt{
+							&ast.IfStmt{
+								Init: &ast.AssignStmt{
+									Lhs: []ast.Expr{ast.NewIdent("err")},
+									Tok: token.DEFINE,
+									Rhs: []ast.Expr{
+										&ast.CallExpr{
+											Fun: &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("ParseForm")},
+										},
+									},
+								},
+								Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+								Body: &ast.BlockStmt{List: createWriteStatusStmts(400)},
+							},
+							&ast.AssignStmt{
+								Lhs: []ast.Expr{ast.NewIdent("form")},
+								Tok: token.DEFINE,
+								Rhs: []ast.Expr{
+									&ast.CallExpr{
+										Fun: ast.NewIdent("make"),
+										Args: []ast.Expr{
+											&ast.MapType{Key: ast.NewIdent("string"), Value: ast.NewIdent("string")},
+											&ast.CallExpr{Fun: ast.NewIdent("len"), Args: []ast.Expr{postForm}},
+										},
+									},
+								},
+							},
+							&ast.RangeStmt{
+								Key: ast.NewIdent("key"),
+								Tok: token.DEFINE,
+								X:   postForm,
+								Body: &ast.BlockStmt{
+									List: []ast.Stmt{
+										&ast.AssignStmt{
+											Lhs: []ast.Expr{&ast.IndexExpr{X: ast.NewIdent("form"), Index: ast.NewIdent("key")}},
+											Tok: token.ASSIGN,
+											Rhs: []ast.Expr{
+												&ast.CallExpr{
+													Fun:  &ast.SelectorExpr{X: postForm, Sel: ast.NewIdent("Get")},
+													Args: []ast.Expr{ast.NewIdent("key")},
+												},
+											},
+										},
+									},
+								},
+							},
+							&ast.AssignStmt{
+								Lhs: []ast.Expr{ast.NewIdent("body"), ast.NewIdent("_")},
+								Tok: token.ASSIGN,
+								Rhs: []ast.Expr{
+									&ast.CallExpr{
+										Fun:  &ast.SelectorExpr{X: ast.NewIdent(aliases["encoding/json"]), Sel: ast.NewIdent("Marshal")},
+										Args: []ast.Expr{ast.NewIdent("form")},
+									},
+								},
+							},
+						},
+					},
+					&ast.CaseClause{
+						Body: createWriteStatusStmts(415),
+					},
+				},
+			},
+		},
+	}
+}
+
+// createWriteStatusStmts creates the statements writing a status code and returning from Handle:
+//
+//	w.WriteHeader(status)
+//	return
+func createWriteStatusStmts(status int) []ast.Stmt {
+	return []ast.Stmt{
+		createWriteHeaderStmt(status),
+		&ast.ReturnStmt{},
+	}
+}
+
+// createWriteHeaderStmt creates the statement writing a status code:
+//
+//	w.WriteHeader(status)
+func createWriteHeaderStmt(status int) ast.Stmt {
+	return &ast.ExprStmt{
+		X: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{
+				X:   ast.NewIdent("w"),
+				Sel: ast.NewIdent("WriteHeader"),
+			},
+			Args: []ast.Expr{
+				&ast.BasicLit{
+					Kind:  token.INT,
+					Value: strconv.Itoa(status),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,403 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// initializeFuncName is the name of the function main is turned into with StyleFuncInstance
+const initializeFuncName = "initialize"
+
+const (
+	// StyleHandler generates a Handler type whose Handle method serves the requests
+	StyleHandler = "handler"
+	// StyleFuncInstance generates a Function type implementing the lifecycle hooks of func's Go instances
+	StyleFuncInstance = "func-instance"
+	// StyleCloudEvents generates a Handler type whose Handle method serves CloudEvents, passing their data to
+	// the handler and returning its output as the data of the response event
+	StyleCloudEvents = "cloudevents"
+)
+
+// generatedTypeName returns the name of the type generated to serve the requests
+func generatedTypeName(opts *GenerateOptions) string {
+	if opts.Style == StyleFuncInstance {
+		return "Function"
+	}
+	return "Handler"
+}
+
+// findMainFunc returns the main function declared in the file, or nil if there is none
+func findMainFunc(file *ast.File) *ast.FuncDecl {
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return fn
+		}
+	}
+	return nil
+}
+
+// removeFunc removes the function and the comments documenting it or inside it from the file
+func removeFunc(file *ast.File, fn *ast.FuncDecl) {
+	file.Decls = slices.DeleteFunc(file.Decls, func(decl ast.Decl) bool { return decl == fn })
+	if fn.Doc != nil {
+		dropComments(file, fn.Doc, nil, nil)
+	}
+	dropComments(file, fn, nil, nil)
+}
+
+// instanceHooks splits the statements of main preceding the one starting the Lambda handler into the
+// initialization run by Start and the calls deferred by it, which are run by Stop in reverse order. The
+// statements with side effects following the last setup statement are left out of the initialization, as
+// they may be meant to run per request rather than once at startup, while those only declaring what is passed
+// to the start statement along with the handler are left out altogether.
+func instanceHooks(file *ast.File, main *ast.FuncDecl, handlerExpr ast.Expr) (start []ast.Stmt, deferred []*ast.DeferStmt, dropped []ast.Stmt) {
+	var startStmt ast.Stmt
+	for _, stmt := range main.Body.List {
+		if stmt.Pos() <= handlerExpr.Pos() && handlerExpr.Pos() < stmt.End() {
+			startStmt = stmt
+			break
+		}
+		if deferStmt, ok := stmt.(*ast.DeferStmt); ok {
+			deferred = append([]*ast.DeferStmt{deferStmt}, deferred...)
+		} else {
+			start = append(start, stmt)
+		}
+	}
+	start = slices.DeleteFunc(start, func(stmt ast.Stmt) bool {
+		return startStmt != nil && declaresStartArgs(main, startStmt, handlerExpr, stmt)
+	})
+
+	setupEnd := 0
+	for i, stmt := range start {
+		if isSetupStmt(file, stmt) {
+			setupEnd = i + 1
+		}
+	}
+	return start[:setupEnd], deferred, start[setupEnd:]
+}
+
+// declaresStartArgs reports whether the statement only declares variables passed along with the handler to the
+// statement starting it (e.g., the context passed to lambda.StartWithContext), which are left unused once the
+// start statement is removed
+func declaresStartArgs(main *ast.FuncDecl, startStmt ast.Stmt, handlerExpr ast.Expr, stmt ast.Stmt) bool {
+	assignStmt, ok := stmt.(*ast.AssignStmt)
+	if !ok || assignStmt.Tok != token.DEFINE {
+		return false
+	}
+	declared := make(map[*ast.Object]bool)
+	for _, lhs := range assignStmt.Lhs {
+		ident, ok := lhs.(*ast.Ident)
+		if !ok || ident.Name != "_" && (ident.Obj == nil || ident.Obj.Decl != assignStmt) {
+			return false
+		}
+		if ident.Name != "_" {
+			declared[ident.Obj] = true
+		}
+	}
+
+	passed := false
+	usedElsewhere := false
+	ast.Inspect(main.Body, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || !declared[ident.Obj] || slices.Contains(assignStmt.Lhs, ast.Expr(ident)) {
+			return true
+		}
+		if startStmt.Pos() <= ident.Pos() && ident.End() <= startStmt.End() && !(handlerExpr.Pos() <= ident.Pos() && ident.End() <= handlerExpr.End()) {
+			passed = true
+		} else {
+			usedElsewhere = true
+		}
+		return true
+	})
+	return passed && !usedElsewhere
+}
+
+// isSetupStmt reports whether the statement is part of the setup run once at startup: declarations,
+// assignments (e.g., creating clients), and conditionals only made of these or exiting (e.g., checking the
+// error of creating a client)
+func isSetupStmt(file *ast.File, stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.DeclStmt, *ast.AssignStmt, *ast.ReturnStmt, *ast.EmptyStmt:
+		return true
+	case *ast.ExprStmt:
+		callExpr, ok := s.X.(*ast.CallExpr)
+		return ok && isExitCall(file, callExpr)
+	case *ast.IfStmt:
+		if s.Init != nil && !isSetupStmt(file, s.Init) {
+			return false
+		}
+		return isSetupStmt(file, s.Body) && (s.Else == nil || isSetupStmt(file, s.Else))
+	case *ast.BlockStmt:
+		for _, stmt := range s.List {
+			if !isSetupStmt(file, stmt) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isExitCall reports whether the call ends the program: a call of panic, os.Exit, or the Fatal and Panic
+// functions of package log
+func isExitCall(file *ast.File, callExpr *ast.CallExpr) bool {
+	if ident, ok := callExpr.Fun.(*ast.Ident); ok && ident.Obj == nil && ident.Name == "panic" {
+		return true
+	}
+	switch qualifiedFuncName(file, callExpr.Fun) {
+	case "os.Exit", "log.Fatal", "log.Fatalf", "log.Fatalln", "log.Panic", "log.Panicf", "log.Panicln":
+		return true
+	}
+	return false
+}
+
+// setupFuncName returns the name of the function the setup of main is kept in with the style
+func setupFuncName(opts *GenerateOptions) string {
+	if opts.Style == StyleFuncInstance {
+		return initializeFuncName
+	}
+	return "New"
+}
+
+// checkSetup warns about variables local to main (or the init function registering the handler) that the
+// handler or the deferred calls moved to Stop refer to, as they are no longer in scope once the setup of main is
+// moved to the initialization run by Start or to New, about the deferred calls dropped by the styles without a
+// Stop hook, and about the statements left out of the setup
+func checkSetup(r *reporter, file *ast.File, handlerRef *HandlerReference, opts *GenerateOptions) {
+	main := handlerRef.registrar
+	if locals := mainLocals(main, handlerRef.Expr); len(locals) > 0 {
+		r.warnf(handlerRef.Expr.Pos(), "%s refers to %s local to %s, which is not in scope of Handle; declare it at package level or as a %s field", handlerRef.QualifiedName, strings.Join(locals, ", "), main.Name.Name, generatedTypeName(opts))
+	}
+	_, deferred, dropped := instanceHooks(file, main, handlerRef.Expr)
+	for _, deferStmt := range deferred {
+		if opts.Style != StyleFuncInstance {
+			r.warnf(deferStmt.Pos(), "deferred call of %s is dropped, as New returns before requests are served; use -style %s to run it in Stop", main.Name.Name, StyleFuncInstance)
+		} else if locals := mainLocals(main, deferStmt.Call); len(locals) > 0 {
+			r.warnf(deferStmt.Pos(), "deferred call moved to Stop refers to %s local to %s, which is not in scope of Stop; declare it at package level or as a Function field", strings.Join(locals, ", "), main.Name.Name)
+		}
+	}
+	for _, stmt := range dropped {
+		r.warnf(stmt.Pos(), "statement between the setup in %s and the start of %s has side effects and is left out of %s; move it there if it has to run once at startup, or to the handler if it has to run per request", main.Name.Name, handlerRef.QualifiedName, setupFuncName(opts))
+	}
+}
+
+// checkDroppedSetup warns about each statement of main (or the init function registering the handler) other
+// than the start of the handler, as they are dropped along with it when several handlers are merged
+func checkDroppedSetup(r *reporter, file *ast.File, handlerRef *HandlerReference) {
+	main := handlerRef.registrar
+	start, deferred, dropped := instanceHooks(file, main, handlerRef.Expr)
+	stmts := append([]ast.Stmt(nil), start...)
+	for _, deferStmt := range deferred {
+		stmts = append(stmts, deferStmt)
+	}
+	stmts = append(stmts, dropped...)
+	for _, stmt := range stmts {
+		r.warnf(stmt.Pos(), "statement of %s is dropped along with the start of %s; move it to an init function if it sets up the handler", main.Name.Name, handlerRef.QualifiedName)
+	}
+}
+
+// mainLocals returns the names of the variables local to main that the node refers to
+func mainLocals(main *ast.FuncDecl, node ast.Node) []string {
+	var names []string
+	seen := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj == nil || ident.Obj.Kind != ast.Var || seen[ident.Name] {
+			return true
+		}
+		if decl, ok := ident.Obj.Decl.(ast.Node); ok && main.Body.Pos() <= decl.Pos() && decl.End() <= main.Body.End() {
+			seen[ident.Name] = true
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// createInitializeFunc turns main into the function run by Start, keeping the initialization preceding the
+// start of the Lambda handler and returning nil in place of exiting early. Reusing main keeps the comments
+// of the initialization in place, as the printer places comments by their position:
+//
+//	func initialize() error {
+//		db = connect()
+//		return nil
+//	}
+func createInitializeFunc(main *ast.FuncDecl, start []ast.Stmt) *ast.FuncDecl {
+	for _, stmt := range start {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				n.Results = []ast.Expr{ast.NewIdent("nil")}
+			}
+			return true
+		})
+	}
+
+	main.Name = &ast.Ident{NamePos: main.Name.NamePos, Name: initializeFuncName}
+	main.Type.Results = &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("error")}}}
+	main.Body.Rbrace = setupRbrace(main, start)
+	main.Body.List = append(start, &ast.ReturnStmt{Return: main.Body.Rbrace, Results: []ast.Expr{ast.NewIdent("nil")}})
+	return main
+}
+
+// createSetupNewFunc turns main into the New function of the styles other than StyleFuncInstance, keeping the
+// setup preceding the start of the Lambda handler and returning the generated handler in place of exiting early.
+// Like with createInitializeFunc, reusing main keeps the comments of the setup in place:
+//
+//	func New() *Handler {
+//		db = connect()
+//		return &Handler{}
+//	}
+func createSetupNewFunc(main *ast.FuncDecl, start []ast.Stmt, newFunc *ast.FuncDecl) *ast.FuncDecl {
+	results := newFunc.Body.List[len(newFunc.Body.List)-1].(*ast.ReturnStmt).Results
+	for _, stmt := range start {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				n.Results = resultsAt(results, n.Return)
+			}
+			return true
+		})
+	}
+
+	main.Name = &ast.Ident{NamePos: main.Name.NamePos, Name: newFunc.Name.Name}
+	main.Type.Results = newFunc.Type.Results
+	main.Body.Rbrace = setupRbrace(main, start)
+	main.Body.List = append(start, &ast.ReturnStmt{Return: main.Body.Rbrace, Results: resultsAt(results, main.Body.Rbrace)})
+	return main
+}
+
+// resultsAt returns copies of the results of the generated New function placed at the position of a return
+// statement, as the braces of empty structs (e.g., of a semaphore channel) are otherwise positioned at the start
+// of the file, breaking the lines of the kept statements following them
+func resultsAt(results []ast.Expr, pos token.Pos) []ast.Expr {
+	placed := make([]ast.Expr, len(results))
+	for i, result := range results {
+		placed[i] = copyExpr(result)
+		ast.Inspect(placed[i], func(n ast.Node) bool {
+			if fields, ok := n.(*ast.FieldList); ok && fields.Opening.IsValid() {
+				fields.Opening, fields.Closing = pos, pos
+			}
+			return true
+		})
+	}
+	return placed
+}
+
+// setupRbrace returns the position closing the body of main once only its setup is kept: the end of the last
+// kept statement, or the start of the first statement if none is kept. The appended return is then printed on a
+// line of its own, neither preceded by a blank line nor joined with the whole body on a single line.
+func setupRbrace(main *ast.FuncDecl, start []ast.Stmt) token.Pos {
+	if len(start) > 0 {
+		return start[len(start)-1].End()
+	}
+	return main.Body.List[0].Pos()
+}
+
+// createInstanceHooks creates the lifecycle hooks of func's Go instances, running the initialization in
+// Start and the calls deferred by main in Stop, and reporting the instance as always ready and alive:
+//
+//	func (*Function) Start(context.Context, map[string]string) error {
+//		return initialize()
+//	}
+//
+//	func (*Function) Stop(context.Context) error {
+//		db.Close()
+//		return nil
+//	}
+//
+//	func (*Function) Ready(context.Context) (bool, error) {
+//		return true, nil
+//	}
+//
+//	func (*Function) Alive(context.Context) (bool, error) {
+//		return true, nil
+//	}
+//
+// With readyz, Start records whether the initialization succeeded, which Ready reports.
+func createInstanceHooks(deferred []*ast.DeferStmt, contextAlias string, readyz bool) []ast.Decl {
+	var stop []ast.Stmt
+	for _, deferStmt := range deferred {
+		stop = append(stop, &ast.ExprStmt{X: deferStmt.Call})
+	}
+
+	contextParam := func() *ast.Field {
+		return &ast.Field{Type: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("Context")}}
+	}
+	hook := func(name string, params []*ast.Field, results []ast.Expr, body []ast.Stmt, values ...ast.Expr) *ast.FuncDecl {
+		resultFields := &ast.FieldList{}
+		for _, result := range results {
+			resultFields.List = append(resultFields.List, &ast.Field{Type: result})
+		}
+		return &ast.FuncDecl{
+			Recv: &ast.FieldList{List: []*ast.Field{{Type: &ast.StarExpr{X: ast.NewIdent("Function")}}}},
+			Name: ast.NewIdent(name),
+			Type: &ast.FuncType{Params: &ast.FieldList{List: params}, Results: resultFields},
+			Body: &ast.BlockStmt{List: append(body, &ast.ReturnStmt{Results: values})},
+		}
+	}
+	configParam := &ast.Field{Type: &ast.MapType{Key: ast.NewIdent("string"), Value: ast.NewIdent("string")}}
+	status := func() []ast.Expr {
+		return []ast.Expr{ast.NewIdent("bool"), ast.NewIdent("error")}
+	}
+
+	start := hook("Start", []*ast.Field{contextParam(), configParam}, []ast.Expr{ast.NewIdent("error")}, nil,
+		&ast.CallExpr{Fun: ast.NewIdent(initializeFuncName)})
+	ready := hook("Ready", []*ast.Field{contextParam()}, status(), nil, ast.NewIdent("true"), ast.NewIdent("nil"))
+	if readyz {
+		start = hook("Start", []*ast.Field{contextParam(), configParam}, []ast.Expr{ast.NewIdent("error")}, createReadyStartStmts(),
+			ast.NewIdent("nil"))
+		ready = hook("Ready", []*ast.Field{contextParam()}, status(), nil, createReadyLoadExpr(), ast.NewIdent("nil"))
+		for _, fn := range []*ast.FuncDecl{start, ready} {
+			fn.Recv.List[0].Names = []*ast.Ident{ast.NewIdent("h")}
+		}
+	}
+
+	return []ast.Decl{
+		start,
+		hook("Stop", []*ast.Field{contextParam()}, []ast.Expr{ast.NewIdent("error")}, stop, ast.NewIdent("nil")),
+		ready,
+		hook("Alive", []*ast.Field{contextParam()}, status(), nil, ast.NewIdent("true"), ast.NewIdent("nil")),
+	}
+}
+
+// dropComments removes the comments inside the node from the file, except those preceding the end of the
+// last kept statement outside of the other statements (e.g., the comments documenting the kept statements), and
+// those inside the moved nodes (e.g., a hoisted function literal)
+func dropComments(file *ast.File, node ast.Node, body []ast.Stmt, keep []ast.Stmt, moved ...ast.Node) {
+	comments := file.Comments[:0]
+	for _, c := range file.Comments {
+		drop := node.Pos() <= c.Pos() && c.End() <= node.End()
+		for _, n := range moved {
+			drop = drop && !(n.Pos() <= c.Pos() && c.End() <= n.End())
+		}
+		if drop && len(keep) > 0 && c.End() <= keep[len(keep)-1].End() {
+			drop = false
+			for _, stmt := range body {
+				if stmt.Pos() <= c.Pos() && c.End() <= stmt.End() {
+					drop = !containsStmt(keep, stmt)
+				}
+			}
+		}
+		if !drop {
+			comments = append(comments, c)
+		}
+	}
+	file.Comments = comments
+}
+
+// containsStmt reports whether the statement is among the statements
+func containsStmt(stmts []ast.Stmt, stmt ast.Stmt) bool {
+	for _, s := range stmts {
+		if s == stmt {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package migrator
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// funcInstanceSrc declares the interfaces of knative.dev/func-go/http that func's Go instances implement
+const funcInstanceSrc = `package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type instance interface {
+	Handle(http.ResponseWriter, *http.Request)
+	Start(context.Context, map[string]string) error
+	Stop(context.Context) error
+	Ready(context.Context) (bool, error)
+	Alive(context.Context) (bool, error)
+}
+`
+
+func TestFuncInstanceImplementsInstance(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+var prefix string
+
+type Event struct {
+	Name string ` + "`json:\"name\"`" + `
+}
+
+func handle(ctx context.Context, e Event) (string, error) {
+	return prefix + e.Name, nil
+}
+
+func main() {
+	prefix = os.Getenv("PREFIX")
+	lambda.Start(handle)
+}
+`
+	opts := DefaultOptions()
+	opts.Style = StyleFuncInstance
+	var buf bytes.Buffer
+	if err := TransformTo(&buf, []byte(src), opts); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, src := range map[string]string{"main.go": out, "instance.go": funcInstanceSrc} {
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			t.Fatalf("parsing %s: %v\n%s", name, err, src)
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("main", fset, files, nil)
+	if err != nil {
+		t.Fatalf("type checking the generated code: %v\n%s", err, out)
+	}
+
+	function := types.NewPointer(pkg.Scope().Lookup("Function").Type())
+	instance := pkg.Scope().Lookup("instance").Type().Underlying().(*types.Interface)
+	if method, wrongType := types.MissingMethod(function, instance, true); method != nil {
+		t.Errorf("*Function doesn't implement func's instance interface, method %s is missing or has the wrong type (%t):\n%s", method.Name(), wrongType, out)
+	}
+
+	// The initialization keeps a line of its own for each statement
+	if !strings.Contains(out, "func initialize() error {\n\tprefix = os.Getenv(\"PREFIX\")\n\treturn nil\n}") {
+		t.Errorf("initialize isn't printed one statement per line:\n%s", out)
+	}
+}
@@ -0,0 +1,91 @@
+package migrator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeInvokeExample writes a shell snippet sending a sample request to the deployed function with func invoke,
+// whose data is a sample of the handler input derived from its JSON Schema
+func writeInvokeExample(w io.Writer, handlerRef *HandlerReference, handlerSig *HandlerSignature, opts *GenerateOptions) error {
+	fmt.Fprintf(w, "#!/bin/sh\n# Send a sample request to the function migrated from %s once deployed\n", handlerRef.QualifiedName)
+
+	comment, command, err := invokeCommand(handlerSig, opts)
+	if err != nil {
+		return err
+	}
+	if comment != "" {
+		fmt.Fprintf(w, "# %s\n", comment)
+	}
+	_, err = fmt.Fprintln(w, command)
+	return err
+}
+
+// invokeCommand returns the command sending a sample request to the deployed function, along with a comment
+// on it if it needs attention
+func invokeCommand(handlerSig *HandlerSignature, opts *GenerateOptions) (comment, command string, err error) {
+	// Functions of the cloudevents style are sent the data in a CloudEvent
+	format := "http"
+	if opts.Style == StyleCloudEvents {
+		format = "cloudevent"
+	}
+
+	switch {
+	case opts.InputSource == InputSourceMultipart:
+		// func invoke only sends the data as the request body
+		return fmt.Sprintf("The handler input is uploaded in the multipart form field %q, which func invoke can't send", opts.FileField),
+			fmt.Sprintf("curl -F %s=@input.json \"$FUNCTION_URL\"", opts.FileField), nil
+	case !handlerSig.HasInput || handlerSig.InputTypeID == cloudFrontRequestID || mapsAPIGatewayRequest(handlerSig):
+		return "", "func invoke --format " + format, nil
+	}
+
+	sample, err := sampleInput(handlerSig)
+	if err != nil {
+		return "", "", err
+	}
+	return "Replace the zero values with realistic ones", "func invoke --format " + format + " --content-type application/json --data " + shellQuote(string(sample)), nil
+}
+
+// sampleInput returns a JSON sample of the handler input derived from its JSON Schema
+func sampleInput(handlerSig *HandlerSignature) ([]byte, error) {
+	schema := handlerSig.inputTypeSchema()
+	if schema["format"] == "byte" || len(schema) == 0 {
+		// Raw and untyped inputs are most likely JSON objects
+		return []byte("{}"), nil
+	}
+	return json.Marshal(sampleOf(schema))
+}
+
+// sampleOf returns a sample value matching the JSON Schema, using zero values and a single array element
+func sampleOf(schema map[string]any) any {
+	switch schema["type"] {
+	case "object":
+		sample := make(map[string]any)
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for name, property := range properties {
+				sample[name] = sampleOf(property.(map[string]any))
+			}
+		}
+		return sample
+	case "array":
+		items, _ := schema["items"].(map[string]any)
+		return []any{sampleOf(items)}
+	case "string":
+		if schema["format"] == "date-time" {
+			return "2006-01-02T15:04:05Z"
+		}
+		return ""
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,48 @@
+package migrator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// koBaseImage is the base image of the images built by ko
+const koBaseImage = "cgr.dev/chainguard/static"
+
+// KoConfig returns the path and content of a minimal .ko.yaml at the root of the module containing the
+// main package in mainDir, building that package on ko's default base image
+func KoConfig(mainDir string) (string, []byte, error) {
+	root, importPath, main, err := mainPackage(mainDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	config := fmt.Sprintf(`# Build and publish the migrated function with: ko build %s
+defaultBaseImage: %s
+builds:
+- id: %s
+  main: %s
+`, importPath, koBaseImage, path.Base(importPath), main)
+	return filepath.Join(root, ".ko.yaml"), []byte(config), nil
+}
+
+// mainPackage returns the root of the module containing the main package in mainDir, the package's import
+// path, and its path relative to the root as the go command expects it (e.g., "./cmd/function")
+func mainPackage(mainDir string) (string, string, string, error) {
+	root, importPath, err := packageImportPath(mainDir)
+	if err != nil {
+		return "", "", "", err
+	}
+	absDir, err := filepath.Abs(mainDir)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	rel, err := filepath.Rel(root, absDir)
+	if err != nil {
+		return "", "", "", err
+	}
+	if rel == "." {
+		return root, importPath, ".", nil
+	}
+	return root, importPath, "./" + filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,29 @@
+package migrator
+
+import (
+	"go/ast"
+	"slices"
+)
+
+// findLambdaContextRefs finds the references to the lambdacontext package of the AWS Lambda SDK module kept by the
+// migration, i.e. outside the removed functions. Its import is kept for them, but the Lambda context and the
+// function settings it reads are only provided by the Lambda runtime.
+func findLambdaContextRefs(file *ast.File, lambdaModule string, removed []*ast.FuncDecl) []*ast.SelectorExpr {
+	var refs []*ast.SelectorExpr
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && slices.Contains(removed, fn) {
+			continue
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			selExpr, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj == nil && importPathForName(file, ident.Name) == lambdaModule+"/lambdacontext" {
+				refs = append(refs, selExpr)
+			}
+			return true
+		})
+	}
+	return refs
+}
@@ -0,0 +1,35 @@
+package migrator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"regexp"
+	"text/tabwriter"
+)
+
+// listHandlers prints every detected Lambda handler matching the pattern (if any) with its signature shape
+// and event type without transforming the file
+func listHandlers(w io.Writer, r *reporter, inputFile string, file *ast.File, fset *token.FileSet, pattern *regexp.Regexp, lambdaModule string, startFuncs []string) error {
+	handlerRefs, err := findMatchingHandlers(r, file, pattern, lambdaModule, startFuncs)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HANDLER\tSIGNATURE\tEVENT TYPE")
+	for _, handlerRef := range handlerRefs {
+		handlerSig, err := resolveHandlerSignature(r, inputFile, file, fset, handlerRef, nil, false)
+		if err != nil {
+			return fmt.Errorf("failed to analyze handler %s: %w", handlerRef.QualifiedName, err)
+		}
+
+		eventType := handlerSig.InputType
+		if eventType == "" {
+			eventType = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", handlerRef.QualifiedName, handlerSig.Shape(), eventType)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,103 @@
+package migrator
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// literalHandlerName is the name of the function a function literal passed as the handler is hoisted into,
+// numbered if taken (e.g., lambdaHandler2)
+const literalHandlerName = "lambdaHandler"
+
+// unusedLiteralHandlerName returns a name for a function literal handler that the file doesn't declare and that
+// isn't taken by the other literal handlers, and marks it as taken
+func unusedLiteralHandlerName(file *ast.File, taken map[string]bool) string {
+	name := literalHandlerName
+	for i := 2; taken[name] || file.Scope.Lookup(name) != nil; i++ {
+		name = literalHandlerName + strconv.Itoa(i)
+	}
+	taken[name] = true
+	return name
+}
+
+// hoistLiteralHandler moves the function literal passed as the handler into a function declared after the one
+// registering it, and refers to the handler by that function's name. Variables of the registering function the
+// literal captures are reported, as they are no longer in scope of the declared function:
+//
+//	lambda.Start(func(ctx context.Context, e MyEvent) error { ... })
+//
+// becomes
+//
+//	lambda.Start(lambdaHandler)
+//
+//	func lambdaHandler(ctx context.Context, e MyEvent) error { ... }
+func hoistLiteralHandler(r *reporter, file *ast.File, handlerRef *HandlerReference) {
+	literal := handlerRef.literal()
+	if literal == nil {
+		return
+	}
+
+	registrar := handlerRef.registrar
+	if captured := capturedLocals(registrar, literal); len(captured) > 0 {
+		r.warnf(literal.Pos(), "function literal handler hoisted into %s captures %s local to %s, which is not in scope of %s; declare it at package level", handlerRef.SimpleName, strings.Join(captured, ", "), registrar.Name.Name, handlerRef.SimpleName)
+	}
+
+	// The name replacing the literal keeps its position, within the start call
+	name := &ast.Ident{NamePos: literal.Pos(), Name: handlerRef.SimpleName}
+	astutil.Apply(registrar.Body, func(c *astutil.Cursor) bool {
+		if c.Node() == literal {
+			c.Replace(name)
+			return false
+		}
+		return true
+	}, nil)
+	fn := &ast.FuncDecl{
+		Name: ast.NewIdent(handlerRef.SimpleName),
+		Type: literal.Type,
+		Body: literal.Body,
+	}
+	for i, decl := range file.Decls {
+		if decl == registrar {
+			file.Decls = append(file.Decls[:i+1], append([]ast.Decl{fn}, file.Decls[i+1:]...)...)
+			break
+		}
+	}
+	handlerRef.Expr = name
+	handlerRef.hoisted = fn
+}
+
+// renameLiteralHandler renames the function the literal handler of a source merged into another was hoisted
+// into if the name is already declared, and marks the name as declared
+func renameLiteralHandler(handlerRef *HandlerReference, declared map[string]bool) {
+	name := handlerRef.SimpleName
+	for i := 2; declared[name]; i++ {
+		name = literalHandlerName + strconv.Itoa(i)
+	}
+	handlerRef.hoisted.Name.Name = name
+	handlerRef.Expr.(*ast.Ident).Name = name
+	handlerRef.SimpleName = name
+	handlerRef.QualifiedName = name
+}
+
+// capturedLocals returns the names of the variables local to the function that the function literal inside it
+// refers to, other than the literal's own parameters and variables
+func capturedLocals(fn *ast.FuncDecl, literal *ast.FuncLit) []string {
+	var names []string
+	seen := make(map[string]bool)
+	ast.Inspect(literal, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || ident.Obj == nil || ident.Obj.Kind != ast.Var || seen[ident.Name] {
+			return true
+		}
+		decl, ok := ident.Obj.Decl.(ast.Node)
+		if ok && fn.Body.Pos() <= decl.Pos() && decl.End() <= fn.Body.End() && (decl.Pos() < literal.Pos() || literal.End() < decl.End()) {
+			seen[ident.Name] = true
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,117 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+const (
+	// loggerKeyTypeName is the type of the context key the request-scoped logger is stored under
+	loggerKeyTypeName = "loggerKey"
+	// loggerAccessorName is the function handlers read the request-scoped logger from their context with
+	loggerAccessorName = "LoggerFromContext"
+)
+
+// injectsLoggerParam reports whether the handler takes a *slog.Logger parameter receiving the request-scoped
+// logger instead of the default one
+func injectsLoggerParam(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return opts.InjectLogger && handlerSig.LoggerTypeID == slogLoggerID
+}
+
+// createLoggerContextStmts creates the statements deriving a logger with the attributes of the request from
+// the default one, and storing it in the handler context:
+//
+//	logger := slog.Default().With("method", r.Method, "path", r.URL.Path)
+//	ctx = context.WithValue(ctx, loggerKey{}, logger)
+func createLoggerContextStmts(contextAlias, slogAlias string) []ast.Stmt {
+	attr := func(name string, value ast.Expr) []ast.Expr {
+		return []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name)}, value}
+	}
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("logger")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent(slogAlias), Sel: ast.NewIdent("Default")}},
+					Sel: ast.NewIdent("With"),
+				},
+				Args: append(
+					attr("method", &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Method")}),
+					attr("path", &ast.SelectorExpr{
+						X:   &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("URL")},
+						Sel: ast.NewIdent("Path"),
+					})...,
+				),
+			}},
+		},
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("ctx")},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("WithValue")},
+				Args: []ast.Expr{
+					ast.NewIdent("ctx"),
+					&ast.CompositeLit{Type: ast.NewIdent(loggerKeyTypeName)},
+					ast.NewIdent("logger"),
+				},
+			}},
+		},
+	}
+}
+
+// createLoggerDecls creates the context key type of the request-scoped logger and the function handlers read
+// it with, which returns the default logger for contexts not derived from the request context:
+//
+//	type loggerKey struct{}
+//
+//	func LoggerFromContext(ctx context.Context) *slog.Logger {
+//		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+//			return logger
+//		}
+//		return slog.Default()
+//	}
+func createLoggerDecls(contextAlias, slogAlias string) []ast.Decl {
+	// The empty struct braces need positions on the same line to be printed as struct{}
+	keyType := &ast.GenDecl{
+		Tok: token.TYPE,
+		Specs: []ast.Spec{&ast.TypeSpec{
+			Name: ast.NewIdent(loggerKeyTypeName),
+			Type: &ast.StructType{Fields: &ast.FieldList{Opening: 1, Closing: 1}},
+		}},
+	}
+	loggerType := func() ast.Expr {
+		return &ast.StarExpr{X: &ast.SelectorExpr{X: ast.NewIdent(slogAlias), Sel: ast.NewIdent("Logger")}}
+	}
+	accessor := &ast.FuncDecl{
+		Name: ast.NewIdent(loggerAccessorName),
+		Type: &ast.FuncType{
+			Params: &ast.FieldList{List: []*ast.Field{
+				{Names: []*ast.Ident{ast.NewIdent("ctx")}, Type: &ast.SelectorExpr{X: ast.NewIdent(contextAlias), Sel: ast.NewIdent("Context")}},
+			}},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: loggerType()}}},
+		},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.IfStmt{
+				Init: &ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("logger"), ast.NewIdent("ok")},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.TypeAssertExpr{
+						X: &ast.CallExpr{
+							Fun:  &ast.SelectorExpr{X: ast.NewIdent("ctx"), Sel: ast.NewIdent("Value")},
+							Args: []ast.Expr{&ast.CompositeLit{Type: ast.NewIdent(loggerKeyTypeName)}},
+						},
+						Type: loggerType(),
+					}},
+				},
+				Cond: ast.NewIdent("ok"),
+				Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("logger")}}}},
+			},
+			&ast.ReturnStmt{Results: []ast.Expr{
+				&ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent(slogAlias), Sel: ast.NewIdent("Default")}},
+			}},
+		}},
+	}
+	return []ast.Decl{keyType, accessor}
+}
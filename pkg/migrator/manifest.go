@@ -0,0 +1,34 @@
+package migrator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FuncManifest holds the fields of the func.yaml the func CLI builds and deploys the migrated function with
+type FuncManifest struct {
+	Name       string // DNS label the function is named by, derived from the handler (e.g., "handle-request" for HandleRequest)
+	Invocation string // How func invoke sends requests to the function, http or cloudevent
+}
+
+// manifestName derives the DNS label the function is named by in its func.yaml from the handler name, separating
+// the words of camel case names with hyphens (e.g., "handle-request" for HandleRequest)
+func manifestName(handlerRef *HandlerReference) string {
+	var b strings.Builder
+	runes := []rune(handlerRef.baseName())
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteRune('-')
+		}
+		b.WriteRune(r)
+	}
+	return serviceName(b.String())
+}
+
+// manifestInvocation returns how func invoke sends requests to the function generated with the style
+func manifestInvocation(opts *GenerateOptions) string {
+	if opts.Style == StyleCloudEvents {
+		return "cloudevent"
+	}
+	return "http"
+}
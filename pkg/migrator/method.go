@@ -0,0 +1,49 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// createMethodCheckStmt creates the statement rejecting requests whose method is not among the allowed ones:
+//
+//	if r.Method != "POST" && r.Method != "PUT" {
+//		w.Header().Set("Allow", "POST, PUT")
+//		w.WriteHeader(405)
+//		return
+//	}
+func createMethodCheckStmt(methods []string) ast.Stmt {
+	var cond ast.Expr
+	for _, method := range methods {
+		neq := &ast.BinaryExpr{
+			X:  &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("Method")},
+			Op: token.NEQ,
+			Y:  &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(method)},
+		}
+		if cond == nil {
+			cond = neq
+		} else {
+			cond = &ast.BinaryExpr{X: cond, Op: token.LAND, Y: neq}
+		}
+	}
+
+	setAllow := &ast.ExprStmt{
+		X: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{
+				X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")}},
+				Sel: ast.NewIdent("Set"),
+			},
+			Args: []ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: `"Allow"`},
+				&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(strings.Join(methods, ", "))},
+			},
+		},
+	}
+
+	return &ast.IfStmt{
+		Cond: cond,
+		Body: &ast.BlockStmt{List: append([]ast.Stmt{setAllow}, createWriteStatusStmts(405)...)},
+	}
+}
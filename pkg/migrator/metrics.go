@@ -0,0 +1,150 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+const (
+	// otelPkgPath, otelMetricPkgPath, and otelAttributePkgPath are the OpenTelemetry API packages the counters
+	// are created and incremented with
+	otelPkgPath          = "go.opentelemetry.io/otel"
+	otelMetricPkgPath    = "go.opentelemetry.io/otel/metric"
+	otelAttributePkgPath = "go.opentelemetry.io/otel/attribute"
+
+	// meterName is the instrumentation scope of the generated counters
+	meterName = "function"
+	// requestCounterName and errorCounterName are the package-level variables holding the counters
+	requestCounterName = "requestCounter"
+	errorCounterName   = "errorCounter"
+
+	// outcomeSuccess, outcomeError, outcomeTimeout, and outcomePanic are the outcomes of handler invocations
+	// the counters are incremented with
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+	outcomeTimeout = "timeout"
+	outcomePanic   = "panic"
+)
+
+// createMetricsDecls creates the counters of handler invocations and failed ones, which are created once from
+// the global meter provider, so they report to the one the function sets up once it is set:
+//
+//	var requestCounter, errorCounter metric.Int64Counter
+//
+//	func init() {
+//		meter := otel.Meter("function")
+//		requestCounter, _ = meter.Int64Counter("function.requests", metric.WithDescription("..."))
+//		errorCounter, _ = meter.Int64Counter("function.errors", metric.WithDescription("..."))
+//	}
+//
+// Counters failing to be created are no-ops, so the errors are ignored.
+func createMetricsDecls(otelAlias, metricAlias string) []ast.Decl {
+	counter := func(variable, name, description string) ast.Stmt {
+		return &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(variable), ast.NewIdent("_")},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent("meter"), Sel: ast.NewIdent("Int64Counter")},
+				Args: []ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(name)},
+					&ast.CallExpr{
+						Fun:  &ast.SelectorExpr{X: ast.NewIdent(metricAlias), Sel: ast.NewIdent("WithDescription")},
+						Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(description)}},
+					},
+				},
+			}},
+		}
+	}
+
+	counters := &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(requestCounterName), ast.NewIdent(errorCounterName)},
+			Type:  &ast.SelectorExpr{X: ast.NewIdent(metricAlias), Sel: ast.NewIdent("Int64Counter")},
+		}},
+	}
+	initFunc := &ast.FuncDecl{
+		Name: ast.NewIdent("init"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("meter")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent(otelAlias), Sel: ast.NewIdent("Meter")},
+					Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(meterName)}},
+				}},
+			},
+			counter(requestCounterName, "function.requests", "Requests passed to the handler, by outcome"),
+			counter(errorCounterName, "function.errors", "Requests the handler failed with an error, a timeout, or a panic, by outcome"),
+		}},
+	}
+	return []ast.Decl{counters, initFunc}
+}
+
+// createRecordOutcomeStmts creates the statements incrementing the counters with the outcome of the handler
+// invocation once Handle returns. The outcome is a panic unless the handler returned:
+//
+//	outcome := "panic"
+//	defer func() {
+//		attrs := metric.WithAttributes(attribute.String("outcome", outcome))
+//		requestCounter.Add(ctx, 1, attrs)
+//		if outcome != "success" {
+//			errorCounter.Add(ctx, 1, attrs)
+//		}
+//	}()
+func createRecordOutcomeStmts(metricAlias, attributeAlias string) []ast.Stmt {
+	add := func(counter string) ast.Stmt {
+		return &ast.ExprStmt{X: &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent(counter), Sel: ast.NewIdent("Add")},
+			Args: []ast.Expr{ast.NewIdent("ctx"), &ast.BasicLit{Kind: token.INT, Value: "1"}, ast.NewIdent("attrs")},
+		}}
+	}
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("outcome")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(outcomePanic)}},
+		},
+		&ast.DeferStmt{Call: &ast.CallExpr{Fun: &ast.FuncLit{
+			Type: &ast.FuncType{Params: &ast.FieldList{}},
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("attrs")},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent(metricAlias), Sel: ast.NewIdent("WithAttributes")},
+						Args: []ast.Expr{&ast.CallExpr{
+							Fun: &ast.SelectorExpr{X: ast.NewIdent(attributeAlias), Sel: ast.NewIdent("String")},
+							Args: []ast.Expr{
+								&ast.BasicLit{Kind: token.STRING, Value: `"outcome"`},
+								ast.NewIdent("outcome"),
+							},
+						}},
+					}},
+				},
+				add(requestCounterName),
+				&ast.IfStmt{
+					Cond: &ast.BinaryExpr{
+						X:  ast.NewIdent("outcome"),
+						Op: token.NEQ,
+						Y:  &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(outcomeSuccess)},
+					},
+					Body: &ast.BlockStmt{List: []ast.Stmt{add(errorCounterName)}},
+				},
+			}},
+		}}},
+	}
+}
+
+// createOutcomeStmt creates the statement recording the outcome of the handler invocation:
+//
+//	outcome = "error"
+func createOutcomeStmt(outcome string) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{ast.NewIdent("outcome")},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(outcome)}},
+	}
+}
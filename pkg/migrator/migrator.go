@@ -0,0 +1,577 @@
+// Package migrator transforms AWS Lambda handler functions into Knative function handlers
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// Options configures the migration of a Lambda handler
+type Options struct {
+	GenerateOptions
+
+	Filename                 string         // Path of the source file, needed to type check handlers declared in other files or packages
+	Handler                  string         // Name of the handler to migrate if the source registers several (defaults to the first)
+	HandlerPackage           string         // Import path of the package declaring the handler, to tell it apart from functions of the same name in other packages (requires type checking the handler)
+	HandlerPattern           *regexp.Regexp // Only consider the handlers whose simple name matches (all if nil)
+	LegacyStartFuncs         []string       // Functions of the lambda package besides Start registering the handler given as their first argument (DefaultLegacyStartFuncs if nil)
+	SignatureMap             []string       // Role of each handler parameter (e.g., SignatureRoleInput, SignatureRoleContext) if not in the order of the Lambda docs (requires type checking the handler)
+	Normalize                bool           // Rename the handler declared in the source to NormalizedHandlerName
+	RewriteDeadline          bool           // Rewrite ctx.Deadline() calls in the handler to fall back to DeadlineDefault
+	DeadlineDefault          time.Duration  // Deadline assumed by rewritten ctx.Deadline() calls when the request context has none
+	AWSRegionEnv             string         // Environment variable passed as region to config.LoadDefaultConfig calls of the AWS SDK not setting one (not rewritten if empty)
+	NoAWSConfigAdvisory      bool           // Don't warn about config.LoadDefaultConfig calls relying on the credentials and region Lambda provides
+	StripXRay                bool           // Remove the X-Ray SDK's instrumentation calls and unwrap its Capture and Client wrappers
+	RenameConflictingImports bool           // Import the packages the generated code needs under another name if the source uses theirs (fails otherwise)
+	KeepPositions            bool           // Insert //line directives attributing the declarations kept from the source to their lines in Filename, e.g. for coverage and debugging tools
+	Format                   bool           // Format the migrated code like gofmt, writing it as printed with a warning if it can't be formatted
+	BasePath                 string         // Path prefix the routes passed to TransformRoutesTo are served under (e.g., "/api/v1")
+	OpenAPI                  io.Writer      // Destination of an OpenAPI 3 document describing the endpoint (not written if nil)
+	Bench                    io.Writer      // Destination of a test file benchmarking the generated Handle method with a sample request (not written if nil)
+	Invoke                   io.Writer      // Destination of a shell snippet sending a sample request to the deployed function with func invoke (not written if nil)
+	OutputValidator          io.Writer      // Destination of the file setting the output validation hook in builds with ValidateOutputTag (not written if nil)
+	Notes                    io.Writer      // Destination of Markdown notes summarizing the migration for reviewers (not written if nil)
+	Readme                   io.Writer      // Destination of a Markdown README describing how to build, deploy, and invoke the migrated function (not written if nil)
+	Manifest                 *FuncManifest  // Set to the func.yaml fields naming the function after the handler for the func CLI (not set if nil)
+	NoTmpAdvisory            bool           // Don't warn about handlers writing to /tmp
+	NoGlobalsAdvisory        bool           // Don't warn about handlers writing package-level variables without synchronization
+	NoReflectAdvisory        bool           // Don't warn about handlers passing their input to functions of package reflect
+	FailOnWarning            bool           // Return a WarningsError after an otherwise successful migration that reported warnings
+	Log                      io.Writer      // Destination of progress messages and warnings (discarded if nil)
+	DumpAST                  io.Writer      // Destination of the AST before and after the transformation, for debugging the migrator (not written if nil)
+}
+
+// DefaultOptions returns the options used by Transform
+func DefaultOptions() Options {
+	return Options{
+		GenerateOptions: GenerateOptions{InputSource: InputSourceBody, Style: StyleHandler},
+		DeadlineDefault: 5 * time.Minute,
+		Format:          true,
+	}
+}
+
+// GenerateOptions configures the generated Knative handler
+type GenerateOptions struct {
+	Style        string // Shape of the generated code (StyleHandler, StyleFuncInstance, or StyleCloudEvents)
+	LambdaModule string // Module path of the AWS Lambda SDK whose imports are removed, e.g. of a fork (defaults to github.com/aws/aws-lambda-go)
+	InputSource  string // Where the handler input is read from (InputSourceBody, InputSourceAuto, or InputSourceMultipart)
+	FileField    string // Multipart form field of the uploaded file passed as input with InputSourceMultipart
+	InputType    string // Concrete type the request body is decoded into for handlers taking an interface input (e.g., "*Circle")
+	PoolBuffers  bool   // Read request bodies into pooled buffers
+	Recover      bool   // Recover from handler panics
+	PanicStatus  bool   // Derive the status of recovered panics from a StatusCode() method
+	NoStack      bool   // Don't log the stack trace of recovered panics
+	ProtoJSON    bool   // Decode and encode protobuf message inputs and outputs with protojson (experimental)
+	PrettyOutput bool   // Indent JSON encoded outputs
+	JSONNumbers  bool   // Decode numbers in untyped input values as json.Number instead of float64, keeping the precision of large integers
+	StrictJSON   bool   // Answer inputs with fields the input struct doesn't declare with 400, like API Gateway request validation
+
+	Readyz          bool           // Answer /readyz with 503 until Start initialized the func instance and 200 after
+	Config          bool           // Generate a Config struct populated from the environment variables read in the source
+	Methods         []string       // Request methods accepted by Handle, others are answered with 405 (all if empty)
+	ResponseHeaders http.Header    // Static headers set on every response, e.g. those API Gateway added via integration responses
+	Timeout         time.Duration  // Cancel the handler context and respond with 504 once the handler ran this long (no timeout if zero)
+	CloudEventAttrs []string       // Attributes of CloudEvents in binary content mode stored in the handler context (e.g., "source"), read with CloudEventAttribute
+	ResponseSource  string         // Source of the response events of StyleCloudEvents (defaults to the function name derived from the handler, as in its func.yaml)
+	StatusFor       map[string]int // Success status by output type, qualified by package name or import path (e.g., "api.Created": 201)
+	InjectLogger    bool           // Store a request-scoped slog.Logger in the handler context, read with LoggerFromContext
+	ValidateOutput  bool           // Pass the result to a hook validating it against the output schema, set in builds with ValidateOutputTag
+	Envelope        bool           // Respond with 500 if the error field of the handler's single struct result is set, and encode its data field otherwise
+	Metrics         bool           // Count handler invocations and failed ones by outcome with OpenTelemetry counters
+	MaxConcurrency  int            // Answer requests with 429 while this many are served by the generated struct (no limit if zero)
+}
+
+// Validate checks the options for unsupported values
+func (o *GenerateOptions) Validate() error {
+	switch o.InputSource {
+	case InputSourceBody, InputSourceAuto:
+		if o.FileField != "" {
+			return fmt.Errorf("file field requires the %s input source", InputSourceMultipart)
+		}
+	case InputSourceMultipart:
+		if o.FileField == "" {
+			return fmt.Errorf("the %s input source requires a file field", InputSourceMultipart)
+		}
+	default:
+		return fmt.Errorf("unsupported input source %q", o.InputSource)
+	}
+	if o.InputType != "" {
+		if expr, err := parser.ParseExpr(o.InputType); err != nil {
+			return fmt.Errorf("invalid input type %q: %w", o.InputType, err)
+		} else if _, ok := expr.(*ast.InterfaceType); ok {
+			return fmt.Errorf("input type %q must be a concrete type", o.InputType)
+		}
+	}
+	if o.Style != StyleHandler && o.Style != StyleFuncInstance && o.Style != StyleCloudEvents {
+		return fmt.Errorf("unsupported style %q", o.Style)
+	}
+	if o.Style == StyleCloudEvents && (o.InputSource != InputSourceBody || o.PoolBuffers || o.Recover || o.ProtoJSON || o.PrettyOutput || o.JSONNumbers || o.StrictJSON || len(o.Methods) > 0 || len(o.ResponseHeaders) > 0 || o.Timeout > 0 || len(o.CloudEventAttrs) > 0 || len(o.StatusFor) > 0 || o.InjectLogger || o.ValidateOutput || o.Envelope || o.Metrics || o.MaxConcurrency > 0) {
+		return fmt.Errorf("the %s style serves events instead of HTTP requests, it can't be combined with other input sources than %s, pooled buffers, recovering from panics, protojson, pretty output, JSON numbers, strict JSON, request methods, response headers, timeouts, CloudEvent attributes, success statuses, injected loggers, output validation, envelopes, metrics, or a maximum concurrency", StyleCloudEvents, InputSourceBody)
+	}
+	if o.ResponseSource != "" && o.Style != StyleCloudEvents {
+		return fmt.Errorf("a response source requires the %s style", StyleCloudEvents)
+	}
+	if o.LambdaModule != "" {
+		if err := module.CheckImportPath(o.LambdaModule); err != nil {
+			return fmt.Errorf("invalid Lambda module path: %w", err)
+		}
+	}
+	if o.Readyz && o.Style != StyleFuncInstance {
+		return fmt.Errorf("readiness reporting requires the %s style, which runs the initialization", StyleFuncInstance)
+	}
+	if o.PanicStatus && !o.Recover {
+		return fmt.Errorf("panic status requires recovering from panics")
+	}
+	if o.NoStack && !o.Recover {
+		return fmt.Errorf("suppressing stack traces requires recovering from panics")
+	}
+	if o.MaxConcurrency < 0 {
+		return fmt.Errorf("invalid maximum concurrency %d", o.MaxConcurrency)
+	}
+	if o.Timeout < 0 || (o.Timeout > 0 && o.Timeout < time.Millisecond) {
+		return fmt.Errorf("invalid timeout %v, must be at least 1ms", o.Timeout)
+	}
+	for _, method := range o.Methods {
+		if method == "" || strings.ContainsAny(method, " \t,") {
+			return fmt.Errorf("invalid request method %q", method)
+		}
+	}
+	for name, values := range o.ResponseHeaders {
+		if !isHeaderName(name) {
+			return fmt.Errorf("invalid response header name %q", name)
+		}
+		for _, value := range values {
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("invalid value %q of response header %s", value, name)
+			}
+		}
+	}
+	for _, attr := range o.CloudEventAttrs {
+		if !isCloudEventAttributeName(attr) {
+			return fmt.Errorf("invalid CloudEvent attribute name %q, must consist of lowercase letters and digits", attr)
+		}
+	}
+	for typeName, status := range o.StatusFor {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid status %d for output type %s", status, typeName)
+		}
+	}
+	return nil
+}
+
+// Transform migrates the Lambda handler in src using the default options and returns the Knative function source
+func Transform(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := TransformTo(&buf, src, DefaultOptions()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TransformTo migrates the Lambda handler in src and writes the Knative function source to w
+func TransformTo(w io.Writer, src []byte, opts Options) error {
+	m, err := prepare(src, opts)
+	if err != nil {
+		return err
+	}
+	// The notes describe the changes planned on the original file
+	var notes *migrationNotes
+	if opts.Notes != nil {
+		notes = &migrationNotes{
+			filename:   opts.Filename,
+			handlerRef: m.handlerRef,
+			handlerSig: m.handlerSig,
+			plan:       planMigration(m.file, m.handlerRef, m.handlerSig, &opts.GenerateOptions, opts.RewriteDeadline && len(m.deadlineCalls) > 0, m.rewritesAWSConfig(opts)),
+			envVars:    findEnvVars(m.file),
+		}
+	}
+	m.handleDeadlineCalls(opts)
+	m.handleAWSConfigCalls(opts)
+	m.handleXRay(opts)
+
+	// Transform the AST
+	if err := dumpAST(opts.DumpAST, "before", m.fset, m.file); err != nil {
+		return err
+	}
+	transformAST(m.file, m.handlerRef, m.handlerSig, &opts.GenerateOptions)
+	if err := dumpAST(opts.DumpAST, "after", m.fset, m.file); err != nil {
+		return err
+	}
+
+	// Print the modified AST
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, m.fset, m.file); err != nil {
+		return fmt.Errorf("failed to print modified code: %w", err)
+	}
+	out := buf.Bytes()
+	if opts.Format {
+		out = formatSource(m.report, out)
+	}
+	if opts.KeepPositions {
+		// The declarations the generated code moved are attributed back to their lines in the source
+		out, err = addLineDirectives(out, m.file, m.fset)
+		if err != nil {
+			return fmt.Errorf("failed to keep source positions: %w", err)
+		}
+	}
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to print modified code: %w", err)
+	}
+
+	if opts.OpenAPI != nil {
+		if err := writeOpenAPI(opts.OpenAPI, m.handlerRef, m.handlerSig); err != nil {
+			return fmt.Errorf("failed to write OpenAPI document: %w", err)
+		}
+	}
+	if opts.Bench != nil {
+		if err := writeBenchmark(opts.Bench, m.file.Name.Name, m.handlerSig, &opts.GenerateOptions); err != nil {
+			return fmt.Errorf("failed to write benchmark: %w", err)
+		}
+	}
+	if opts.OutputValidator != nil {
+		if err := writeOutputValidator(opts.OutputValidator, m.file.Name.Name, m.handlerSig, &opts.GenerateOptions); err != nil {
+			return fmt.Errorf("failed to write output validator: %w", err)
+		}
+	}
+	if opts.Invoke != nil {
+		if err := writeInvokeExample(opts.Invoke, m.handlerRef, m.handlerSig, &opts.GenerateOptions); err != nil {
+			return fmt.Errorf("failed to write invoke example: %w", err)
+		}
+	}
+	if opts.Readme != nil {
+		if err := writeReadme(opts.Readme, opts.Filename, m.handlerRef, m.handlerSig, &opts.GenerateOptions); err != nil {
+			return fmt.Errorf("failed to write README: %w", err)
+		}
+	}
+	if opts.Manifest != nil {
+		*opts.Manifest = FuncManifest{Name: manifestName(m.handlerRef), Invocation: manifestInvocation(&opts.GenerateOptions)}
+	}
+	if notes != nil {
+		notes.warnings = m.report.warnings
+		if err := writeNotes(opts.Notes, notes); err != nil {
+			return fmt.Errorf("failed to write migration notes: %w", err)
+		}
+	}
+	return m.report.check(opts.FailOnWarning)
+}
+
+// PrintPlan writes the import and declaration changes migrating src would make to w, without transforming anything
+func PrintPlan(w io.Writer, src []byte, opts Options) error {
+	m, err := prepare(src, opts)
+	if err != nil {
+		return err
+	}
+
+	printPlan(w, m.file, m.handlerRef, m.handlerSig, &opts.GenerateOptions, opts.RewriteDeadline && len(m.deadlineCalls) > 0, m.rewritesAWSConfig(opts))
+	return m.report.check(opts.FailOnWarning)
+}
+
+// ListHandlers writes every Lambda handler detected in src with its signature shape and event type to w
+func ListHandlers(w io.Writer, src []byte, opts Options) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, opts.Filename, src, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	report := newReporter(opts.Log, fset)
+	if err := listHandlers(w, report, opts.Filename, file, fset, opts.HandlerPattern, opts.lambdaModule(), opts.LegacyStartFuncs); err != nil {
+		return fmt.Errorf("failed to list lambda handlers: %w", err)
+	}
+	return report.check(opts.FailOnWarning)
+}
+
+// migration holds the analyzed state of a source file about to be migrated
+type migration struct {
+	report         *reporter
+	fset           *token.FileSet
+	file           *ast.File
+	handlerRef     *HandlerReference
+	handlerSig     *HandlerSignature
+	deadlineCalls  []deadlineCall
+	awsConfigCalls []*ast.CallExpr
+}
+
+// prepare parses src and analyzes its Lambda handler
+func prepare(src []byte, opts Options) (*migration, error) {
+	m, err := prepareWith(newReporter(opts.Log, token.NewFileSet()), src, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The setup of main is kept in the initialization run by Start or in New, which can't define what the
+	// handler refers to
+	checkSetup(m.report, m.file, m.handlerRef, &opts.GenerateOptions)
+	return m, nil
+}
+
+// prepareWith parses src into the file set of the reporter and analyzes its Lambda handler
+func prepareWith(report *reporter, src []byte, opts Options) (*migration, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	if opts.Style == StyleCloudEvents && (opts.OpenAPI != nil || opts.Bench != nil) {
+		return nil, fmt.Errorf("OpenAPI documents and benchmarks describe HTTP requests, they can't be written with the %s style", StyleCloudEvents)
+	}
+
+	m := &migration{report: report, fset: report.fset}
+
+	// Parse the Go source code
+	var err error
+	m.file, err = parser.ParseFile(m.fset, opts.Filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	// Find the lambda.Start call and extract handler reference
+	m.handlerRef, err = findLambdaHandler(m.report, m.file, opts.Handler, opts.HandlerPattern, opts.lambdaModule(), opts.LegacyStartFuncs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find lambda handler: %w", err)
+	}
+
+	m.report.logf("Found Lambda handler: %s", m.handlerRef.QualifiedName)
+
+	// The generated code is declared at package level, out of scope of the variables local to the registrar
+	if receiver := m.handlerRef.localReceiver(); receiver != "" {
+		return nil, fmt.Errorf("handler %s is a method of %s, which is local to %s and not in scope of the generated code; declare %s at package level", m.handlerRef.QualifiedName, receiver, m.handlerRef.registrar.Name.Name, receiver)
+	}
+
+	// The handler package can't contradict the package the handler is selected from
+	if opts.HandlerPackage != "" {
+		if importPath := m.handlerRef.importPath(m.file); importPath != "" && importPath != opts.HandlerPackage {
+			return nil, fmt.Errorf("handler %s is declared in package %s, not %s", m.handlerRef.QualifiedName, importPath, opts.HandlerPackage)
+		}
+		m.handlerRef.Package = opts.HandlerPackage
+	}
+
+	// Analyze the handler function signature
+	// Protobuf messages are detected by their method sets, schemas are derived from the input and output
+	// types, sample requests are derived from the input type, statuses are mapped by resolved output type,
+	// outputs are validated against their schema, and envelope results are detected by their fields, which
+	// requires the type checker
+	requireTypes := opts.ProtoJSON || opts.OpenAPI != nil || opts.Invoke != nil || opts.Readme != nil || opts.Bench != nil || opts.ValidateOutput || opts.Envelope || len(opts.StatusFor) > 0
+	m.handlerSig, err = resolveHandlerSignature(m.report, opts.Filename, m.file, m.fset, m.handlerRef, opts.SignatureMap, requireTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze handler signature: %w", err)
+	}
+
+	// Function literal handlers are declared as functions the generated code calls
+	hoistLiteralHandler(m.report, m.file, m.handlerRef)
+
+	// lambda.Handler values return the JSON payload of the response, which is already encoded
+	m.handlerSig.RawOutput = m.handlerRef.Invoked && m.handlerSig.OutputType == "[]byte"
+
+	// The data field of envelope results is the output, which must be known before anything inspects it
+	if opts.Envelope {
+		if err := m.handlerSig.useEnvelope(); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.handlerRef.QualifiedName, err)
+		}
+	}
+
+	// JSON only decodes into interfaces without methods, so interface inputs are decoded into the concrete type
+	if m.handlerSig.InputIsInterface && opts.InputType == "" {
+		return nil, fmt.Errorf("%s takes the interface input %s: %w", m.handlerRef.QualifiedName, m.handlerSig.InputType, errInterfaceInput)
+	}
+	if opts.InputType != "" && !m.handlerSig.InputIsInterface {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "the input of %s isn't an interface, -input-type has no effect", m.handlerRef.QualifiedName)
+	}
+
+	// The request context replaces the one Lambda derived the invocation contexts from
+	if ctx := m.handlerRef.startCtx; ctx != nil && !isEmptyContext(m.file, ctx) {
+		m.report.warnf(ctx.Pos(), "%s is started with the context %s, which the request contexts passed to the handler don't derive from; values and cancellation it carries don't reach the handler", m.handlerRef.QualifiedName, types.ExprString(ctx))
+	}
+
+	// The generated handler can't stop a timed out handler from writing the response
+	if opts.Timeout > 0 && m.handlerSig.HasWriter {
+		return nil, fmt.Errorf("%s: %w", m.handlerRef.QualifiedName, errTimeoutWriterHandler)
+	}
+
+	// Only outputs encoded as JSON are described by the output schema
+	if opts.ValidateOutput && !encodesJSONOutput(m.handlerSig, &opts.GenerateOptions) {
+		return nil, fmt.Errorf("%s: %w", m.handlerRef.QualifiedName, errUnvalidatedOutput)
+	}
+
+	// Only the event data is passed to the handler, whose output becomes the data of the response event
+	if opts.Style == StyleCloudEvents {
+		if err := checkCloudEventsHandler(m.handlerSig); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.handlerRef.QualifiedName, err)
+		}
+	}
+
+	// WebSocket events can't be served by a plain HTTP handler
+	if err := checkWebsocketHandler(m.report, m.file, m.handlerRef, m.handlerSig); err != nil {
+		return nil, err
+	}
+
+	if opts.Normalize {
+		if err := normalizeHandlerName(m.file, m.handlerRef, NormalizedHandlerName); err != nil {
+			return nil, fmt.Errorf("failed to normalize handler name: %w", err)
+		}
+	}
+
+	if m.handlerSig.InputTypeID == cloudFrontRequestID || m.handlerSig.OutputTypeID == cloudFrontResponseID {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s is a Lambda@Edge handler; the generated mapping does not enforce CloudFront's header restrictions and size limits, review them before relying on the migrated function", m.handlerRef.QualifiedName)
+	}
+
+	if mapsAPIGatewayRequest(m.handlerSig) {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s takes an API Gateway proxy request; only its method, path, headers, query parameters, and body are mapped from the HTTP request, review the uses of its resource, path parameters, stage variables, and request context before relying on the migrated function", m.handlerRef.QualifiedName)
+	}
+
+	if m.handlerSig.ContextType != "" {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s takes the custom context %s; the request context only implements context.Context, adapt the handler to accept it before building the migrated function", m.handlerRef.QualifiedName, m.handlerSig.ContextType)
+	}
+
+	if m.handlerRef.Decorated {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s is evaluated for every request instead of once at startup like with lambda.Start; move state the decorator creates (e.g., caches or rate limiters) to package level", m.handlerRef.QualifiedName)
+	}
+
+	if opts.InjectLogger && !m.handlerSig.HasContext && !injectsLoggerParam(m.handlerSig, &opts.GenerateOptions) {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s takes no context or *slog.Logger, the injected logger can't be read", m.handlerRef.QualifiedName)
+	}
+
+	if len(opts.CloudEventAttrs) > 0 && !m.handlerSig.HasContext {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s takes no context, the CloudEvent attributes stored in it can't be read", m.handlerRef.QualifiedName)
+	}
+
+	if opts.Config && len(findEnvVars(m.file)) == 0 {
+		m.report.warnf(token.NoPos, "no environment variables are read with os.Getenv or os.LookupEnv in %s, -emit-config has no effect", opts.Filename)
+	}
+
+	if !opts.NoTmpAdvisory {
+		for _, call := range findTmpWrites(m.file, m.handlerRef.SimpleName) {
+			m.report.warnf(call.Pos(), "%s writes to /tmp; unlike Lambda's per-function /tmp, the filesystem of Knative containers is ephemeral node storage that may be size-limited or read-only, reassess the scratch space assumptions", m.handlerRef.QualifiedName)
+		}
+	}
+
+	for _, ref := range findLambdaContextRefs(m.file, opts.lambdaModule(), m.handlerRef.removedFuncs(m.file)) {
+		m.report.warnf(ref.Pos(), "%s depends on the Lambda runtime, which doesn't run the migrated function: lambdacontext.FromContext finds no Lambda context and the function settings (e.g., lambdacontext.FunctionName) are empty; derive what it reads from the request or the environment instead", types.ExprString(ref))
+	}
+
+	if !opts.NoGlobalsAdvisory {
+		for _, ident := range findGlobalWrites(m.file, m.handlerRef.SimpleName) {
+			m.report.warnf(ident.Pos(), "%s writes the package-level variable %s (declared on line %d) without synchronization; unlike Lambda, Knative may serve requests concurrently in the same instance, guard it with a mutex or use sync/atomic", m.handlerRef.QualifiedName, ident.Name, m.fset.Position(ident.Obj.Pos()).Line)
+		}
+	}
+
+	if !opts.NoReflectAdvisory {
+		for _, call := range findInputReflection(m.file, m.handlerRef.SimpleName, m.handlerSig) {
+			m.report.warnf(call.Pos(), "%s passes its input to %s; the generated code decodes the request body into the declared input type %s, review whether the reflection relies on how the Lambda runtime decoded events", m.handlerRef.QualifiedName, types.ExprString(call.Fun), m.handlerSig.InputType)
+		}
+	}
+
+	if opts.ProtoJSON && !m.handlerSig.InputIsProto && !m.handlerSig.OutputIsProto {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "neither the input nor the output of %s is a protobuf message, -protojson has no effect", m.handlerRef.QualifiedName)
+	}
+
+	if opts.JSONNumbers && !decodesInput(m.handlerSig, &opts.GenerateOptions) {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "the request body isn't decoded into the input of %s, -json-numbers has no effect", m.handlerRef.QualifiedName)
+	}
+	if opts.StrictJSON && !decodesInput(m.handlerSig, &opts.GenerateOptions) {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "the request body isn't decoded into the input of %s, -strict-json has no effect", m.handlerRef.QualifiedName)
+	}
+
+	if len(opts.StatusFor) > 0 && !m.handlerSig.HasOutput {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "%s returns no output, -status-for has no effect", m.handlerRef.QualifiedName)
+	} else if len(opts.StatusFor) > 0 && successStatus(m.handlerSig, &opts.GenerateOptions) == 0 {
+		m.report.warnf(m.handlerRef.Expr.Pos(), "no -status-for type matches the output type %q of %s, responding with the default status", m.handlerSig.OutputType, m.handlerRef.QualifiedName)
+	}
+
+	m.deadlineCalls = findDeadlineCalls(m.file, m.handlerRef.QualifiedName)
+	m.awsConfigCalls = findAWSConfigCalls(m.file)
+
+	// The generated code refers to the packages it imports by name, which may be taken by another import or
+	// a declaration of the source
+	plan := planMigration(m.file, m.handlerRef, m.handlerSig, &opts.GenerateOptions, opts.RewriteDeadline && len(m.deadlineCalls) > 0, m.rewritesAWSConfig(opts))
+	if conflicts := importConflicts(m.file, plan.addedImports); len(conflicts) > 0 {
+		if !opts.RenameConflictingImports {
+			return nil, fmt.Errorf("%w: %s (see -rename-conflicting-imports)", errImportConflict, strings.Join(conflicts, ", "))
+		}
+		m.report.logf("Renaming the imports whose name is taken: %s", strings.Join(conflicts, ", "))
+	}
+	return m, nil
+}
+
+// handleDeadlineCalls rewrites the handler's ctx.Deadline() calls if requested and warns about them otherwise
+func (m *migration) handleDeadlineCalls(opts Options) {
+	// Knative requests carry no deadline by default, so ctx.Deadline() based logic needs attention
+	if opts.RewriteDeadline {
+		rewriteDeadlineCalls(m.file, m.deadlineCalls, opts.DeadlineDefault)
+	} else {
+		for _, call := range m.deadlineCalls {
+			m.report.warnf(call.Pos(), "handler calls ctx.Deadline(), which reports no deadline under Knative unless one is configured (see -rewrite-deadline)")
+		}
+	}
+}
+
+// rewritesAWSConfig reports whether a region is passed to any of the config.LoadDefaultConfig calls
+func (m *migration) rewritesAWSConfig(opts Options) bool {
+	if opts.AWSRegionEnv == "" {
+		return false
+	}
+	for _, call := range m.awsConfigCalls {
+		if !setsRegion(m.file, call) && !call.Ellipsis.IsValid() {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAWSConfigCalls warns about the config.LoadDefaultConfig calls, which rely on the credentials and region
+// Lambda provides, passing the region from the environment to them if requested
+func (m *migration) handleAWSConfigCalls(opts Options) {
+	if opts.AWSRegionEnv != "" {
+		rewriteAWSConfigCalls(m.file, m.awsConfigCalls, opts.AWSRegionEnv)
+	}
+	if opts.NoAWSConfigAdvisory {
+		return
+	}
+	for _, call := range m.awsConfigCalls {
+		m.report.warnf(call.Pos(), "config.LoadDefaultConfig relies on the credentials of the Lambda execution role and the region Lambda sets in AWS_REGION; provide credentials to the Knative service (e.g. via IRSA or a secret with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY) and a region (see -aws-region-env)")
+	}
+}
+
+// handleXRay strips the X-Ray instrumentation if requested, and warns about the imports and uses of the X-Ray
+// SDK left, which record no traces without the X-Ray daemon and trace header Lambda provides
+func (m *migration) handleXRay(opts Options) {
+	hint := "see -strip-xray"
+	if opts.StripXRay {
+		if stripped := stripXRay(m.file); stripped > 0 {
+			m.report.logf("Stripped %d X-Ray instrumentation calls", stripped)
+		}
+		hint = "-strip-xray can't remove it, do so by hand"
+	}
+	for _, importSpec := range findXRayImports(m.file) {
+		m.report.warnf(importSpec.Pos(), "%s of the AWS X-Ray SDK is imported; the X-Ray daemon and trace header Lambda provides aren't available under Knative, instrument the function with OpenTelemetry instead", importSpec.Path.Value)
+	}
+	for _, ref := range findXRayRefs(m.file) {
+		m.report.warnf(ref.Pos(), "%s of the AWS X-Ray SDK records no traces under Knative (%s)", types.ExprString(ref), hint)
+	}
+	for _, call := range findXRayEnvReads(m.file) {
+		m.report.warnf(call.Pos(), "%s reads %s, which Lambda sets for X-Ray tracing but Knative doesn't", types.ExprString(call.Fun), types.ExprString(call.Args[0]))
+	}
+}
+
+// dumpAST writes the AST of the file to w, labeled with the stage of the transformation, if w isn't nil
+func dumpAST(w io.Writer, stage string, fset *token.FileSet, file *ast.File) error {
+	if w == nil {
+		return nil
+	}
+	fmt.Fprintf(w, "// AST %s transformation\n", stage)
+	if err := ast.Fprint(w, fset, file, ast.NotNilFilter); err != nil {
+		return fmt.Errorf("failed to dump AST %s transformation: %w", stage, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package migrator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// findModuleRoot returns the closest directory containing a go.mod file, starting at dir and walking up
+func findModuleRoot(dir string) (string, bool) {
+	for root := dir; ; {
+		if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+			return root, true
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", false
+		}
+		root = parent
+	}
+}
+
+// packageImportPath returns the root of the module containing dir and the import path of the package in dir,
+// derived from the module path declared in go.mod
+func packageImportPath(dir string) (string, string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	root, ok := findModuleRoot(absDir)
+	if !ok {
+		return "", "", fmt.Errorf("no go.mod found for %s", dir)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	modulePath := modfile.ModulePath(data)
+	if modulePath == "" {
+		return "", "", fmt.Errorf("no module path declared in %s", filepath.Join(root, "go.mod"))
+	}
+
+	rel, err := filepath.Rel(root, absDir)
+	if err != nil {
+		return "", "", err
+	}
+	return root, path.Join(modulePath, filepath.ToSlash(rel)), nil
+}
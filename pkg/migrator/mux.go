@@ -0,0 +1,420 @@
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Route maps a request path to the Lambda handler registered in a source file
+type Route struct {
+	Path     string // Request path served by the handler (e.g., "/orders")
+	Src      []byte // Source registering the handler
+	Filename string // Path of the source file, needed to type check handlers declared in other files or packages
+	Handler  string // Name of the handler to migrate if the source registers several (defaults to the first)
+}
+
+// TransformRoutesTo migrates the Lambda handlers of several sources into a single Knative function whose
+// Handle method dispatches requests by path to a generated method per handler, and writes its source to w.
+// The imports and declarations of the other sources are merged into the first one, whose main function is
+// replaced. Comments of the other sources outside their declarations are dropped.
+func TransformRoutesTo(w io.Writer, routes []Route, opts Options) error {
+	if len(routes) == 0 {
+		return fmt.Errorf("no routes given")
+	}
+	if opts.BasePath != "" && !strings.HasPrefix(opts.BasePath, "/") {
+		return fmt.Errorf("base path %q doesn't start with /", opts.BasePath)
+	}
+	if err := checkMergedOptions(opts); err != nil {
+		return err
+	}
+
+	report := newReporter(opts.Log, token.NewFileSet())
+	migrations := make([]*migration, len(routes))
+	paths := make(map[string]bool, len(routes))
+	for i, route := range routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("route path %q doesn't start with /", route.Path)
+		}
+		if paths[route.Path] {
+			return fmt.Errorf("route path %s is given more than once", route.Path)
+		}
+		paths[route.Path] = true
+
+		routeOpts := opts
+		routeOpts.Filename = route.Filename
+		routeOpts.Handler = route.Handler
+		m, err := prepareMerged(report, route.Src, routeOpts)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", route.Path, err)
+		}
+		migrations[i] = m
+	}
+
+	return transformMerged(w, report, migrations, opts, func(file *ast.File, declared map[string]bool) {
+		transformRoutesAST(file, routes, strings.TrimSuffix(opts.BasePath, "/"), migrations, declared, &opts.GenerateOptions)
+	})
+}
+
+// checkMergedOptions returns an error if the options can't be applied to several handlers migrated into one
+// Knative function
+func checkMergedOptions(opts Options) error {
+	if opts.HandlerPackage != "" || opts.Normalize || opts.RewriteDeadline || opts.OpenAPI != nil || opts.Invoke != nil || opts.Bench != nil || opts.ValidateOutput || opts.Notes != nil || opts.Readme != nil || opts.Manifest != nil || opts.Config || opts.Style != StyleHandler || len(opts.CloudEventAttrs) > 0 || opts.InjectLogger || opts.Metrics || opts.Envelope || opts.InputType != "" || opts.KeepPositions {
+		return fmt.Errorf("selecting handler packages, normalizing handler names, rewriting deadlines, generating config structs or func instances, storing CloudEvent attributes or loggers in the context, counting invocations, responding with envelope errors, decoding interface inputs into a concrete type, keeping source positions, and writing OpenAPI documents, invoke examples, benchmarks, output validators, migration notes, READMEs, or func manifests are not supported for several handlers")
+	}
+	return nil
+}
+
+// prepareMerged prepares the migration of a source whose file is merged with others, handling the calls the
+// options rewrite and removing its lambda import
+func prepareMerged(report *reporter, src []byte, opts Options) (*migration, error) {
+	m, err := prepareWith(report, src, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The mains of merged files are replaced along with their setup
+	checkDroppedSetup(m.report, m.file, m.handlerRef)
+	m.handleDeadlineCalls(opts)
+	m.handleAWSConfigCalls(opts)
+	m.handleXRay(opts)
+	removeLambdaImport(m.file, opts.lambdaModule())
+	return m, nil
+}
+
+// transformMerged merges the files of the other migrations into the first one, replaces its main function with
+// transform, and writes the source to w
+func transformMerged(w io.Writer, report *reporter, migrations []*migration, opts Options, transform func(file *ast.File, declared map[string]bool)) error {
+	// Imports only the mains referred to are removed once merged
+	var files []ast.Node
+	for _, m := range migrations {
+		files = append(files, m.file)
+	}
+	refsBefore := packageRefs(files...)
+
+	base := migrations[0]
+	declared := declaredNames(base.file)
+	merged := make([][]ast.Decl, len(migrations))
+	for i, m := range migrations[1:] {
+		decls, err := mergeFile(base.file, m, declared)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", report.fset.File(m.file.Pos()).Name(), err)
+		}
+		merged[i+1] = decls
+	}
+
+	transform(base.file, declared)
+	refsAfter := []ast.Node{base.file}
+	for _, decls := range merged {
+		for _, decl := range decls {
+			refsAfter = append(refsAfter, decl)
+		}
+	}
+	removeUnusedImports(base.file, refsBefore, packageRefs(refsAfter...))
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, report.fset, base.file); err != nil {
+		return fmt.Errorf("failed to print modified code: %w", err)
+	}
+
+	// The printer places comments by their offset in the file, so the merged declarations are printed
+	// along with the comments of their own file
+	for i, decls := range merged {
+		for _, decl := range decls {
+			buf.WriteString("\n")
+			node := &printer.CommentedNode{Node: decl, Comments: migrations[i].file.Comments}
+			if err := printer.Fprint(&buf, report.fset, node); err != nil {
+				return fmt.Errorf("failed to print modified code: %w", err)
+			}
+			buf.WriteString("\n")
+		}
+	}
+
+	out := buf.Bytes()
+	if opts.Format {
+		out = formatSource(report, out)
+	}
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("failed to print modified code: %w", err)
+	}
+	return report.check(opts.FailOnWarning)
+}
+
+// mergeFile adds the imports of the migrated file to dst and returns its declarations other than main, the init
+// function registering its handler, and its imports. Imports whose package name is taken by another import of
+// dst are renamed along with their uses; declarations colliding with the already declared names are errors.
+func mergeFile(dst *ast.File, m *migration, declared map[string]bool) ([]ast.Decl, error) {
+	importNames := make(map[string]string) // package name to import path
+	importsByPath := make(map[string]*ast.ImportSpec)
+	var dstImports *ast.GenDecl
+	for _, decl := range dst.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			dstImports = genDecl
+			for _, spec := range genDecl.Specs {
+				importSpec := spec.(*ast.ImportSpec)
+				importPath := strings.Trim(importSpec.Path.Value, `"`)
+				importNames[importName(importSpec)] = importPath
+				importsByPath[importPath] = importSpec
+			}
+		}
+	}
+	if dstImports == nil {
+		dstImports = &ast.GenDecl{Tok: token.IMPORT}
+		dst.Decls = append([]ast.Decl{dstImports}, dst.Decls...)
+	}
+
+	var decls []ast.Decl
+	for _, decl := range m.file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			for _, spec := range genDecl.Specs {
+				importSpec := spec.(*ast.ImportSpec)
+				importPath := strings.Trim(importSpec.Path.Value, `"`)
+				name := importName(importSpec)
+				if existing, ok := importsByPath[importPath]; ok {
+					if existingName := importName(existing); existingName != name {
+						renamePackage(m, name, existingName)
+					}
+					continue
+				}
+
+				newSpec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(importPath)}}
+				if importSpec.Name != nil {
+					newSpec.Name = ast.NewIdent(importSpec.Name.Name)
+				}
+				if _, taken := importNames[name]; taken && name != "_" && name != "." {
+					newName := name
+					for i := 2; importNames[newName] != ""; i++ {
+						newName = name + strconv.Itoa(i)
+					}
+					renamePackage(m, name, newName)
+					newSpec.Name = ast.NewIdent(newName)
+					name = newName
+				}
+				importNames[name] = importPath
+				importsByPath[importPath] = newSpec
+				dstImports.Specs = append(dstImports.Specs, newSpec)
+			}
+			continue
+		}
+		if fn, ok := decl.(*ast.FuncDecl); ok && slices.Contains(m.handlerRef.removedFuncs(m.file), fn) {
+			continue
+		}
+		if decl == m.handlerRef.hoisted {
+			renameLiteralHandler(m.handlerRef, declared)
+		}
+
+		for _, name := range declNames(decl) {
+			if declared[name] {
+				return nil, fmt.Errorf("%s is declared by several sources", name)
+			}
+			declared[name] = true
+		}
+		decls = append(decls, decl)
+	}
+	return decls, nil
+}
+
+// importName returns the name the import is referenced by (e.g., "handler" for ".../pkg/handler")
+func importName(importSpec *ast.ImportSpec) string {
+	if importSpec.Name != nil {
+		return importSpec.Name.Name
+	}
+	return assumedPackageName(strings.Trim(importSpec.Path.Value, `"`))
+}
+
+// renamePackage renames the references to the package imported as oldName in the migrated file,
+// its handler reference, and its signature's type expressions
+func renamePackage(m *migration, oldName, newName string) {
+	rename := func(n ast.Node) bool {
+		if selExpr, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Name == oldName && ident.Obj == nil {
+				ident.Name = newName
+			}
+		}
+		return true
+	}
+	for _, decl := range m.file.Decls {
+		ast.Inspect(decl, rename)
+	}
+	for _, node := range []ast.Expr{m.handlerRef.Expr, m.handlerSig.InputTypeExpr, m.handlerSig.OutputTypeExpr} {
+		if node != nil {
+			ast.Inspect(node, rename)
+		}
+	}
+	if importPath, ok := m.handlerSig.TypeImports[oldName]; ok {
+		delete(m.handlerSig.TypeImports, oldName)
+		m.handlerSig.TypeImports[newName] = importPath
+	}
+}
+
+// declaredNames returns the names of the package-level declarations of the file
+func declaredNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		for _, name := range declNames(decl) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// declNames returns the names declared by a package-level declaration, with methods qualified by
+// their receiver type; init functions and blank identifiers may be declared several times
+func declNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			names = append(names, recvTypeName(d.Recv.List[0].Type)+"."+d.Name.Name)
+		} else if d.Name.Name != "init" {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					if name.Name != "_" {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// recvTypeName returns the name of the type of a method receiver (e.g., "T" for "*T[K]")
+func recvTypeName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident := baseIdent(expr); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
+
+// transformRoutesAST replaces main() with the Knative handler structure, whose Handle method dispatches
+// requests by path, under the base path, to a method wrapping each migrated handler
+func transformRoutesAST(file *ast.File, routes []Route, basePath string, migrations []*migration, declared map[string]bool, opts *GenerateOptions) {
+	aliases := make(map[string]string)
+	poolBuffers := false
+	for _, m := range migrations {
+		for path, alias := range addRequiredImports(file, m.handlerSig, opts) {
+			aliases[path] = alias
+		}
+		poolBuffers = poolBuffers || poolsBuffers(m.handlerSig, opts)
+	}
+	anchorImports(file)
+
+	var wrappers []ast.Decl
+	var cases []ast.Stmt
+	for i, m := range migrations {
+		wrapper := createHandleMethod(copyExpr(m.handlerRef.Expr), aliases, m.handlerSig, opts)
+		wrapper.Name = ast.NewIdent(wrapperName(m.handlerRef.SimpleName, declared))
+		wrappers = append(wrappers, wrapper)
+
+		cases = append(cases, &ast.CaseClause{
+			List: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(basePath + routes[i].Path)}},
+			Body: []ast.Stmt{
+				&ast.ExprStmt{
+					X: &ast.CallExpr{
+						Fun:  &ast.SelectorExpr{X: ast.NewIdent("h"), Sel: wrapper.Name},
+						Args: []ast.Expr{ast.NewIdent("ctx"), ast.NewIdent("w"), ast.NewIdent("r")},
+					},
+				},
+			},
+		})
+	}
+
+	// Unknown paths, including the routes outside the base path, aren't served by any handler
+	cases = append(cases, &ast.CaseClause{
+		Body: []ast.Stmt{
+			&ast.ExprStmt{
+				X: &ast.CallExpr{
+					Fun:  &ast.SelectorExpr{X: ast.NewIdent(aliases["net/http"]), Sel: ast.NewIdent("NotFound")},
+					Args: []ast.Expr{ast.NewIdent("w"), ast.NewIdent("r")},
+				},
+			},
+		},
+	})
+
+	// The dispatching Handle method shares the signature of the wrappers
+	first := wrappers[0].(*ast.FuncDecl)
+	handleMethod := &ast.FuncDecl{
+		Recv: first.Recv,
+		Name: ast.NewIdent("Handle"),
+		Type: first.Type,
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.SwitchStmt{
+					Tag: &ast.SelectorExpr{
+						X:   &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("URL")},
+						Sel: ast.NewIdent("Path"),
+					},
+					Body: &ast.BlockStmt{List: cases},
+				},
+			},
+		},
+	}
+
+	generated := make([]ast.Decl, 0, len(wrappers)+4)
+	if poolBuffers {
+		generated = append(generated, createBufferPoolDecl(aliases["sync"], aliases["bytes"]))
+	}
+	handlerStruct, newFunc := createHandlerStruct(generatedTypeName(opts)), createNewFunc(generatedTypeName(opts))
+	if opts.MaxConcurrency > 0 {
+		addSemaphoreField(handlerStruct, newFunc, opts.MaxConcurrency)
+	}
+	generated = append(generated, handlerStruct, newFunc, handleMethod)
+	replaceRegistrar(file, migrations[0].handlerRef, append(generated, wrappers...))
+}
+
+// replaceRegistrar replaces the function registering the handler, main or an init function along with main,
+// with the generated declarations, preceded by the function a function literal handler was hoisted into
+func replaceRegistrar(file *ast.File, handlerRef *HandlerReference, generated []ast.Decl) {
+	for _, fn := range handlerRef.removedFuncs(file)[1:] {
+		removeFunc(file, fn)
+	}
+	hoisted := handlerRef.hoisted
+	if hoisted != nil {
+		file.Decls = slices.DeleteFunc(file.Decls, func(decl ast.Decl) bool { return decl == hoisted })
+	}
+	for i, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn == handlerRef.registrar {
+			newDecls := make([]ast.Decl, 0, len(file.Decls)+len(generated)+1)
+			newDecls = append(newDecls, file.Decls[:i]...)
+			if hoisted != nil {
+				newDecls = append(newDecls, hoisted)
+			}
+			newDecls = append(newDecls, generated...)
+			newDecls = append(newDecls, file.Decls[i+1:]...)
+			file.Decls = newDecls
+			break
+		}
+	}
+}
+
+// wrapperName returns an unused name for the method wrapping the handler (e.g., "serveGetOrder" for "getOrder")
+// and marks it as used
+func wrapperName(handlerName string, declared map[string]bool) string {
+	first, size := utf8.DecodeRuneInString(handlerName)
+	base := "serve" + string(unicode.ToUpper(first)) + handlerName[size:]
+	name := base
+	for i := 2; declared["Handler."+name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	declared["Handler."+name] = true
+	return name
+}
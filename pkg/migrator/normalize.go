@@ -0,0 +1,68 @@
+package migrator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+)
+
+// NormalizedHandlerName is the canonical name handlers are renamed to with -normalize
+const NormalizedHandlerName = "handleRequest"
+
+// normalizeHandlerName renames the handler declared in the file and every reference to it. References are
+// matched by their resolved declaration, so shadowing local identifiers of the same name stay untouched
+func normalizeHandlerName(file *ast.File, handlerRef *HandlerReference, name string) error {
+	if handlerRef.Decorated {
+		return fmt.Errorf("handler %s is returned by a decorator, whose name normalizing would change instead", handlerRef.QualifiedName)
+	}
+	handlerIdent := baseIdent(handlerRef.Expr)
+	if handlerIdent == nil || handlerIdent.Obj == nil || handlerIdent.Obj.Decl == nil {
+		return fmt.Errorf("handler %s is not declared in the input file", handlerRef.QualifiedName)
+	}
+	if handlerIdent.Name == name {
+		return nil
+	}
+
+	// Don't clobber other package-level declarations or imports
+	if file.Scope.Lookup(name) != nil {
+		return fmt.Errorf("cannot rename handler to %s: the name is already declared", name)
+	}
+	for _, importSpec := range file.Imports {
+		importPath := strings.Trim(importSpec.Path.Value, `"`)
+		if (importSpec.Name != nil && importSpec.Name.Name == name) || (importSpec.Name == nil && assumedPackageName(importPath) == name) {
+			return fmt.Errorf("cannot rename handler to %s: the name is used by an import", name)
+		}
+	}
+
+	obj := handlerIdent.Obj
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj == obj {
+			ident.Name = name
+		}
+		return true
+	})
+
+	// Keep the file scope consistent for later lookups
+	delete(file.Scope.Objects, obj.Name)
+	obj.Name = name
+	file.Scope.Insert(obj)
+
+	handlerRef.SimpleName = name
+	handlerRef.QualifiedName = types.ExprString(handlerRef.Expr)
+	return nil
+}
+
+// baseIdent returns the identifier of a handler expression declared in the same package
+// (e.g., handleRequest for handleRequest[Event]), or nil for selectors
+func baseIdent(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.IndexExpr:
+		return baseIdent(e.X)
+	case *ast.IndexListExpr:
+		return baseIdent(e.X)
+	}
+	return nil
+}
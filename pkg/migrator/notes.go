@@ -0,0 +1,97 @@
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// migrationNotes is the data summarized by the Markdown migration notes
+type migrationNotes struct {
+	filename   string
+	handlerRef *HandlerReference
+	handlerSig *HandlerSignature
+	plan       *migrationPlan
+	envVars    []string
+	warnings   []Warning
+}
+
+// findEnvVars returns the names of the environment variables read with os.Getenv or os.LookupEnv
+// in the file, as far as they are given as string literals
+func findEnvVars(file *ast.File) []string {
+	seen := make(map[string]bool)
+	var names []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		callExpr, ok := n.(*ast.CallExpr)
+		if !ok || len(callExpr.Args) != 1 {
+			return true
+		}
+		selExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
+		if !ok || (selExpr.Sel.Name != "Getenv" && selExpr.Sel.Name != "LookupEnv") {
+			return true
+		}
+		if ident, ok := selExpr.X.(*ast.Ident); !ok || ident.Obj != nil || importPathForName(file, ident.Name) != "os" {
+			return true
+		}
+		if lit, ok := callExpr.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if name, err := strconv.Unquote(lit.Value); err == nil && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// writeNotes writes a Markdown summary of the migration for reviewers, e.g. to be added to the pull request
+// migrating the function
+func writeNotes(w io.Writer, notes *migrationNotes) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Migration of %s\n\n", notes.handlerRef.QualifiedName)
+	fmt.Fprintf(&buf, "The AWS Lambda handler `%s` registered in `%s` was migrated to a Knative function.\n\n",
+		notes.handlerRef.QualifiedName, filepath.Base(notes.filename))
+
+	fmt.Fprintf(&buf, "## Changes\n\n")
+	fmt.Fprintf(&buf, "- `%s` was replaced by the `Handler` type, whose `Handle` method calls the handler with the signature `%s`\n", notes.handlerRef.registrar.Name.Name, notes.handlerSig.Shape())
+	fmt.Fprintf(&buf, "- Imports added: %s\n", joinOrNone(codeSpans(notes.plan.addedImports)))
+	fmt.Fprintf(&buf, "- Imports removed: %s\n", joinOrNone(codeSpans(notes.plan.removedImports)))
+	fmt.Fprintf(&buf, "- Declarations added: %s\n", joinOrNone(codeSpans(notes.plan.newDecls)))
+
+	fmt.Fprintf(&buf, "\n## Environment Variables\n\n")
+	if len(notes.envVars) == 0 {
+		fmt.Fprintf(&buf, "No environment variables are read in `%s`.\n", filepath.Base(notes.filename))
+	} else {
+		fmt.Fprintf(&buf, "These environment variables are read in `%s` and need to be set on the Knative service:\n\n", filepath.Base(notes.filename))
+		for _, name := range notes.envVars {
+			fmt.Fprintf(&buf, "- `%s`\n", name)
+		}
+	}
+
+	fmt.Fprintf(&buf, "\n## Warnings\n\n")
+	if len(notes.warnings) == 0 {
+		fmt.Fprintf(&buf, "The migration reported no warnings.\n")
+	} else {
+		for _, warning := range notes.warnings {
+			fmt.Fprintf(&buf, "- %s\n", warning)
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// codeSpans formats the values as Markdown code spans
+func codeSpans(values []string) []string {
+	spans := make([]string, len(values))
+	for i, value := range values {
+		spans[i] = "`" + value + "`"
+	}
+	return spans
+}
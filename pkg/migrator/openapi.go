@@ -0,0 +1,75 @@
+package migrator
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// writeOpenAPI writes a minimal OpenAPI 3 document describing the migrated endpoint, whose path
+// operation can be stitched into an existing API description
+func writeOpenAPI(w io.Writer, handlerRef *HandlerReference, handlerSig *HandlerSignature) error {
+	success := map[string]any{"description": "The handler succeeded"}
+	if streamsOutput(handlerSig) {
+		success["content"] = map[string]any{
+			"application/octet-stream": map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+		}
+	} else if handlerSig.HasOutput {
+		success["content"] = jsonContent(handlerSig.outputTypeSchema())
+	}
+
+	operation := map[string]any{
+		"operationId": handlerRef.SimpleName,
+		"responses": map[string]any{
+			"200": success,
+			"500": map[string]any{"description": "The handler failed"},
+		},
+	}
+
+	// Handlers without input are usually invoked to fetch something
+	method := "get"
+	if handlerSig.HasInput {
+		method = "post"
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(handlerSig.inputTypeSchema()),
+		}
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   handlerRef.QualifiedName,
+			"version": "1.0.0",
+		},
+		"paths": map[string]any{
+			"/": map[string]any{method: operation},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// jsonContent returns the OpenAPI content map of a JSON media type with the given schema
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": schema},
+	}
+}
+
+// inputTypeSchema returns the JSON Schema of the input type, or an open schema if it wasn't type checked
+func (s *HandlerSignature) inputTypeSchema() map[string]any {
+	if s.inputType == nil {
+		return map[string]any{}
+	}
+	return jsonSchema(s.inputType)
+}
+
+// outputTypeSchema returns the JSON Schema of the output type, or an open schema if it wasn't type checked
+func (s *HandlerSignature) outputTypeSchema() map[string]any {
+	if s.outputType == nil {
+		return map[string]any{}
+	}
+	return jsonSchema(s.outputType)
+}
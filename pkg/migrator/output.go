@@ -0,0 +1,137 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// emptyMapOutput reports whether the handler returns a map, which the generated handler encodes as an
+// empty JSON object instead of null if it is nil
+func emptyMapOutput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	if !handlerSig.HasOutput || handlerSig.OutputTypeExpr == nil || encodesProtoOutput(handlerSig, opts) || handlerSig.OutputTypeID == cloudFrontResponseID || mapsAPIGatewayResponse(handlerSig) {
+		return false
+	}
+
+	// Named map types can only be recognized with type information
+	if handlerSig.outputType != nil {
+		_, ok := handlerSig.outputType.Underlying().(*types.Map)
+		return ok
+	}
+	_, ok := handlerSig.OutputTypeExpr.(*ast.MapType)
+	return ok
+}
+
+// createEmptyMapStmt creates the statement replacing a nil map result with an empty one:
+//
+//	if result == nil {
+//		result = map[string]any{}
+//	}
+func createEmptyMapStmt(mapType ast.Expr) ast.Stmt {
+	return &ast.IfStmt{
+		Cond: &ast.BinaryExpr{
+			X:  ast.NewIdent("result"),
+			Op: token.EQL,
+			Y:  ast.NewIdent("nil"),
+		},
+		Body: &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("result")},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{&ast.CompositeLit{Type: copyExpr(mapType)}},
+				},
+			},
+		},
+	}
+}
+
+// pointerCollectionOutput reports whether the handler returns a pointer to a slice or map, which the generated
+// handler answers with no content if it is nil, and encodes as an empty JSON array or object instead of null if
+// the collection it points to is nil
+func pointerCollectionOutput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	if !handlerSig.HasOutput || handlerSig.OutputTypeExpr == nil || encodesProtoOutput(handlerSig, opts) || streamsOutput(handlerSig) {
+		return false
+	}
+
+	// Named slice and map types can only be recognized with type information
+	if handlerSig.outputType != nil {
+		ptr, ok := handlerSig.outputType.(*types.Pointer)
+		if !ok {
+			return false
+		}
+		switch ptr.Elem().Underlying().(type) {
+		case *types.Slice, *types.Map:
+			return true
+		}
+		return false
+	}
+	star, ok := handlerSig.OutputTypeExpr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	switch x := star.X.(type) {
+	case *ast.MapType:
+		return true
+	case *ast.ArrayType:
+		return x.Len == nil
+	}
+	return false
+}
+
+// createPointerCollectionStmts creates the statements answering a nil pointer result with no content, and
+// pointing a result to a nil collection to an empty one instead, leaving the handler's collection as is:
+//
+//	if result == nil {
+//		w.WriteHeader(204)
+//		return
+//	}
+//	if *result == nil {
+//		result = &[]Item{}
+//	}
+func createPointerCollectionStmts(collectionType ast.Expr) []ast.Stmt {
+	return []ast.Stmt{
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("result"), Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(204)},
+		},
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: &ast.StarExpr{X: ast.NewIdent("result")}, Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("result")},
+					Tok: token.ASSIGN,
+					Rhs: []ast.Expr{&ast.UnaryExpr{Op: token.AND, X: &ast.CompositeLit{Type: copyExpr(collectionType)}}},
+				},
+			}},
+		},
+	}
+}
+
+// createRawResultStmts creates the statements writing the JSON payload returned by a lambda.Handler as the
+// response, writing the status first if one is given:
+//
+//	w.Header().Set("Content-Type", "application/json")
+//	w.WriteHeader(status) // only if a status is given
+//	w.Write(result)
+func createRawResultStmts(status int) []ast.Stmt {
+	stmts := []ast.Stmt{
+		&ast.ExprStmt{X: &ast.CallExpr{
+			Fun: &ast.SelectorExpr{
+				X:   &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")}},
+				Sel: ast.NewIdent("Set"),
+			},
+			Args: []ast.Expr{
+				&ast.BasicLit{Kind: token.STRING, Value: `"Content-Type"`},
+				&ast.BasicLit{Kind: token.STRING, Value: `"application/json"`},
+			},
+		}},
+	}
+	if status != 0 {
+		stmts = append(stmts, createWriteHeaderStmt(status))
+	}
+	return append(stmts, &ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Write")},
+		Args: []ast.Expr{ast.NewIdent("result")},
+	}})
+}
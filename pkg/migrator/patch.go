@@ -0,0 +1,214 @@
+package migrator
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// patchContext is the number of unchanged lines surrounding the changes of a hunk, like git diff's default
+const patchContext = 3
+
+// editOp is the kind of an edit turning the original lines into the transformed ones
+type editOp int
+
+const (
+	editEqual editOp = iota
+	editDelete
+	editInsert
+)
+
+// edit is a line kept, deleted from the original, or inserted from the transformed lines
+type edit struct {
+	op   editOp
+	line string
+}
+
+// WritePatch writes a git patch turning the original content of the file at path into the transformed one,
+// which can be applied with git apply from the directory path is relative to. Nothing is written if the
+// contents are equal.
+func WritePatch(w io.Writer, path string, mode fs.FileMode, original, transformed []byte) error {
+	if bytes.Equal(original, transformed) {
+		return nil
+	}
+
+	gitMode := "100644"
+	if mode&0o111 != 0 {
+		gitMode = "100755"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "diff --git a/%s b/%s\n", path, path)
+	fmt.Fprintf(&buf, "index %s..%s %s\n", blobHash(original), blobHash(transformed), gitMode)
+	fmt.Fprintf(&buf, "--- a/%s\n+++ b/%s\n", path, path)
+	writeHunks(&buf, diffLines(splitLines(original), splitLines(transformed)))
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// blobHash returns the object ID git assigns to a blob with the content
+func blobHash(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// splitLines splits the content into lines including their line break, so a missing final line break
+// is kept in the last line
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n') + 1
+		if i == 0 {
+			i = len(content)
+		}
+		lines = append(lines, string(content[:i]))
+		content = content[i:]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script turning the lines a into the lines b, computed with Myers'
+// algorithm: the furthest reaching path of each diagonal k = x - y is extended for growing numbers of
+// edits d until it reaches the end of both, then the path is traced back through the recorded frontiers
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset, d)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack traces the path found by diffLines back from the end of both line slices to their start
+func backtrack(trace [][]int, a, b []string, offset, d int) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{editEqual, a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{editInsert, b[y]})
+		} else {
+			x--
+			edits = append(edits, edit{editDelete, a[x]})
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// writeHunks writes the edits as unified diff hunks, merging changes separated by at most twice the
+// context lines into one hunk
+func writeHunks(w io.Writer, edits []edit) {
+	for start := 0; start < len(edits); {
+		for start < len(edits) && edits[start].op == editEqual {
+			start++
+		}
+		if start == len(edits) {
+			return
+		}
+
+		// Extend the hunk while the next change follows within the context of both
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].op != editEqual {
+				end = i + 1
+			} else if i-end >= 2*patchContext {
+				break
+			}
+		}
+		from := max(start-patchContext, 0)
+		to := min(end+patchContext, len(edits))
+
+		// Line numbers are 1-based, and refer to the line preceding the hunk if it has no lines
+		oldLine, newLine := 1, 1
+		for _, e := range edits[:from] {
+			if e.op != editInsert {
+				oldLine++
+			}
+			if e.op != editDelete {
+				newLine++
+			}
+		}
+		var oldCount, newCount int
+		for _, e := range edits[from:to] {
+			if e.op != editInsert {
+				oldCount++
+			}
+			if e.op != editDelete {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldLine--
+		}
+		if newCount == 0 {
+			newLine--
+		}
+
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, e := range edits[from:to] {
+			prefix := " "
+			switch e.op {
+			case editDelete:
+				prefix = "-"
+			case editInsert:
+				prefix = "+"
+			}
+			fmt.Fprint(w, prefix, e.line)
+			if e.line[len(e.line)-1] != '\n' {
+				fmt.Fprint(w, "\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+}
+
+// hunkRange formats the start line and number of lines of a hunk, omitting the number if it is one
+func hunkRange(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
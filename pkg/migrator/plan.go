@@ -0,0 +1,149 @@
+package migrator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// migrationPlan lists the import and declaration changes of a transformation
+type migrationPlan struct {
+	addedImports   []string
+	removedImports []string
+	newDecls       []string
+}
+
+// planMigration determines the import and declaration changes the transformation would make, without
+// modifying the file
+func planMigration(file *ast.File, handlerRef *HandlerReference, handlerSig *HandlerSignature, opts *GenerateOptions, rewriteDeadline, rewriteAWSConfig bool) *migrationPlan {
+	plan := &migrationPlan{}
+	// The generated code refers to the handler and the required imports
+	refsAfter := packageRefs(handlerRef.Expr)
+	for path, info := range planRequiredImports(file, handlerSig, opts) {
+		// Lambda SDK imports get removed first and therefore need to be re-added
+		if info.needed && (!info.hasImport || isRemovedLambdaImport(path, opts.lambdaModule())) {
+			plan.addedImports = append(plan.addedImports, path)
+		}
+		if info.needed {
+			refsAfter[info.alias] = true
+		}
+	}
+
+	// Only the statements of main preceding the start of the handler are kept
+	for name := range keptPackageRefs(file, handlerRef, opts) {
+		refsAfter[name] = true
+	}
+	refsBefore := packageRefs(file)
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			for _, spec := range genDecl.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok {
+					importPath := strings.Trim(importSpec.Path.Value, `"`)
+					if isRemovedLambdaImport(importPath, opts.lambdaModule()) || isUnusedImport(importSpec, refsBefore, refsAfter) {
+						plan.removedImports = append(plan.removedImports, importPath)
+					}
+				}
+			}
+		}
+	}
+
+	typeName := generatedTypeName(opts)
+	plan.newDecls = []string{"type " + typeName, "func New", "func (*" + typeName + ") Handle"}
+	if len(configEnvVars(file, opts)) > 0 {
+		plan.newDecls = []string{"type Config", "type " + typeName, "func New", "func " + configLoaderName, "func (*" + typeName + ") Handle"}
+	}
+	if opts.Style == StyleFuncInstance {
+		plan.newDecls = append([]string{"func " + initializeFuncName}, plan.newDecls...)
+		for _, hook := range []string{"Start", "Stop", "Ready", "Alive"} {
+			plan.newDecls = append(plan.newDecls, "func (*"+typeName+") "+hook)
+		}
+	}
+	if len(opts.CloudEventAttrs) > 0 {
+		plan.newDecls = append(plan.newDecls, "type "+cloudEventKeyTypeName, "func "+cloudEventAccessorName)
+	}
+	if opts.InjectLogger {
+		plan.newDecls = append(plan.newDecls, "type "+loggerKeyTypeName, "func "+loggerAccessorName)
+	}
+	if opts.Metrics {
+		plan.newDecls = append(plan.newDecls, "var "+requestCounterName+", "+errorCounterName, "func init")
+	}
+	if validatesOutput(handlerSig, opts) {
+		plan.newDecls = append([]string{"var " + validateOutputName}, plan.newDecls...)
+	}
+	if poolsBuffers(handlerSig, opts) {
+		plan.newDecls = append([]string{"var " + bufferPoolName}, plan.newDecls...)
+	}
+	if handlerRef.hoisted != nil {
+		plan.newDecls = append([]string{"func " + handlerRef.SimpleName}, plan.newDecls...)
+	}
+	if rewriteDeadline {
+		if !hasImport(file, "time") {
+			plan.addedImports = append(plan.addedImports, "time")
+		}
+		plan.newDecls = append(plan.newDecls, "func "+deadlineHelperName)
+	}
+	if rewriteAWSConfig && !hasImport(file, "os") && !slices.Contains(plan.addedImports, "os") {
+		plan.addedImports = append(plan.addedImports, "os")
+	}
+	sort.Strings(plan.addedImports)
+	return plan
+}
+
+// keptPackageRefs returns the names qualifying identifiers in the declarations kept by the transformation, i.e.
+// all but main and the init function registering the handler, whose statements preceding the start of the
+// handler are kept, along with their deferred calls with StyleFuncInstance
+func keptPackageRefs(file *ast.File, handlerRef *HandlerReference, opts *GenerateOptions) map[string]bool {
+	var nodes []ast.Node
+	removed := handlerRef.removedFuncs(file)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); !ok || !slices.Contains(removed, fn) {
+			nodes = append(nodes, decl)
+		} else if fn == handlerRef.registrar {
+			start, deferred, _ := instanceHooks(file, fn, handlerRef.Expr)
+			for _, stmt := range start {
+				nodes = append(nodes, stmt)
+			}
+			if opts.Style == StyleFuncInstance {
+				for _, stmt := range deferred {
+					nodes = append(nodes, stmt)
+				}
+			}
+		}
+	}
+	return packageRefs(nodes...)
+}
+
+// printPlan prints the import and declaration changes the transformation would make, without modifying the file
+func printPlan(w io.Writer, file *ast.File, handlerRef *HandlerReference, handlerSig *HandlerSignature, opts *GenerateOptions, rewriteDeadline, rewriteAWSConfig bool) {
+	plan := planMigration(file, handlerRef, handlerSig, opts, rewriteDeadline, rewriteAWSConfig)
+	fmt.Fprintf(w, "Imports added:         %s\n", joinOrNone(plan.addedImports))
+	fmt.Fprintf(w, "Imports removed:       %s\n", joinOrNone(plan.removedImports))
+	fmt.Fprintf(w, "Declarations added:    %s\n", joinOrNone(plan.newDecls))
+	var removed []string
+	for _, fn := range handlerRef.removedFuncs(file) {
+		removed = append(removed, "func "+fn.Name.Name)
+	}
+	fmt.Fprintf(w, "Declarations removed:  %s\n", strings.Join(removed, ", "))
+}
+
+// hasImport reports whether the file imports the given path
+func hasImport(file *ast.File, path string) bool {
+	for _, importSpec := range file.Imports {
+		if strings.Trim(importSpec.Path.Value, `"`) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// joinOrNone joins the values with commas, or returns "none" if there are none
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,107 @@
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// generatedFilename is the file //line directives attribute the generated declarations to, so they aren't
+// mistaken for the source lines they are printed after
+const generatedFilename = "generated"
+
+// linePos is a position //line directives attribute output lines to
+type linePos struct {
+	filename string
+	line     int
+}
+
+// addLineDirectives inserts //line directives into the printed file so that the declarations kept from the
+// source, including the package clause, are attributed to their lines in it, as positions recorded by
+// coverage and debugging tools would otherwise shift by the lines the generated code adds. The generated
+// declarations are attributed to generatedFilename. Directives of the source are kept and taken into account,
+// positions follow them like the compiler's.
+func addLineDirectives(out []byte, file *ast.File, fset *token.FileSet) ([]byte, error) {
+	outFset := token.NewFileSet()
+	outFile, err := parser.ParseFile(outFset, "", out, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse printed code: %w", err)
+	}
+	if len(outFile.Decls) != len(file.Decls) {
+		return nil, fmt.Errorf("printed code has %d declarations instead of %d", len(outFile.Decls), len(file.Decls))
+	}
+
+	// Output lines starting a declaration, with the position they are attributed to
+	targets := map[int]linePos{
+		outFset.PositionFor(outFile.Package, false).Line: {fset.Position(file.Package).Filename, fset.Position(file.Package).Line},
+	}
+	for i, decl := range file.Decls {
+		// Doc comments may be printed apart from their declaration, which then starts at its keyword
+		outStart := declStart(outFile.Decls[i])
+		outLine := outFset.PositionFor(outStart, false).Line
+		pos := decl.Pos()
+		if outStart != outFile.Decls[i].Pos() {
+			pos = declStart(decl)
+		}
+		if pos.IsValid() {
+			position := fset.Position(pos)
+			targets[outLine] = linePos{position.Filename, position.Line}
+		} else {
+			targets[outLine] = linePos{generatedFilename, outLine}
+		}
+	}
+
+	// Until the first directive, the output lines are attributed to the source file they replace
+	current := linePos{fset.File(file.Package).Name(), 0}
+	lines := bytes.SplitAfter(out, []byte("\n"))
+	var buf bytes.Buffer
+	for i, line := range lines {
+		outLine := i + 1
+		if target, ok := targets[outLine]; ok && (current.filename != target.filename || current.line+outLine != target.line) {
+			fmt.Fprintf(&buf, "//line %s:%d\n", target.filename, target.line)
+			current = linePos{target.filename, target.line - outLine}
+		}
+		buf.Write(line)
+		if directive, ok := parseLineDirective(string(line)); ok {
+			current = linePos{directive.filename, directive.line - outLine - 1}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// declStart returns the position of the first line of a declaration, its doc comment if it has one
+func declStart(decl ast.Decl) token.Pos {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
+
+// parseLineDirective parses a //line directive of the form //line filename:line, which attributes the next
+// line to the given position. Directives with a column are not taken into account.
+func parseLineDirective(line string) (linePos, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "//line ")
+	if !ok {
+		return linePos{}, false
+	}
+	colon := strings.LastIndex(rest, ":")
+	if colon < 0 {
+		return linePos{}, false
+	}
+	n, err := strconv.Atoi(rest[colon+1:])
+	if err != nil || n <= 0 {
+		return linePos{}, false
+	}
+	return linePos{rest[:colon], n}, true
+}
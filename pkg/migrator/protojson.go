@@ -0,0 +1,154 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// protojsonPkgPath is the import path of the protobuf JSON encoding
+const protojsonPkgPath = "google.golang.org/protobuf/encoding/protojson"
+
+// isProtoMessage reports whether the type is a pointer to a generated protobuf message,
+// i.e. its method set has the Reset, String, and ProtoReflect methods
+func isProtoMessage(t types.Type) bool {
+	if _, ok := t.(*types.Pointer); !ok {
+		return false
+	}
+
+	methods := types.NewMethodSet(t)
+	for _, name := range []string{"Reset", "String", "ProtoReflect"} {
+		if methods.Lookup(nil, name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// createProtoUnmarshalStmts creates the statements decoding the request body into the protobuf input message:
+//
+//	in := new(pb.Request)
+//	if err := protojson.Unmarshal(body, in); err != nil {
+//		w.WriteHeader(400)
+//		return
+//	}
+func createProtoUnmarshalStmts(inputType ast.Expr, protojsonAlias string) []ast.Stmt {
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("in")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun:  ast.NewIdent("new"),
+					Args: []ast.Expr{copyExpr(inputType.(*ast.StarExpr).X)},
+				},
+			},
+		},
+		&ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("err")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{
+					&ast.CallExpr{
+						Fun: &ast.SelectorExpr{
+							X:   ast.NewIdent(protojsonAlias),
+							Sel: ast.NewIdent("Unmarshal"),
+						},
+						Args: []ast.Expr{ast.NewIdent("body"), ast.NewIdent("in")},
+					},
+				},
+			},
+			Cond: &ast.BinaryExpr{
+				X:  ast.NewIdent("err"),
+				Op: token.NEQ,
+				Y:  ast.NewIdent("nil"),
+			},
+			Body: &ast.BlockStmt{List: createWriteStatusStmts(400)},
+		},
+	}
+}
+
+// createProtoMarshalStmts creates the statements encoding the protobuf output message as the response:
+//
+//	out, err := protojson.Marshal(result)
+//	if err != nil {
+//		log.Printf("Failed to encode response: %v", err)
+//		w.WriteHeader(500)
+//		return
+//	}
+//	w.Header().Set("Content-Type", "application/json")
+//	w.WriteHeader(status) // only if a status is given
+//	w.Write(out)
+func createProtoMarshalStmts(protojsonAlias, logAlias string, status int) []ast.Stmt {
+	stmts := []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("out"), ast.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{
+				&ast.CallExpr{
+					Fun: &ast.SelectorExpr{
+						X:   ast.NewIdent(protojsonAlias),
+						Sel: ast.NewIdent("Marshal"),
+					},
+					Args: []ast.Expr{ast.NewIdent("result")},
+				},
+			},
+		},
+		&ast.IfStmt{
+			Cond: &ast.BinaryExpr{
+				X:  ast.NewIdent("err"),
+				Op: token.NEQ,
+				Y:  ast.NewIdent("nil"),
+			},
+			Body: &ast.BlockStmt{
+				List: append([]ast.Stmt{
+					&ast.ExprStmt{
+						X: &ast.CallExpr{
+							Fun: &ast.SelectorExpr{
+								X:   ast.NewIdent(logAlias),
+								Sel: ast.NewIdent("Printf"),
+							},
+							Args: []ast.Expr{
+								&ast.BasicLit{Kind: token.STRING, Value: `"Failed to encode response: %v"`},
+								ast.NewIdent("err"),
+							},
+						},
+					},
+				}, createWriteStatusStmts(500)...),
+			},
+		},
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Header")},
+					},
+					Sel: ast.NewIdent("Set"),
+				},
+				Args: []ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: `"Content-Type"`},
+					&ast.BasicLit{Kind: token.STRING, Value: `"application/json"`},
+				},
+			},
+		},
+	}
+	if status != 0 {
+		stmts = append(stmts, createWriteHeaderStmt(status))
+	}
+	return append(stmts, &ast.ExprStmt{
+		X: &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("Write")},
+			Args: []ast.Expr{ast.NewIdent("out")},
+		},
+	})
+}
+
+// decodesProtoInput reports whether the generated handler decodes its input with protojson
+func decodesProtoInput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return opts.ProtoJSON && handlerSig.HasInput && handlerSig.InputIsProto && handlerSig.InputTypeExpr != nil
+}
+
+// encodesProtoOutput reports whether the generated handler encodes its output with protojson
+func encodesProtoOutput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return opts.ProtoJSON && handlerSig.HasOutput && handlerSig.OutputIsProto
+}
@@ -0,0 +1,72 @@
+package migrator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// writeReadme writes a Markdown README for the migrated function describing its signature and how to build,
+// deploy, and invoke it with func, with a sample request derived from the handler input
+func writeReadme(w io.Writer, filename string, handlerRef *HandlerReference, handlerSig *HandlerSignature, opts *GenerateOptions) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", handlerRef.QualifiedName)
+	fmt.Fprintf(&buf, "This Knative function was migrated from the AWS Lambda handler `%s` in `%s`. ",
+		handlerRef.QualifiedName, filepath.Base(filename))
+	switch opts.Style {
+	case StyleFuncInstance:
+		fmt.Fprintf(&buf, "Its `Function` type implements the lifecycle hooks of func's Go instances and serves the requests with its `Handle` method.\n\n")
+	case StyleCloudEvents:
+		fmt.Fprintf(&buf, "Its `Handler` type, created by `New`, serves CloudEvents with its `Handle` method.\n\n")
+	default:
+		fmt.Fprintf(&buf, "Its `Handler` type, created by `New`, serves the requests with its `Handle` method.\n\n")
+	}
+
+	fmt.Fprintf(&buf, "## Signature\n\n")
+	fmt.Fprintf(&buf, "The handler has the signature `%s`:\n\n", handlerSig.Shape())
+	switch {
+	case !handlerSig.HasInput:
+		fmt.Fprintf(&buf, "- Input: none, the request body is ignored\n")
+	case opts.Style == StyleCloudEvents:
+		fmt.Fprintf(&buf, "- Input: `%s`, decoded from the data of the received event\n", handlerSig.InputType)
+	case opts.InputSource == InputSourceMultipart:
+		fmt.Fprintf(&buf, "- Input: `%s`, decoded from the JSON file uploaded in the multipart form field `%s`\n", handlerSig.InputType, opts.FileField)
+	default:
+		fmt.Fprintf(&buf, "- Input: `%s`, decoded from the JSON request body\n", handlerSig.InputType)
+	}
+	switch {
+	case handlerSig.HasOutput && opts.Style == StyleCloudEvents:
+		fmt.Fprintf(&buf, "- Output: `%s`, encoded as the JSON data of the response event\n", handlerSig.OutputType)
+	case handlerSig.HasOutput:
+		fmt.Fprintf(&buf, "- Output: `%s`, encoded as the JSON response body\n", handlerSig.OutputType)
+	case opts.Style == StyleCloudEvents:
+		fmt.Fprintf(&buf, "- Output: none, the function responds with no event\n")
+	default:
+		fmt.Fprintf(&buf, "- Output: none, the function responds with no content\n")
+	}
+	switch {
+	case handlerSig.HasError && opts.Style == StyleCloudEvents:
+		fmt.Fprintf(&buf, "- Errors returned by the handler are returned to the func runtime, which answers them with 500\n")
+	case handlerSig.HasError:
+		fmt.Fprintf(&buf, "- Errors returned by the handler are logged and answered with 500\n")
+	}
+
+	fmt.Fprintf(&buf, "\n## Build and Deploy\n\n")
+	fmt.Fprintf(&buf, "Build the function image and deploy it as a Knative service with the [func CLI](https://github.com/knative/func):\n\n")
+	fmt.Fprintf(&buf, "```sh\nfunc build\nfunc deploy\n```\n")
+
+	comment, command, err := invokeCommand(handlerSig, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&buf, "\n## Invoke\n\n")
+	fmt.Fprintf(&buf, "Send a sample request to the deployed function:\n\n")
+	fmt.Fprintf(&buf, "```sh\n%s\n```\n", command)
+	if comment != "" {
+		fmt.Fprintf(&buf, "\n%s.\n", comment)
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,82 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// readyzPath is the path of the readiness endpoint answered by the generated Handle method
+const readyzPath = "/readyz"
+
+// addReadyField adds the field recording whether the initialization succeeded to the generated struct:
+//
+//	type Function struct {
+//		ready atomic.Bool
+//	}
+func addReadyField(handlerStruct *ast.GenDecl, atomicAlias string) {
+	structType := handlerStruct.Specs[0].(*ast.TypeSpec).Type.(*ast.StructType)
+	structType.Fields.List = append(structType.Fields.List, &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent("ready")},
+		Type:  &ast.SelectorExpr{X: ast.NewIdent(atomicAlias), Sel: ast.NewIdent("Bool")},
+	})
+}
+
+// createReadyzStmt creates the statement answering readiness probes with 503 until the initialization
+// succeeded and 200 after:
+//
+//	if r.URL.Path == "/readyz" {
+//		if !h.ready.Load() {
+//			w.WriteHeader(503)
+//			return
+//		}
+//		w.WriteHeader(200)
+//		return
+//	}
+func createReadyzStmt() ast.Stmt {
+	return &ast.IfStmt{
+		Cond: &ast.BinaryExpr{
+			X:  &ast.SelectorExpr{X: &ast.SelectorExpr{X: ast.NewIdent("r"), Sel: ast.NewIdent("URL")}, Sel: ast.NewIdent("Path")},
+			Op: token.EQL,
+			Y:  &ast.BasicLit{Kind: token.STRING, Value: `"` + readyzPath + `"`},
+		},
+		Body: &ast.BlockStmt{List: append([]ast.Stmt{
+			&ast.IfStmt{
+				Cond: &ast.UnaryExpr{Op: token.NOT, X: createReadyLoadExpr()},
+				Body: &ast.BlockStmt{List: createWriteStatusStmts(503)},
+			},
+		}, createWriteStatusStmts(200)...)},
+	}
+}
+
+// createReadyLoadExpr creates the expression reporting whether the initialization succeeded:
+//
+//	h.ready.Load()
+func createReadyLoadExpr() ast.Expr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{X: &ast.SelectorExpr{X: ast.NewIdent("h"), Sel: ast.NewIdent("ready")}, Sel: ast.NewIdent("Load")},
+	}
+}
+
+// createReadyStartStmts creates the statements of Start running the initialization and recording its success:
+//
+//	if err := initialize(); err != nil {
+//		return err
+//	}
+//	h.ready.Store(true)
+func createReadyStartStmts() []ast.Stmt {
+	return []ast.Stmt{
+		&ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("err")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.CallExpr{Fun: ast.NewIdent(initializeFuncName)}},
+			},
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("err")}}}},
+		},
+		&ast.ExprStmt{X: &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: &ast.SelectorExpr{X: ast.NewIdent("h"), Sel: ast.NewIdent("ready")}, Sel: ast.NewIdent("Store")},
+			Args: []ast.Expr{ast.NewIdent("true")},
+		}},
+	}
+}
@@ -0,0 +1,121 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// createRecoverStmt creates the deferred recovery turning handler panics into error responses. With stack,
+// the stack trace of the panicking goroutine is logged along with the recovered value. With panicStatus,
+// recovered values reporting a StatusCode() determine the response status:
+//
+//	defer func() {
+//		if rec := recover(); rec != nil {
+//			log.Printf("Handler panic: %v\n%s", rec, debug.Stack())
+//			status := 500
+//			if statusCoder, ok := rec.(interface{ StatusCode() int }); ok {
+//				status = statusCoder.StatusCode()
+//			}
+//			w.WriteHeader(status)
+//		}
+//	}()
+func createRecoverStmt(debugAlias, logAlias string, panicStatus, stack bool) ast.Stmt {
+	var statusExpr ast.Expr = &ast.BasicLit{Kind: token.INT, Value: "500"}
+
+	logArgs := []ast.Expr{
+		&ast.BasicLit{Kind: token.STRING, Value: `"Handler panic: %v"`},
+		ast.NewIdent("rec"),
+	}
+	if stack {
+		logArgs[0] = &ast.BasicLit{Kind: token.STRING, Value: `"Handler panic: %v\n%s"`}
+		logArgs = append(logArgs, &ast.CallExpr{
+			Fun: &ast.SelectorExpr{X: ast.NewIdent(debugAlias), Sel: ast.NewIdent("Stack")},
+		})
+	}
+
+	recoverStmts := []ast.Stmt{
+		&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(logAlias), Sel: ast.NewIdent("Printf")},
+				Args: logArgs,
+			},
+		},
+	}
+
+	if panicStatus {
+		statusExpr = ast.NewIdent("status")
+		recoverStmts = append(recoverStmts,
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("status")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "500"}},
+			},
+			&ast.IfStmt{
+				Init: &ast.AssignStmt{
+					Lhs: []ast.Expr{ast.NewIdent("statusCoder"), ast.NewIdent("ok")},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{
+						&ast.TypeAssertExpr{
+							X: ast.NewIdent("rec"),
+							Type: &ast.InterfaceType{
+								Methods: &ast.FieldList{
+									List: []*ast.Field{
+										{
+											Names: []*ast.Ident{ast.NewIdent("StatusCode")},
+											Type: &ast.FuncType{
+												Params:  &ast.FieldList{},
+												Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("int")}}},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Cond: ast.NewIdent("ok"),
+				Body: &ast.BlockStmt{
+					List: []ast.Stmt{
+						&ast.AssignStmt{
+							Lhs: []ast.Expr{ast.NewIdent("status")},
+							Tok: token.ASSIGN,
+							Rhs: []ast.Expr{
+								&ast.CallExpr{
+									Fun: &ast.SelectorExpr{X: ast.NewIdent("statusCoder"), Sel: ast.NewIdent("StatusCode")},
+								},
+							},
+						},
+					},
+				},
+			},
+		)
+	}
+
+	recoverStmts = append(recoverStmts, &ast.ExprStmt{
+		X: &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: ast.NewIdent("w"), Sel: ast.NewIdent("WriteHeader")},
+			Args: []ast.Expr{statusExpr},
+		},
+	})
+
+	return &ast.DeferStmt{
+		Call: &ast.CallExpr{
+			Fun: &ast.FuncLit{
+				Type: &ast.FuncType{Params: &ast.FieldList{}},
+				Body: &ast.BlockStmt{
+					List: []ast.Stmt{
+						&ast.IfStmt{
+							Init: &ast.AssignStmt{
+								Lhs: []ast.Expr{ast.NewIdent("rec")},
+								Tok: token.DEFINE,
+								Rhs: []ast.Expr{&ast.CallExpr{Fun: ast.NewIdent("recover")}},
+							},
+							Cond: &ast.BinaryExpr{X: ast.NewIdent("rec"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+							Body: &ast.BlockStmt{List: recoverStmts},
+						},
+					},
+				},
+			},
+		},
+	}
+}
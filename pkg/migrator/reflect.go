@@ -0,0 +1,76 @@
+package migrator
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// findInputReflection finds the calls of functions of package reflect passed the input parameter of a handler
+// declared in the file (e.g., reflect.TypeOf(event)), whose results may depend on the input being decoded
+// like the Lambda runtime did
+func findInputReflection(file *ast.File, handlerName string, handlerSig *HandlerSignature) []*ast.CallExpr {
+	if !handlerSig.HasInput {
+		return nil
+	}
+
+	var calls []*ast.CallExpr
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != handlerName || fn.Body == nil {
+			continue
+		}
+
+		input := inputParam(fn, handlerSig)
+		if input == nil || input.Obj == nil {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			callExpr, ok := n.(*ast.CallExpr)
+			if !ok || !strings.HasPrefix(qualifiedFuncName(file, callExpr.Fun), "reflect.") {
+				return true
+			}
+			for _, arg := range callExpr.Args {
+				if refersTo(arg, input.Obj) {
+					calls = append(calls, callExpr)
+					break
+				}
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// inputParam returns the name of the handler parameter receiving the input, or nil if it is unnamed or the
+// parameters don't match the roles of the signature
+func inputParam(fn *ast.FuncDecl, handlerSig *HandlerSignature) *ast.Ident {
+	var names []*ast.Ident
+	for _, field := range fn.Type.Params.List {
+		if len(field.Names) == 0 {
+			names = append(names, nil)
+		}
+		names = append(names, field.Names...)
+	}
+	roles := handlerSig.paramRoles()
+	if len(roles) != len(names) {
+		return nil
+	}
+	for i, role := range roles {
+		if role == SignatureRoleInput {
+			return names[i]
+		}
+	}
+	return nil
+}
+
+// refersTo reports whether the expression refers to the object, e.g. the input parameter or its fields
+func refersTo(expr ast.Expr, obj *ast.Object) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,209 @@
+package migrator
+
+import (
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonSchema returns the JSON Schema of values of type t as encoded by encoding/json
+func jsonSchema(t types.Type) map[string]any {
+	return schemaOf(t, make(map[*types.Named]bool))
+}
+
+// schemaOf returns the JSON Schema of t, leaving types that are already being visited open to stop at recursion
+func schemaOf(t types.Type, visiting map[*types.Named]bool) map[string]any {
+	switch t := t.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		// Custom encodings can't be described from the type
+		if types.NewMethodSet(types.NewPointer(t)).Lookup(nil, "MarshalJSON") != nil || visiting[t] {
+			return map[string]any{}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return schemaOf(t.Underlying(), visiting)
+	case *types.Pointer:
+		return schemaOf(t.Elem(), visiting)
+	case *types.Basic:
+		switch info := t.Info(); {
+		case info&types.IsBoolean != 0:
+			return map[string]any{"type": "boolean"}
+		case info&types.IsInteger != 0:
+			return map[string]any{"type": "integer"}
+		case info&types.IsFloat != 0:
+			return map[string]any{"type": "number"}
+		case info&types.IsString != 0:
+			return map[string]any{"type": "string"}
+		}
+	case *types.Slice:
+		// Byte slices are encoded as base64 strings
+		if elem, ok := t.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case *types.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case *types.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+	case *types.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for _, field := range jsonFields(t) {
+			schema := schemaOf(field.Var.Type(), visiting)
+			if field.Quoted {
+				// The string option encodes numbers and booleans as JSON strings
+				schema = map[string]any{"type": "string"}
+			}
+			properties[field.Name] = schema
+			if !field.OmitEmpty {
+				required = append(required, field.Name)
+			}
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	// Interfaces and other types may hold any value
+	return map[string]any{}
+}
+
+// jsonField is a struct field as encoded by encoding/json
+type jsonField struct {
+	Name      string     // Name of the JSON object member
+	Var       *types.Var // Struct field, possibly promoted from an embedded struct
+	OmitEmpty bool       // Field is tagged with the omitempty option
+	Quoted    bool       // Field is tagged with the string option
+
+	index  []int // Indices of the field and the embedded fields it is promoted through
+	tagged bool  // Name is given by the json tag
+}
+
+// jsonFields returns the fields of the struct encoded by encoding/json in their encoding order, i.e. the
+// exported fields not tagged with `json:"-"` including those promoted from untagged embedded structs.
+// Like encoding/json, a name declared at several embedding depths refers to the shallowest field, and
+// ambiguous names at the same depth are dropped unless exactly one of them is tagged.
+func jsonFields(st *types.Struct) []jsonField {
+	type embedded struct {
+		st    *types.Struct
+		index []int
+	}
+
+	var fields []jsonField
+	visited := make(map[*types.Struct]bool)
+	for current := []embedded{{st: st}}; len(current) > 0; {
+		var next []embedded
+		for _, e := range current {
+			if visited[e.st] {
+				continue
+			}
+			visited[e.st] = true
+
+			for i := 0; i < e.st.NumFields(); i++ {
+				field := e.st.Field(i)
+				tag := reflect.StructTag(e.st.Tag(i)).Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), e.index...), i)
+
+				if field.Embedded() {
+					t := field.Type()
+					if ptr, ok := t.(*types.Pointer); ok {
+						t = ptr.Elem()
+					}
+					embeddedStruct, isStruct := t.Underlying().(*types.Struct)
+					if !field.Exported() && !isStruct {
+						continue
+					}
+					// The fields of untagged embedded structs are promoted
+					if name == "" && isStruct {
+						next = append(next, embedded{st: embeddedStruct, index: index})
+						continue
+					}
+				} else if !field.Exported() {
+					continue
+				}
+
+				jf := jsonField{Name: name, Var: field, index: index, tagged: name != ""}
+				if name == "" {
+					jf.Name = field.Name()
+				}
+				for _, option := range strings.Split(options, ",") {
+					switch option {
+					case "omitempty":
+						jf.OmitEmpty = true
+					case "string":
+						jf.Quoted = isQuotable(field.Type())
+					}
+				}
+				fields = append(fields, jf)
+			}
+		}
+		current = next
+	}
+
+	return dominantFields(fields)
+}
+
+// dominantFields drops the fields hidden by others of the same name, keeping the remaining ones in
+// their declaration order
+func dominantFields(fields []jsonField) []jsonField {
+	byName := make(map[string][]jsonField)
+	for _, field := range fields {
+		byName[field.Name] = append(byName[field.Name], field)
+	}
+
+	var dominant []jsonField
+	for _, field := range fields {
+		candidates := byName[field.Name]
+		if candidates == nil {
+			continue
+		}
+		delete(byName, field.Name)
+
+		// Fields are collected by depth, so the first candidates are the shallowest
+		depth := len(candidates[0].index)
+		var shallowest, tagged []jsonField
+		for _, candidate := range candidates {
+			if len(candidate.index) == depth {
+				shallowest = append(shallowest, candidate)
+				if candidate.tagged {
+					tagged = append(tagged, candidate)
+				}
+			}
+		}
+		switch {
+		case len(shallowest) == 1:
+			dominant = append(dominant, shallowest[0])
+		case len(tagged) == 1:
+			dominant = append(dominant, tagged[0])
+		}
+	}
+
+	sort.Slice(dominant, func(i, j int) bool {
+		a, b := dominant[i].index, dominant[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return dominant
+}
+
+// isQuotable reports whether encoding/json applies the string option to values of the type,
+// i.e. it is a boolean, number, or string
+func isQuotable(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&(types.IsBoolean|types.IsNumeric|types.IsString) != 0
+}
@@ -0,0 +1,80 @@
+package migrator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// lambdaDefaultMemory is the memory in MB of Lambda functions not configuring any
+	lambdaDefaultMemory = 128
+	// lambdaMemoryPerVCPU is the memory in MB at which Lambda allots a full vCPU, CPU power being proportional to memory
+	lambdaMemoryPerVCPU = 1769
+)
+
+// ServiceManifest returns the path and content of a Knative Service manifest at the root of the module
+// containing the main package in mainDir, running the image built from that package on port 8080. The
+// resources are commented out, sized like a Lambda function with memoryMB of memory (Lambda's default if 0).
+// The manifest is at the path the Skaffold config deploys, and refers to the image by the same name.
+func ServiceManifest(mainDir string, memoryMB int) (string, []byte, error) {
+	root, importPath, _, err := mainPackage(mainDir)
+	if err != nil {
+		return "", nil, err
+	}
+	name := path.Base(importPath)
+
+	sizing := "the Lambda function's"
+	if memoryMB == 0 {
+		memoryMB = lambdaDefaultMemory
+		sizing = "Lambda's default"
+	}
+	cpu := (memoryMB*1000 + lambdaMemoryPerVCPU - 1) / lambdaMemoryPerVCPU
+
+	manifest := fmt.Sprintf(`# Deploy the migrated function with: kubectl apply -f %s
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: %s
+spec:
+  template:
+    spec:
+      containers:
+      # Skaffold replaces the image with the one built from %s, otherwise replace it with the one
+      # published by: ko build %s
+      - image: %s
+        ports:
+        - containerPort: 8080
+        # Sized like %s %d MB, which Lambda allots %dm of a vCPU:
+        # resources:
+        #   requests:
+        #     cpu: %dm
+        #     memory: %dMi
+        #   limits:
+        #     memory: %dMi
+`, skaffoldManifest, serviceName(name), importPath, importPath, name, sizing, memoryMB, cpu, cpu, memoryMB, memoryMB)
+	return filepath.Join(root, skaffoldManifest), []byte(manifest), nil
+}
+
+// serviceName turns name into a valid Kubernetes resource name, i.e. a DNS label of lowercase letters,
+// digits, and hyphens
+func serviceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+	name = strings.Trim(name, "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "function-" + name
+	}
+	return strings.TrimSuffix(name, "-")
+}
@@ -0,0 +1,124 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// avoidShadowing renames the receiver, parameters, and variables declared by the generated method that the
+// handler expression refers to by the same name (e.g., a package-level variable w whose method is the handler,
+// or a handler function named input), which would otherwise shadow the declarations the handler expression
+// refers to. The identifiers of the handler expression itself are kept.
+func avoidShadowing(method *ast.FuncDecl, handlerFuncExpr ast.Expr) {
+	kept := make(map[*ast.Ident]bool)
+	referenced := make(map[string]bool)
+	inspectRefs(handlerFuncExpr, func(ident *ast.Ident) {
+		kept[ident] = true
+		referenced[ident.Name] = true
+	})
+
+	used := make(map[string]bool)
+	ast.Inspect(method, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+
+	for _, name := range methodDeclaredNames(method) {
+		if !referenced[name] {
+			continue
+		}
+		renamed := name
+		for i := 2; used[renamed]; i++ {
+			renamed = name + strconv.Itoa(i)
+		}
+		used[renamed] = true
+		inspectRefs(method, func(ident *ast.Ident) {
+			if ident.Name == name && !kept[ident] {
+				ident.Name = renamed
+			}
+		})
+		for _, ident := range declaredIdents(method) {
+			if ident.Name == name {
+				ident.Name = renamed
+			}
+		}
+	}
+}
+
+// inspectRefs calls fn for the identifiers of the node that refer to declarations, i.e. those that don't
+// select a field or method or name the key of a composite literal element, nor declare a name
+func inspectRefs(node ast.Node, fn func(*ast.Ident)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			inspectRefs(n.X, fn)
+			return false
+		case *ast.KeyValueExpr:
+			if _, ok := n.Key.(*ast.Ident); !ok {
+				inspectRefs(n.Key, fn)
+			}
+			inspectRefs(n.Value, fn)
+			return false
+		case *ast.Field:
+			inspectRefs(n.Type, fn)
+			return false
+		case *ast.ValueSpec:
+			inspectRefs(n.Type, fn)
+			for _, value := range n.Values {
+				inspectRefs(value, fn)
+			}
+			return false
+		case *ast.Ident:
+			fn(n)
+		}
+		return true
+	})
+}
+
+// methodDeclaredNames returns the distinct names declared by the method, i.e. those of its receiver, parameters,
+// and results, and those of the variables and function literal parameters of its body
+func methodDeclaredNames(method *ast.FuncDecl) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, ident := range declaredIdents(method) {
+		if ident.Name != "_" && !seen[ident.Name] {
+			seen[ident.Name] = true
+			names = append(names, ident.Name)
+		}
+	}
+	return names
+}
+
+// declaredIdents returns the identifiers declaring the names of methodDeclaredNames
+func declaredIdents(method *ast.FuncDecl) []*ast.Ident {
+	var idents []*ast.Ident
+	ast.Inspect(method, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			idents = append(idents, n.Names...)
+		case *ast.ValueSpec:
+			idents = append(idents, n.Names...)
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok {
+						idents = append(idents, ident)
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				for _, expr := range []ast.Expr{n.Key, n.Value} {
+					if ident, ok := expr.(*ast.Ident); ok {
+						idents = append(idents, ident)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return idents
+}
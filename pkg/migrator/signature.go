@@ -0,0 +1,789 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// errVariadicHandler is returned for handlers with variadic parameters, which the Lambda runtime can't call
+var errVariadicHandler = errors.New("variadic handlers are not supported")
+
+// errErrorFirstHandler is returned for handlers returning their error before the output, which the Lambda
+// runtime rejects as it expects the error last
+var errErrorFirstHandler = errors.New("handlers returning the error first are not supported, the error must be the last result")
+
+// HandlerSignature describes the Lambda handler function signature
+type HandlerSignature struct {
+	HasContext   bool
+	HasInput     bool
+	HasOutput    bool
+	HasError     bool
+	HasWriter    bool   // Trailing parameter receiving the response writer (e.g., an io.Writer)
+	ContextType  string // Textual type of a custom context interface embedding context.Context (empty for context.Context)
+	InputType    string // Textual input type if present (e.g., "events.SQSEvent")
+	OutputType   string // Textual output type if present (e.g., "Response")
+	InputTypeID  string // Input type qualified by import path (e.g., "github.com/aws/aws-lambda-go/events.SQSEvent")
+	OutputTypeID string // Output type qualified by import path
+
+	InputTypeExpr     ast.Expr          // Input type as an expression valid in the migrated file
+	OutputTypeExpr    ast.Expr          // Output type as an expression valid in the migrated file
+	TypeImports       map[string]string // Import paths of the packages referenced by the type expressions, by package name
+	InputIsPointer    bool              // Input is passed by pointer (e.g., *MyEvent)
+	InputIsProto      bool              // Input is a protobuf message (only detected by the type checker)
+	InputIsInterface  bool              // Input is an interface with methods, which JSON can't decode into (only detected for interfaces declared in the file without the type checker)
+	OutputIsProto     bool              // Output is a protobuf message (only detected by the type checker)
+	OutputIsReader    bool              // Output is a concrete type implementing io.Reader, e.g. *bytes.Buffer (only detected by the type checker)
+	OutputIsCloser    bool              // Output is a concrete type implementing io.Closer (only detected by the type checker)
+	RawOutput         bool              // Output is the JSON payload returned by the Invoke method of a lambda.Handler, written as is
+	ParamRoles        []string          // Role of each parameter given by a signature map or including auxiliary ones (nil if classified by the order of the Lambda docs)
+	HasCancel         bool              // Parameter receiving the function cancelling the handler context (a context.CancelFunc)
+	LoggerTypeID      string            // Type of the parameter receiving the default logger (e.g., "*log/slog.Logger", empty if there is none)
+	EnvelopeErrField  string            // Field of the envelope result carrying the handler error, whose output is its data field (empty if the result isn't used as an envelope)
+	EnvelopeDataField string            // Field of the envelope result carrying the output
+
+	inputType  types.Type      // Type-checked input type (nil if analyzed from the AST)
+	outputType types.Type      // Type-checked output type (nil if analyzed from the AST)
+	envelope   *envelopeOutput // Envelope the single result of the handler may be (nil if it isn't one or analyzed from the AST)
+}
+
+// Shape returns the handler signature in the notation of the AWS Lambda docs (e.g., "func (context.Context, TIn) error")
+func (s *HandlerSignature) Shape() string {
+	var params, results []string
+	for _, role := range s.paramRoles() {
+		switch role {
+		case SignatureRoleContext:
+			params = append(params, "context.Context")
+		case SignatureRoleInput:
+			params = append(params, "TIn")
+		case SignatureRoleBody:
+			params = append(params, "[]byte")
+		case SignatureRoleWriter:
+			params = append(params, "TWriter")
+		case SignatureRoleCancel:
+			params = append(params, "context.CancelFunc")
+		case SignatureRoleLogger:
+			params = append(params, strings.Replace(s.LoggerTypeID, "log/slog", "slog", 1))
+		}
+	}
+	if s.HasOutput {
+		results = append(results, "TOut")
+	}
+	if s.HasError {
+		results = append(results, "error")
+	}
+
+	shape := "func (" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		shape += " " + results[0]
+	default:
+		shape += " (" + strings.Join(results, ", ") + ")"
+	}
+	return shape
+}
+
+// resolveHandlerSignature analyzes the signature of the referenced handler.
+// With requireTypes the AST-based analysis is skipped, e.g. to inspect the method sets of the handler types.
+// The parameters are classified by the roles of the signature map if given, which requires type checking them.
+// The type checker looks the handler up in the package given explicitly or selected at the call site, so
+// handlers of the same name in other packages aren't mistaken for it.
+func resolveHandlerSignature(r *reporter, inputFile string, file *ast.File, fset *token.FileSet, handlerRef *HandlerReference, roles []string, requireTypes bool) (*HandlerSignature, error) {
+	pkgPath := handlerRef.Package
+	if pkgPath == "" {
+		pkgPath = handlerRef.importPath(file)
+	}
+	// Function literals are found by their position, the type checker has no name for them
+	if literal := handlerRef.literal(); literal != nil && (requireTypes || roles != nil) {
+		if inputFile == "" {
+			return nil, fmt.Errorf("a filename is required to type check the handler")
+		}
+		return analyzeLiteralSignatureWithTypes(r, inputFile, file, handlerRef.SimpleName, literal, fset, roles)
+	}
+
+	// The signature of decorated handlers is the result type of the decorator, which requires type information
+	if requireTypes || roles != nil || handlerRef.Package != "" || handlerRef.Decorated {
+		if inputFile == "" {
+			return nil, fmt.Errorf("a filename is required to type check the handler")
+		}
+		return analyzeHandlerSignatureWithTypes(r, inputFile, file, handlerRef.SimpleName, handlerRef.receiver(), pkgPath, handlerRef.TypeArgs != nil, handlerRef.Decorated, fset, roles)
+	}
+
+	// First try AST-based analysis (works for handlers in the same file)
+	handlerSig, err := analyzeHandlerSignature(file, handlerRef.SimpleName, handlerRef.TypeArgs, handlerRef.receiver() != "", handlerRef.literal())
+	if err == nil || errors.Is(err, errVariadicHandler) || errors.Is(err, errErrorFirstHandler) {
+		return handlerSig, err
+	}
+
+	// If not found in AST, try type-based analysis (works for imported handlers)
+	if inputFile == "" {
+		return nil, fmt.Errorf("%w (a filename is required to type check the handler)", err)
+	}
+	r.logf("Could not analyze handler from the file (%v), trying type checker...", err)
+	if literal := handlerRef.literal(); literal != nil {
+		return analyzeLiteralSignatureWithTypes(r, inputFile, file, handlerRef.SimpleName, literal, fset, nil)
+	}
+	return analyzeHandlerSignatureWithTypes(r, inputFile, file, handlerRef.SimpleName, handlerRef.receiver(), pkgPath, handlerRef.TypeArgs != nil, handlerRef.Decorated, fset, nil)
+}
+
+// analyzeHandlerSignature analyzes the handler function signature, substituting the
+// type arguments of an explicit generic instantiation for the type parameters.
+// Method value handlers are looked up among the methods, whose receiver is not a parameter of the handler, and
+// function literal handlers are analyzed from the literal instead of a declaration.
+func analyzeHandlerSignature(file *ast.File, handlerName string, typeArgs []ast.Expr, method bool, literal *ast.FuncLit) (*HandlerSignature, error) {
+	var sig *HandlerSignature
+	var analyzeErr error
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		var fnType *ast.FuncType
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			if literal == nil && fn.Name.Name == handlerName && (fn.Recv != nil) == method {
+				fnType = fn.Type
+			}
+		case *ast.FuncLit:
+			if fn == literal {
+				fnType = fn.Type
+			}
+		}
+		if fnType != nil {
+			if (fnType.TypeParams.NumFields() > 0) != (typeArgs != nil) {
+				return false
+			}
+			subst := typeParamSubstitutions(fnType.TypeParams, typeArgs)
+			typeString := func(expr ast.Expr) string {
+				return types.ExprString(substituteExpr(expr, subst))
+			}
+			sig = &HandlerSignature{TypeImports: make(map[string]string)}
+
+			// The Lambda runtime passes a fixed number of arguments
+			for _, field := range fnType.Params.List {
+				if _, ok := field.Type.(*ast.Ellipsis); ok {
+					sig = nil
+					analyzeErr = fmt.Errorf("handler function %s has the signature %s: %w", handlerName, types.ExprString(fnType), errVariadicHandler)
+					return false
+				}
+			}
+
+			// Types of dot-imported packages are unqualified like those declared in the package, so only the type
+			// checker can tell the context, writer, input, and output types apart
+			if hasDotImport(file) && (refersToUndeclared(file, substituteFieldList(fnType.Params, subst)) ||
+				refersToUndeclared(file, substituteFieldList(fnType.Results, subst))) {
+				sig = nil
+				analyzeErr = fmt.Errorf("handler function %s has types that may be dot-imported, which require type information", handlerName)
+				return false
+			}
+
+			// A trailing io.Writer parameter receives the response writer
+			params := fnType.Params.List
+			if n := len(params); n > 0 && len(params[n-1].Names) <= 1 && isWriterExpr(file, params[n-1].Type) {
+				sig.HasWriter = true
+				params = params[:n-1]
+			}
+
+			// Other parameters beyond context and input (e.g., custom writer interfaces, or cancel functions and
+			// loggers passed by some frameworks) can only be classified with type information
+			paramList := paramTypes(params)
+			if len(paramList) > 2 || (len(paramList) == 2 && !isContextExpr(file, paramList[0])) || slices.ContainsFunc(paramList, func(expr ast.Expr) bool {
+				return auxiliaryRole(typeIDFromExpr(file, expr)) != ""
+			}) {
+				sig = nil
+				analyzeErr = fmt.Errorf("handler function %s has parameters that require type information", handlerName)
+				return false
+			}
+
+			// Analyze parameters: the context can only be passed first, and the input is the parameter left
+			// after it, so a handler taking only a context (whatever its name) has no input
+			if len(paramList) > 0 && isContextExpr(file, paramList[0]) {
+				sig.HasContext = true
+				paramList = paramList[1:]
+			}
+			if len(paramList) == 1 {
+				sig.HasInput = true
+				sig.InputType = typeString(paramList[0])
+				sig.InputTypeID = typeIDFromExpr(file, paramList[0])
+				sig.InputTypeExpr = substituteExpr(paramList[0], subst)
+				_, sig.InputIsPointer = sig.InputTypeExpr.(*ast.StarExpr)
+				sig.InputIsInterface = declaresInterface(paramList[0])
+			}
+
+			// Analyze return values
+			if fnType.Results != nil && len(fnType.Results.List) > 0 {
+				numResults := len(fnType.Results.List)
+				if numResults == 1 {
+					// Check if it's an error
+					if ident, ok := fnType.Results.List[0].Type.(*ast.Ident); ok {
+						if ident.Name == "error" {
+							sig.HasError = true
+						}
+					}
+				} else if numResults == 2 {
+					if ident, ok := fnType.Results.List[0].Type.(*ast.Ident); ok && ident.Name == "error" {
+						sig = nil
+						analyzeErr = fmt.Errorf("handler function %s has the signature %s: %w", handlerName, types.ExprString(fnType), errErrorFirstHandler)
+						return false
+					}
+
+					// (TOut, error)
+					sig.HasOutput = true
+					sig.HasError = true
+					sig.OutputType = typeString(fnType.Results.List[0].Type)
+					sig.OutputTypeID = typeIDFromExpr(file, fnType.Results.List[0].Type)
+					sig.OutputTypeExpr = substituteExpr(fnType.Results.List[0].Type, subst)
+
+					// Outputs implementing io.Reader are streamed, which only the method sets of the type checker tell
+					if mayImplementReader(file, fnType.Results.List[0].Type) {
+						sig = nil
+						analyzeErr = fmt.Errorf("handler function %s has an output that may implement io.Reader, which requires type information", handlerName)
+						return false
+					}
+				}
+			}
+
+			return false
+		}
+		return true
+	})
+
+	if analyzeErr != nil {
+		return nil, analyzeErr
+	}
+
+	if sig == nil {
+		return nil, fmt.Errorf("handler function %s not found", handlerName)
+	}
+
+	for _, expr := range []ast.Expr{sig.InputTypeExpr, sig.OutputTypeExpr} {
+		for _, name := range typeExprPackages(expr) {
+			if importPath := importPathForName(file, name); importPath != "" {
+				sig.TypeImports[name] = importPath
+			}
+		}
+	}
+
+	return sig, nil
+}
+
+// typeExprPackages returns the names of the packages qualifying types in the type expression
+func typeExprPackages(expr ast.Expr) []string {
+	var names []string
+	if expr == nil {
+		return names
+	}
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if selExpr, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selExpr.X.(*ast.Ident); ok {
+				names = append(names, ident.Name)
+			}
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// typeIDFromExpr returns the type expression qualified by import path instead of package name,
+// resolving the package name through the file's imports
+func typeIDFromExpr(file *ast.File, expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + typeIDFromExpr(file, e.X)
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok {
+			if importPath := importPathForName(file, ident.Name); importPath != "" {
+				return importPath + "." + e.Sel.Name
+			}
+		}
+	}
+	return types.ExprString(expr)
+}
+
+// importPathForName returns the path of the import referenced by the given package name in the file
+func importPathForName(file *ast.File, name string) string {
+	for _, importSpec := range file.Imports {
+		importPath := strings.Trim(importSpec.Path.Value, `"`)
+		if importSpec.Name != nil {
+			if importSpec.Name.Name == name {
+				return importPath
+			}
+		} else if assumedPackageName(importPath) == name {
+			return importPath
+		}
+	}
+	return ""
+}
+
+// hasDotImport reports whether the file imports a package with a dot, making its exported names unqualified
+func hasDotImport(file *ast.File) bool {
+	for _, importSpec := range file.Imports {
+		if importSpec.Name != nil && importSpec.Name.Name == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// refersToUndeclared reports whether the types of the fields refer to unqualified identifiers that are neither
+// predeclared nor declared at package level in the file, e.g. types of dot-imported packages
+func refersToUndeclared(file *ast.File, fields *ast.FieldList) bool {
+	if fields == nil {
+		return false
+	}
+	found := false
+	for _, field := range fields.List {
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				return false
+			case *ast.FieldList:
+				// Names of struct fields and function parameters aren't references
+				found = found || refersToUndeclared(file, n)
+				return false
+			case *ast.Ident:
+				if types.Universe.Lookup(n.Name) == nil && file.Scope.Lookup(n.Name) == nil {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// isContextExpr reports whether the type expression is context.Context, whatever name context is imported under
+func isContextExpr(file *ast.File, expr ast.Expr) bool {
+	return typeIDFromExpr(file, expr) == "context.Context"
+}
+
+// isWriterExpr reports whether the type expression refers to io.Writer, whatever name io is imported under
+func isWriterExpr(file *ast.File, expr ast.Expr) bool {
+	if selExpr, ok := expr.(*ast.SelectorExpr); ok {
+		if ident, ok := selExpr.X.(*ast.Ident); ok {
+			return selExpr.Sel.Name == "Writer" && importPathForName(file, ident.Name) == "io"
+		}
+	}
+	return false
+}
+
+// analyzeHandlerSignatureWithTypes uses the type checker to analyze handler signature
+// This works even if the handler is defined in another file or package
+// For explicitly instantiated generic handlers the instantiated signature is analyzed
+// For method values on the package-level variable receiver the method of its type is analyzed
+// Only functions of the package with the import path pkgPath are considered if given
+// For decorated handlers handlerName names the decorator, whose result is the analyzed handler
+func analyzeHandlerSignatureWithTypes(r *reporter, inputFile string, file *ast.File, handlerName, receiver, pkgPath string, instantiated, decorated bool, fset *token.FileSet, roles []string) (*HandlerSignature, error) {
+	pkg, err := loadHandlerPackage(r, inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Functions of the same name may be declared in several of the imported packages
+	inPackage := func(obj types.Object) bool {
+		return pkgPath == "" || (obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == pkgPath)
+	}
+
+	// Generic handlers are analyzed with their type arguments applied
+	if instantiated && pkg.TypesInfo != nil {
+		for id, inst := range pkg.TypesInfo.Instances {
+			if id.Name == handlerName && inPackage(pkg.TypesInfo.Uses[id]) {
+				if funcType, ok := inst.Type.(*types.Signature); ok {
+					if decorated {
+						if funcType, err = decoratedHandlerType(handlerName, funcType); err != nil {
+							return nil, err
+						}
+					}
+					return signatureFromTypes(handlerName, funcType, pkg.Types, file, roles)
+				}
+			}
+		}
+		return nil, fmt.Errorf("instantiation of generic handler function %s not found", handlerName)
+	}
+
+	// Find the handler function object in the package's type info
+	var handlerObj types.Object
+	if receiver != "" && pkg.Types != nil {
+		if recv, ok := pkg.Types.Scope().Lookup(receiver).(*types.Var); ok {
+			if method, ok := lookupMethod(recv.Type(), pkg.Types, handlerName); ok {
+				handlerObj = method
+			}
+		}
+	}
+	if handlerObj == nil && pkg.TypesInfo != nil {
+		// First check Defs (definitions in this package)
+		for id, obj := range pkg.TypesInfo.Defs {
+			if id.Name == handlerName && isHandlerObject(obj) && inPackage(obj) {
+				handlerObj = obj
+				break
+			}
+		}
+
+		// If not found locally, check Uses (imported symbols)
+		if handlerObj == nil {
+			for id, obj := range pkg.TypesInfo.Uses {
+				if id.Name == handlerName && isHandlerObject(obj) && inPackage(obj) {
+					handlerObj = obj
+					break
+				}
+			}
+		}
+	}
+
+	if handlerObj == nil && pkgPath != "" {
+		return nil, fmt.Errorf("handler function %s not found in package %s", handlerName, pkgPath)
+	}
+	if handlerObj == nil {
+		return nil, fmt.Errorf("handler function %s not found in package or imports", handlerName)
+	}
+
+	// Get the function signature (the underlying type covers vars of named func types). The parameters
+	// of a method's signature don't include its receiver
+	funcType, ok := handlerObj.Type().Underlying().(*types.Signature)
+	if !ok {
+		return nil, fmt.Errorf("handler is not a function")
+	}
+	if decorated {
+		if funcType, err = decoratedHandlerType(handlerName, funcType); err != nil {
+			return nil, err
+		}
+	}
+
+	return signatureFromTypes(handlerName, funcType, pkg.Types, file, roles)
+}
+
+// loadHandlerPackage loads the type-checked package of the input file
+func loadHandlerPackage(r *reporter, inputFile string) (*packages.Package, error) {
+	// Get absolute path
+	absPath, err := filepath.Abs(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Use packages.Load to properly handle Go modules and imports. Loading from the module root
+	// resolves the package like the go command does, including imports of internal packages
+	dir, pattern := packageDir(filepath.Dir(absPath))
+	cfg := &packages.Config{
+		Mode: packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps,
+		Dir:  dir,
+	}
+
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package: %w", err)
+	}
+
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no packages found")
+	}
+
+	pkg := pkgs[0]
+	if len(pkg.Errors) > 0 {
+		// Log errors but continue - we might still find the handler
+		for _, err := range pkg.Errors {
+			r.warnf(token.NoPos, "%v", err)
+		}
+	}
+	return pkg, nil
+}
+
+// analyzeLiteralSignatureWithTypes analyzes the signature of a function literal handler using the type checker,
+// finding the literal in the type-checked package by its position in the input file
+func analyzeLiteralSignatureWithTypes(r *reporter, inputFile string, file *ast.File, handlerName string, literal *ast.FuncLit, fset *token.FileSet, roles []string) (*HandlerSignature, error) {
+	pkg, err := loadHandlerPackage(r, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(inputFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	offset := fset.Position(literal.Pos()).Offset
+	if pkg.TypesInfo != nil && pkg.Fset != nil {
+		for expr, tv := range pkg.TypesInfo.Types {
+			if _, ok := expr.(*ast.FuncLit); !ok {
+				continue
+			}
+			if position := pkg.Fset.Position(expr.Pos()); position.Filename == absPath && position.Offset == offset {
+				if funcType, ok := tv.Type.(*types.Signature); ok {
+					return signatureFromTypes(handlerName, funcType, pkg.Types, file, roles)
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("function literal handler %s not found in package", handlerName)
+}
+
+// decoratedHandlerType returns the signature of the handler returned by the decorator with the signature
+func decoratedHandlerType(decoratorName string, decoratorType *types.Signature) (*types.Signature, error) {
+	if decoratorType.Results().Len() == 1 {
+		if funcType, ok := decoratorType.Results().At(0).Type().Underlying().(*types.Signature); ok {
+			return funcType, nil
+		}
+	}
+	return nil, fmt.Errorf("decorator %s has the signature %s, but must return the handler function only", decoratorName, decoratorType)
+}
+
+// lookupMethod looks up the method of the type, including methods promoted from embedded fields and
+// methods of the pointer type for addressable receivers
+func lookupMethod(t types.Type, pkg *types.Package, name string) (*types.Func, bool) {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, pkg, name)
+	method, ok := obj.(*types.Func)
+	return method, ok
+}
+
+// packageDir returns the directory to load the package in dir from and the pattern to load it with:
+// the root of the enclosing module with a relative pattern, or dir itself if it isn't part of a module
+func packageDir(dir string) (string, string) {
+	if root, ok := findModuleRoot(dir); ok {
+		if rel, err := filepath.Rel(root, dir); err == nil {
+			return root, "./" + filepath.ToSlash(rel)
+		}
+	}
+	return dir, "."
+}
+
+// typeID returns the type qualified by import path (e.g., "*github.com/aws/aws-lambda-go/events.SQSEvent")
+func typeID(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return p.Path()
+	})
+}
+
+// isContextType reports whether the type is context.Context
+func isContextType(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+	}
+	return false
+}
+
+// embedsContext reports whether the type is an interface embedding context.Context, directly or through
+// other embedded interfaces (e.g., interface { context.Context; RequestID() string })
+func embedsContext(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return false
+	}
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		if embedded := iface.EmbeddedType(i); isContextType(embedded) || embedsContext(embedded) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWriterInterface reports whether the type is an interface with a Write([]byte) (int, error) method that
+// http.ResponseWriter satisfies, i.e. all of its methods are among Header, Write, and WriteHeader
+func isWriterInterface(t types.Type) bool {
+	iface, ok := t.Underlying().(*types.Interface)
+	if !ok || iface.NumMethods() == 0 {
+		return false
+	}
+
+	hasWrite := false
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		switch method.Name() {
+		case "Write":
+			hasWrite = isWriteSignature(method.Type().(*types.Signature))
+		case "Header", "WriteHeader":
+		default:
+			return false
+		}
+	}
+	return hasWrite
+}
+
+// isWriteSignature reports whether the signature is func([]byte) (int, error)
+func isWriteSignature(sig *types.Signature) bool {
+	if sig.Params().Len() != 1 || sig.Results().Len() != 2 {
+		return false
+	}
+	slice, ok := sig.Params().At(0).Type().(*types.Slice)
+	if !ok || !types.Identical(slice.Elem(), types.Typ[types.Byte]) {
+		return false
+	}
+	return types.Identical(sig.Results().At(0).Type(), types.Typ[types.Int]) &&
+		sig.Results().At(1).Type().String() == "error"
+}
+
+// importQualifier qualifies types by the name the file imports their package under, omitting the name for
+// types of the current package, and records the import path of each qualifying package in imports
+func importQualifier(current *types.Package, file *ast.File, imports map[string]string) types.Qualifier {
+	return func(p *types.Package) string {
+		if p == current {
+			return ""
+		}
+		name := p.Name()
+		for _, importSpec := range file.Imports {
+			if strings.Trim(importSpec.Path.Value, `"`) == p.Path() && importSpec.Name != nil && importSpec.Name.Name != "_" {
+				name = importSpec.Name.Name
+			}
+		}
+		if name == "." {
+			return ""
+		}
+		imports[name] = p.Path()
+		return name
+	}
+}
+
+// classifyParams classifies the type-checked handler parameters by their order: an optional context first,
+// followed by an optional input and an optional trailing writer interface
+func classifyParams(sig *HandlerSignature, params *types.Tuple, qf types.Qualifier) {
+	numParams := params.Len()
+
+	// A trailing writer interface parameter receives the response writer
+	if numParams > 0 && isWriterInterface(params.At(numParams-1).Type()) {
+		sig.HasWriter = true
+		numParams--
+	}
+
+	// The context can only be passed first, and the input is the parameter left after it
+	inputIndex := 0
+	if numParams > 0 {
+		firstParam := params.At(0)
+		if isContextType(firstParam.Type()) || embedsContext(firstParam.Type()) {
+			sig.HasContext = true
+			if !isContextType(firstParam.Type()) {
+				sig.ContextType = types.TypeString(firstParam.Type(), qf)
+			}
+			inputIndex = 1
+		}
+	}
+
+	// Some frameworks pass a cancel function or logger after the context, which isn't the input (each role is
+	// taken once, like with a signature map)
+	var auxiliary []string
+	for ; inputIndex < numParams; inputIndex++ {
+		id := typeID(params.At(inputIndex).Type())
+		role := auxiliaryRole(id)
+		if role == "" || slices.Contains(auxiliary, role) {
+			break
+		}
+		setAuxiliary(sig, role, id)
+		auxiliary = append(auxiliary, role)
+	}
+	if numParams-inputIndex == 1 {
+		setInputType(sig, params.At(inputIndex).Type(), qf)
+	}
+
+	// The auxiliary parameters don't fit the order of the Lambda docs, so the roles are recorded explicitly
+	if len(auxiliary) > 0 {
+		var roles []string
+		if sig.HasContext {
+			roles = append(roles, SignatureRoleContext)
+		}
+		roles = append(roles, auxiliary...)
+		if sig.HasInput {
+			roles = append(roles, SignatureRoleInput)
+		}
+		if sig.HasWriter {
+			roles = append(roles, SignatureRoleWriter)
+		}
+		sig.ParamRoles = roles
+	}
+}
+
+// setInputType records the type-checked input type of the handler
+func setInputType(sig *HandlerSignature, t types.Type, qf types.Qualifier) {
+	sig.HasInput = true
+	sig.InputType = types.TypeString(t, qf)
+	sig.InputTypeID = typeID(t)
+	sig.InputTypeExpr = typeExpr(sig.InputType)
+	_, sig.InputIsPointer = t.(*types.Pointer)
+	sig.InputIsProto = isProtoMessage(t)
+	sig.InputIsInterface = isInterfaceInput(t)
+	sig.inputType = t
+}
+
+// setOutputType records the type-checked output type of the handler
+func setOutputType(sig *HandlerSignature, t types.Type, qf types.Qualifier) {
+	sig.HasOutput = true
+	sig.OutputType = types.TypeString(t, qf)
+	sig.OutputTypeID = typeID(t)
+	sig.OutputTypeExpr = typeExpr(sig.OutputType)
+	sig.OutputIsProto = isProtoMessage(t)
+	sig.OutputIsReader = implementsIO(t, ioReaderInterface)
+	sig.OutputIsCloser = implementsIO(t, ioCloserInterface)
+	sig.outputType = t
+}
+
+// typeExpr parses the type string printed by types.TypeString into a position-free expression
+func typeExpr(typeString string) ast.Expr {
+	expr, err := parser.ParseExpr(typeString)
+	if err != nil {
+		return nil
+	}
+	return copyExpr(expr)
+}
+
+// isHandlerObject reports whether obj can be passed as a handler to lambda.Start:
+// either a function or a package-level variable of function type
+func isHandlerObject(obj types.Object) bool {
+	switch o := obj.(type) {
+	case *types.Func:
+		return true
+	case *types.Var:
+		if o.Pkg() == nil || o.Parent() != o.Pkg().Scope() {
+			return false
+		}
+		_, ok := o.Type().Underlying().(*types.Signature)
+		return ok
+	}
+	return false
+}
+
+// signatureFromTypes analyzes a type-checked function signature, printing types relative to the
+// current package and the imports of the file. The parameters are classified by the roles of the signature map if given.
+func signatureFromTypes(handlerName string, funcType *types.Signature, current *types.Package, file *ast.File, roles []string) (*HandlerSignature, error) {
+	sig := &HandlerSignature{TypeImports: make(map[string]string)}
+	qf := importQualifier(current, file, sig.TypeImports)
+
+	// The Lambda runtime passes a fixed number of arguments
+	if funcType.Variadic() {
+		return nil, fmt.Errorf("handler function %s has the signature %s: %w", handlerName, types.TypeString(funcType, qf), errVariadicHandler)
+	}
+
+	// Check parameters
+	if roles != nil {
+		if err := mapParams(sig, handlerName, funcType.Params(), roles, qf); err != nil {
+			return nil, err
+		}
+	} else {
+		classifyParams(sig, funcType.Params(), qf)
+	}
+
+	// Check return values
+	results := funcType.Results()
+	if results != nil && results.Len() > 0 {
+		if results.Len() == 1 {
+			// Check if it's an error
+			if results.At(0).Type().String() == "error" {
+				sig.HasError = true
+			} else {
+				sig.envelope = findEnvelope(results.At(0).Type(), qf)
+			}
+		} else if results.Len() == 2 {
+			if results.At(0).Type().String() == "error" {
+				return nil, fmt.Errorf("handler function %s has the signature %s: %w", handlerName, types.TypeString(funcType, qf), errErrorFirstHandler)
+			}
+
+			// (TOut, error)
+			sig.HasError = true
+			setOutputType(sig, results.At(0).Type(), qf)
+		}
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,116 @@
+package migrator
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the files, keyed by their slash-separated path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportedHandlerKeepsEventsInput(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	// The module depends on a stub of the AWS Lambda SDK, so the package of the handler loads without network access
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"aws-lambda-go/go.mod": "module github.com/aws/aws-lambda-go\n\ngo 1.21\n",
+		"aws-lambda-go/lambda/lambda.go": `package lambda
+
+func Start(handler interface{}) {}
+`,
+		"aws-lambda-go/events/apigw.go": `package events
+
+type APIGatewayProxyRequest struct {
+	Path                            string
+	HTTPMethod                      string
+	Headers                         map[string]string
+	MultiValueHeaders               map[string][]string
+	QueryStringParameters           map[string]string
+	MultiValueQueryStringParameters map[string][]string
+	Body                            string
+}
+`,
+		"fn/go.mod": `module example.com/fn
+
+go 1.21
+
+require github.com/aws/aws-lambda-go v0.0.0
+
+replace github.com/aws/aws-lambda-go => ../aws-lambda-go
+`,
+		"fn/handler/handler.go": `package handler
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func Handle(ctx context.Context, req events.APIGatewayProxyRequest) (string, error) {
+	return req.HTTPMethod + " " + req.Path, nil
+}
+`,
+		"fn/main.go": `package main
+
+import (
+	"example.com/fn/handler"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	lambda.Start(handler.Handle)
+}
+`,
+	})
+
+	mainPath := filepath.Join(dir, "fn", "main.go")
+	src, err := os.ReadFile(mainPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Filename = mainPath
+	var buf bytes.Buffer
+	if err := TransformTo(&buf, src, opts); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	// The input type is qualified by its package, whose import is kept while the lambda import is removed
+	for _, want := range []string{`"github.com/aws/aws-lambda-go/events"`, "events.APIGatewayProxyRequest{"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generated code lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"github.com/aws/aws-lambda-go/lambda"`) {
+		t.Errorf("generated code still imports the lambda package:\n%s", out)
+	}
+
+	if err := os.WriteFile(mainPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "vet", "./...")
+	cmd.Dir = filepath.Join(dir, "fn")
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("generated code doesn't pass go vet: %v\n%s\n%s", err, output, out)
+	}
+}
@@ -0,0 +1,102 @@
+package migrator
+
+import (
+	"fmt"
+	"go/types"
+)
+
+const (
+	// SignatureRoleContext maps a handler parameter to the request context
+	SignatureRoleContext = "ctx"
+	// SignatureRoleInput maps a handler parameter to the input decoded from the request body
+	SignatureRoleInput = "input"
+	// SignatureRoleBody maps a []byte handler parameter to the raw request body
+	SignatureRoleBody = "body"
+	// SignatureRoleWriter maps a handler parameter to the response writer
+	SignatureRoleWriter = "writer"
+	// SignatureRoleCancel maps a context.CancelFunc handler parameter to the function cancelling the handler context
+	SignatureRoleCancel = "cancel"
+	// SignatureRoleLogger maps a *log.Logger or *slog.Logger handler parameter to the default logger
+	SignatureRoleLogger = "logger"
+)
+
+// paramRoles returns the role of each handler parameter, given by a signature map or implied by the order
+// of the Lambda docs otherwise
+func (s *HandlerSignature) paramRoles() []string {
+	if s.ParamRoles != nil {
+		return s.ParamRoles
+	}
+	var roles []string
+	if s.HasContext {
+		roles = append(roles, SignatureRoleContext)
+	}
+	if s.HasInput {
+		roles = append(roles, SignatureRoleInput)
+	}
+	if s.HasWriter {
+		roles = append(roles, SignatureRoleWriter)
+	}
+	return roles
+}
+
+// mapParams classifies the handler parameters by the roles of a signature map instead of their order, checking
+// that each parameter can receive the value of its role. The input is the parameter of the input role, or
+// the body's if there is none.
+func mapParams(sig *HandlerSignature, handlerName string, params *types.Tuple, roles []string, qf types.Qualifier) error {
+	if len(roles) != params.Len() {
+		return fmt.Errorf("the signature map has %d roles, but handler function %s has %d parameters", len(roles), handlerName, params.Len())
+	}
+
+	seen := make(map[string]bool)
+	var input, body types.Type
+	for i, role := range roles {
+		if seen[role] {
+			return fmt.Errorf("the signature map gives the role %s more than once", role)
+		}
+		seen[role] = true
+
+		t := params.At(i).Type()
+		ok := true
+		switch role {
+		case SignatureRoleContext:
+			ok = isContextType(t) || embedsContext(t)
+			sig.HasContext = true
+			if !isContextType(t) {
+				sig.ContextType = types.TypeString(t, qf)
+			}
+		case SignatureRoleInput:
+			input = t
+		case SignatureRoleBody:
+			ok = isByteSlice(t)
+			body = t
+		case SignatureRoleWriter:
+			ok = isWriterInterface(t)
+			sig.HasWriter = true
+		case SignatureRoleCancel, SignatureRoleLogger:
+			ok = auxiliaryRole(typeID(t)) == role
+			setAuxiliary(sig, role, typeID(t))
+		default:
+			return fmt.Errorf("unknown signature map role %q, must be one of %s, %s, %s, %s, %s, or %s", role, SignatureRoleContext, SignatureRoleInput, SignatureRoleBody, SignatureRoleWriter, SignatureRoleCancel, SignatureRoleLogger)
+		}
+		if !ok {
+			return fmt.Errorf("parameter %d of handler function %s has the type %s, which can't take the %s role", i+1, handlerName, types.TypeString(t, qf), role)
+		}
+	}
+
+	if input == nil {
+		input = body
+	} else if body != nil && typeID(input) == cloudFrontRequestID {
+		return fmt.Errorf("the %s role can't be combined with a CloudFront request input, which is mapped from the request instead of read from the body", SignatureRoleBody)
+	}
+	if input != nil {
+		setInputType(sig, input, qf)
+	}
+	sig.ParamRoles = roles
+	return nil
+}
+
+// isByteSlice reports whether the type is a byte slice, including named ones like json.RawMessage
+func isByteSlice(t types.Type) bool {
+	slice, ok := t.Underlying().(*types.Slice)
+	return ok && types.Identical(slice.Elem(), types.Typ[types.Byte])
+}
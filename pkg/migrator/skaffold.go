@@ -0,0 +1,42 @@
+package migrator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+// skaffoldManifest is the path of the Knative Service manifest deployed by the Skaffold config, relative
+// to the module root
+const skaffoldManifest = "service.yaml"
+
+// SkaffoldConfig returns the path and content of a minimal skaffold.yaml at the root of the module
+// containing the main package in mainDir, building that package with ko and deploying the Knative Service
+// manifest in skaffoldManifest, which refers to the built image by the name derived from the package
+func SkaffoldConfig(mainDir string) (string, []byte, error) {
+	root, importPath, main, err := mainPackage(mainDir)
+	if err != nil {
+		return "", nil, err
+	}
+	name := path.Base(importPath)
+
+	config := fmt.Sprintf(`# Run the migrated function in a dev loop with: skaffold dev
+# %s must define the Knative Service running the image %s
+apiVersion: skaffold/v4beta11
+kind: Config
+metadata:
+  name: %s
+build:
+  artifacts:
+  - image: %s
+    ko:
+      fromImage: %s
+      main: %s
+manifests:
+  rawYaml:
+  - %s
+deploy:
+  kubectl: {}
+`, skaffoldManifest, name, name, name, koBaseImage, main, skaffoldManifest)
+	return filepath.Join(root, "skaffold.yaml"), []byte(config), nil
+}
@@ -0,0 +1,40 @@
+package migrator
+
+import (
+	"go/types"
+	"strings"
+)
+
+// successStatus returns the status configured for the handler's output type in GenerateOptions.StatusFor,
+// or 0 if the default status applies
+func successStatus(handlerSig *HandlerSignature, opts *GenerateOptions) int {
+	if !handlerSig.HasOutput || handlerSig.outputType == nil {
+		return 0
+	}
+	for typeName, status := range opts.StatusFor {
+		if typeNameMatches(handlerSig.outputType, typeName) {
+			return status
+		}
+	}
+	return 0
+}
+
+// typeNameMatches reports whether the named type, ignoring pointers, is referred to by name, which
+// qualifies the type name either by package name (e.g., "api.Created") or by import path
+func typeNameMatches(t types.Type, name string) bool {
+	for {
+		pointer, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = pointer.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+
+	obj := named.Obj()
+	name = strings.TrimLeft(name, "*")
+	return name == obj.Pkg().Name()+"."+obj.Name() || name == obj.Pkg().Path()+"."+obj.Name()
+}
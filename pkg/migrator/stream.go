@@ -0,0 +1,162 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+const (
+	// readerID and readCloserID identify the output types streamed to the response
+	readerID     = "io.Reader"
+	readCloserID = "io.ReadCloser"
+)
+
+var (
+	// ioReaderInterface and ioCloserInterface are the method sets of io.Reader and io.Closer, which concrete
+	// output types are checked against without loading package io
+	ioReaderInterface = newIOInterface("Read", types.NewTuple(types.NewVar(token.NoPos, nil, "p", types.NewSlice(types.Typ[types.Byte]))),
+		types.NewVar(token.NoPos, nil, "n", types.Typ[types.Int]))
+	ioCloserInterface = newIOInterface("Close", nil)
+)
+
+// newIOInterface creates an interface with a single method taking the parameters and returning the results
+// followed by an error
+func newIOInterface(name string, params *types.Tuple, results ...*types.Var) *types.Interface {
+	results = append(results, types.NewVar(token.NoPos, nil, "err", types.Universe.Lookup("error").Type()))
+	sig := types.NewSignatureType(nil, nil, nil, params, types.NewTuple(results...), false)
+	return types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, nil, name, sig)}, nil).Complete()
+}
+
+// implementsIO reports whether the type is a concrete type implementing the io interface. Interface types
+// other than io.Reader and io.ReadCloser aren't streamed, as their dynamic type isn't known.
+func implementsIO(t types.Type, iface *types.Interface) bool {
+	return !types.IsInterface(t) && types.Implements(t, iface)
+}
+
+// mayImplementReader reports whether the output type expression may denote a concrete type implementing
+// io.Reader, i.e. a pointer to a standard library type (e.g., *bytes.Buffer) or a type of the file declaring
+// a Read method, so the handler signature must be type checked to tell
+func mayImplementReader(file *ast.File, expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+		if sel, ok := expr.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				importPath := importPathForName(file, pkg.Name)
+				return importPath != "" && isStdlibImport(importPath)
+			}
+		}
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Name.Name == "Read" && recvTypeName(fn.Recv.List[0].Type) == ident.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// streamsOutput reports whether the generated handler copies the output to the response instead of
+// encoding it as JSON, i.e. whether the handler returns an io.Reader or io.ReadCloser, or a concrete type
+// implementing io.Reader, which is streamed even if it could be encoded as JSON
+func streamsOutput(handlerSig *HandlerSignature) bool {
+	return handlerSig.HasOutput && (handlerSig.OutputTypeID == readerID || handlerSig.OutputTypeID == readCloserID || handlerSig.OutputIsReader)
+}
+
+// createStreamResultStmts creates the statements copying the handler result to the response, writing the
+// status first if one is given, and closing the result once copied:
+//
+//	w.WriteHeader(status)
+//	if result != nil {
+//		defer result.Close()
+//		if _, err := io.Copy(w, result); err != nil {
+//			log.Printf("Failed to stream handler output: %v", err)
+//		}
+//	}
+//
+// An io.Reader result is only closed if it is an io.Closer:
+//
+//	if closer, ok := result.(io.Closer); ok {
+//		defer closer.Close()
+//	}
+//
+// A concrete reader is closed if its type has a Close method, and a nil pointer responds with no content:
+//
+//	if result == nil {
+//		w.WriteHeader(204)
+//		return
+//	}
+func createStreamResultStmts(ioAlias, logAlias string, handlerSig *HandlerSignature, status int) []ast.Stmt {
+	var stmts []ast.Stmt
+	_, isPointer := handlerSig.OutputTypeExpr.(*ast.StarExpr)
+	if handlerSig.OutputIsReader && isPointer {
+		stmts = append(stmts, &ast.IfStmt{
+			Cond: &ast.BinaryExpr{X: ast.NewIdent("result"), Op: token.EQL, Y: ast.NewIdent("nil")},
+			Body: &ast.BlockStmt{List: []ast.Stmt{createWriteHeaderStmt(204), &ast.ReturnStmt{}}},
+		})
+	}
+	if status != 0 {
+		stmts = append(stmts, createWriteHeaderStmt(status))
+	}
+
+	var closeStmt ast.Stmt
+	switch {
+	case handlerSig.OutputTypeID == readCloserID || handlerSig.OutputIsCloser:
+		closeStmt = &ast.DeferStmt{
+			Call: &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("result"), Sel: ast.NewIdent("Close")}},
+		}
+	case handlerSig.OutputTypeID == readerID:
+		closeStmt = &ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("closer"), ast.NewIdent("ok")},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{&ast.TypeAssertExpr{
+					X:    ast.NewIdent("result"),
+					Type: &ast.SelectorExpr{X: ast.NewIdent(ioAlias), Sel: ast.NewIdent("Closer")},
+				}},
+			},
+			Cond: ast.NewIdent("ok"),
+			Body: &ast.BlockStmt{List: []ast.Stmt{&ast.DeferStmt{
+				Call: &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("closer"), Sel: ast.NewIdent("Close")}},
+			}}},
+		}
+	}
+
+	copyStmt := &ast.IfStmt{
+		Init: &ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("_"), ast.NewIdent("err")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent(ioAlias), Sel: ast.NewIdent("Copy")},
+				Args: []ast.Expr{ast.NewIdent("w"), ast.NewIdent("result")},
+			}},
+		},
+		Cond: &ast.BinaryExpr{X: ast.NewIdent("err"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{
+			X: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{X: ast.NewIdent(logAlias), Sel: ast.NewIdent("Printf")},
+				Args: []ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: `"Failed to stream handler output: %v"`},
+					ast.NewIdent("err"),
+				},
+			},
+		}}},
+	}
+
+	copyStmts := []ast.Stmt{copyStmt}
+	if closeStmt != nil {
+		copyStmts = []ast.Stmt{closeStmt, copyStmt}
+	}
+	if handlerSig.OutputIsReader {
+		return append(stmts, copyStmts...)
+	}
+
+	// A nil interface result streams an empty body
+	return append(stmts, &ast.IfStmt{
+		Cond: &ast.BinaryExpr{X: ast.NewIdent("result"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+		Body: &ast.BlockStmt{List: copyStmts},
+	})
+}
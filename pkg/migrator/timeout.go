@@ -0,0 +1,123 @@
+package migrator
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+)
+
+// errTimeoutWriterHandler is returned when a timeout is configured for a handler writing the response itself,
+// which could keep writing after the generated handler responded with 504 and returned
+var errTimeoutWriterHandler = errors.New("handlers writing the response themselves can't be wrapped with a timeout")
+
+// createTimeoutCallStmts wraps the statement calling the handler with a goroutine, responding with 504 if
+// the handler context is done before the handler returns. The results are declared upfront, and panics are
+// raised again in the calling goroutine so they are recovered like those of unwrapped handlers. The onTimeout
+// statements run before responding with 504:
+//
+//	var result T
+//	var err error
+//	var panicked any
+//	done := make(chan struct{})
+//	go func() {
+//		defer close(done)
+//		defer func() {
+//			panicked = recover()
+//		}()
+//		result, err = handlerFuncName(args...)
+//	}()
+//	select {
+//	case <-done:
+//		if panicked != nil {
+//			panic(panicked)
+//		}
+//	case <-ctx.Done():
+//		w.WriteHeader(504)
+//		return
+//	}
+func createTimeoutCallStmts(callStmt ast.Stmt, handlerSig *HandlerSignature, onTimeout ...ast.Stmt) []ast.Stmt {
+	var decls []ast.Stmt
+	declare := func(name string, typ ast.Expr) {
+		decls = append(decls, &ast.DeclStmt{Decl: &ast.GenDecl{
+			Tok:   token.VAR,
+			Specs: []ast.Spec{&ast.ValueSpec{Names: []*ast.Ident{ast.NewIdent(name)}, Type: typ}},
+		}})
+	}
+	if assign, ok := callStmt.(*ast.AssignStmt); ok {
+		assign.Tok = token.ASSIGN
+		if handlerSig.EnvelopeErrField != "" {
+			declare("envelope", copyExpr(handlerSig.envelope.typeExpr))
+		} else if handlerSig.HasOutput {
+			declare("result", copyExpr(handlerSig.OutputTypeExpr))
+		}
+		if handlerSig.HasError {
+			declare("err", ast.NewIdent("error"))
+		}
+	}
+	declare("panicked", ast.NewIdent("any"))
+
+	goStmt := &ast.GoStmt{
+		Call: &ast.CallExpr{
+			Fun: &ast.FuncLit{
+				Type: &ast.FuncType{Params: &ast.FieldList{}},
+				Body: &ast.BlockStmt{List: []ast.Stmt{
+					&ast.DeferStmt{
+						Call: &ast.CallExpr{Fun: ast.NewIdent("close"), Args: []ast.Expr{ast.NewIdent("done")}},
+					},
+					&ast.DeferStmt{
+						Call: &ast.CallExpr{
+							Fun: &ast.FuncLit{
+								Type: &ast.FuncType{Params: &ast.FieldList{}},
+								Body: &ast.BlockStmt{List: []ast.Stmt{
+									&ast.AssignStmt{
+										Lhs: []ast.Expr{ast.NewIdent("panicked")},
+										Tok: token.ASSIGN,
+										Rhs: []ast.Expr{&ast.CallExpr{Fun: ast.NewIdent("recover")}},
+									},
+								}},
+							},
+						},
+					},
+					callStmt,
+				}},
+			},
+		},
+	}
+
+	selectStmt := &ast.SelectStmt{
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.CommClause{
+				Comm: &ast.ExprStmt{X: &ast.UnaryExpr{Op: token.ARROW, X: ast.NewIdent("done")}},
+				Body: []ast.Stmt{
+					&ast.IfStmt{
+						Cond: &ast.BinaryExpr{X: ast.NewIdent("panicked"), Op: token.NEQ, Y: ast.NewIdent("nil")},
+						Body: &ast.BlockStmt{List: []ast.Stmt{
+							&ast.ExprStmt{X: &ast.CallExpr{Fun: ast.NewIdent("panic"), Args: []ast.Expr{ast.NewIdent("panicked")}}},
+						}},
+					},
+				},
+			},
+			&ast.CommClause{
+				Comm: &ast.ExprStmt{X: &ast.UnaryExpr{
+					Op: token.ARROW,
+					X:  &ast.CallExpr{Fun: &ast.SelectorExpr{X: ast.NewIdent("ctx"), Sel: ast.NewIdent("Done")}},
+				}},
+				Body: append(onTimeout, createWriteStatusStmts(504)...),
+			},
+		}},
+	}
+
+	// The empty struct braces need positions on the same line to be printed as struct{}
+	return append(decls,
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent("done")},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun:  ast.NewIdent("make"),
+				Args: []ast.Expr{&ast.ChanType{Dir: ast.SEND | ast.RECV, Value: &ast.StructType{Fields: &ast.FieldList{Opening: 1, Closing: 1}}}},
+			}},
+		},
+		goStmt,
+		selectStmt,
+	)
+}
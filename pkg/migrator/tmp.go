@@ -0,0 +1,90 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// fsWriteFuncs are the functions writing to the filesystem path given as their first argument, by import path
+var fsWriteFuncs = map[string]map[string]bool{
+	"os":        {"Create": true, "WriteFile": true, "OpenFile": true, "Mkdir": true, "MkdirAll": true, "CreateTemp": true, "MkdirTemp": true},
+	"io/ioutil": {"WriteFile": true, "TempFile": true, "TempDir": true},
+}
+
+// tempDirFuncs are the functions creating files or directories in os.TempDir() if given an empty directory
+var tempDirFuncs = map[string]bool{"os.CreateTemp": true, "os.MkdirTemp": true, "io/ioutil.TempFile": true, "io/ioutil.TempDir": true}
+
+// findTmpWrites finds the calls writing to /tmp in the body of a handler declared in the file
+func findTmpWrites(file *ast.File, handlerName string) []*ast.CallExpr {
+	var calls []*ast.CallExpr
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != handlerName || fn.Body == nil {
+			continue
+		}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			callExpr, ok := n.(*ast.CallExpr)
+			if !ok || len(callExpr.Args) == 0 {
+				return true
+			}
+			name := qualifiedFuncName(file, callExpr.Fun)
+			importPath, funcName, _ := strings.Cut(name, ".")
+			if !fsWriteFuncs[importPath][funcName] {
+				return true
+			}
+			if isTmpPath(file, callExpr.Args[0]) || (tempDirFuncs[name] && isEmptyString(callExpr.Args[0])) {
+				calls = append(calls, callExpr)
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// qualifiedFuncName returns the name of a function of an imported package qualified by its import path
+// (e.g., "os.Create"), or an empty string if the expression doesn't refer to one
+func qualifiedFuncName(file *ast.File, expr ast.Expr) string {
+	selExpr, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	ident, ok := selExpr.X.(*ast.Ident)
+	if !ok || ident.Obj != nil {
+		return ""
+	}
+	importPath := importPathForName(file, ident.Name)
+	if importPath == "" {
+		return ""
+	}
+	return importPath + "." + selExpr.Sel.Name
+}
+
+// isTmpPath reports whether the expression is a path in /tmp, i.e. a literal starting with /tmp, a call of
+// os.TempDir, or a filepath.Join or path.Join call whose first element is one
+func isTmpPath(file *ast.File, expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return false
+		}
+		path, err := strconv.Unquote(e.Value)
+		return err == nil && (path == "/tmp" || strings.HasPrefix(path, "/tmp/"))
+	case *ast.CallExpr:
+		switch qualifiedFuncName(file, e.Fun) {
+		case "os.TempDir":
+			return true
+		case "path/filepath.Join", "path.Join":
+			return len(e.Args) > 0 && isTmpPath(file, e.Args[0])
+		}
+	}
+	return false
+}
+
+// isEmptyString reports whether the expression is the "" literal
+func isEmptyString(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING && (lit.Value == `""` || lit.Value == "``")
+}
@@ -0,0 +1,180 @@
+package migrator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+)
+
+const (
+	// validateOutputName is the name of the generated package-level hook validating the handler output
+	validateOutputName = "validateOutput"
+	// ValidateOutputTag is the build tag of the file setting the output validation hook
+	ValidateOutputTag = "validateoutput"
+)
+
+// errUnvalidatedOutput is returned when output validation is requested for a handler whose output isn't
+// encoded as JSON, which the output schema describes
+var errUnvalidatedOutput = errors.New("output validation requires a handler output encoded as JSON")
+
+// validatesOutput reports whether the generated handler passes the result to the output validation hook,
+// i.e. whether validation is requested for an output encoded as JSON
+func validatesOutput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return opts.ValidateOutput && encodesJSONOutput(handlerSig, opts)
+}
+
+// encodesJSONOutput reports whether the generated handler encodes the output with encoding/json
+func encodesJSONOutput(handlerSig *HandlerSignature, opts *GenerateOptions) bool {
+	return handlerSig.HasOutput && handlerSig.OutputTypeID != cloudFrontResponseID && !mapsAPIGatewayResponse(handlerSig) && !streamsOutput(handlerSig) && !encodesProtoOutput(handlerSig, opts) && !handlerSig.RawOutput
+}
+
+// createValidateOutputDecl creates the declaration of the output validation hook, which is only set in
+// builds with the ValidateOutputTag, so production builds skip the validation:
+//
+//	var validateOutput func(any)
+func createValidateOutputDecl() *ast.GenDecl {
+	return &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(validateOutputName)},
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("any")}}},
+			},
+		}},
+	}
+}
+
+// createValidateOutputStmt creates the statement passing the result to the output validation hook if set:
+//
+//	if validateOutput != nil {
+//		validateOutput(result)
+//	}
+func createValidateOutputStmt() ast.Stmt {
+	return &ast.IfStmt{
+		Cond: &ast.BinaryExpr{X: ast.NewIdent(validateOutputName), Op: token.NEQ, Y: ast.NewIdent("nil")},
+		Body: &ast.BlockStmt{List: []ast.Stmt{&ast.ExprStmt{
+			X: &ast.CallExpr{Fun: ast.NewIdent(validateOutputName), Args: []ast.Expr{ast.NewIdent("result")}},
+		}}},
+	}
+}
+
+// writeOutputValidator writes the file of the migrated package that sets the output validation hook in
+// builds with the ValidateOutputTag. The hook encodes the result like Handle and logs where the encoded
+// JSON doesn't match the output schema, which is derived from the output type like the OpenAPI document's.
+func writeOutputValidator(w io.Writer, packageName string, handlerSig *HandlerSignature, opts *GenerateOptions) error {
+	if !encodesJSONOutput(handlerSig, opts) {
+		return errUnvalidatedOutput
+	}
+	schema, err := json.MarshalIndent(handlerSig.outputTypeSchema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode output schema: %w", err)
+	}
+
+	src := fmt.Sprintf(`//go:build %s
+
+package %s
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+)
+
+// outputSchema is the JSON Schema of the handler output, derived from its type when it was migrated
+const outputSchema = %s
+
+func init() {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(outputSchema), &schema); err != nil {
+		panic(fmt.Sprintf("invalid output schema: %%v", err))
+	}
+	%s = func(result any) {
+		data, err := json.Marshal(result)
+		if err != nil {
+			log.Printf("Failed to encode handler output for validation: %%v", err)
+			return
+		}
+		var value any
+		if err := json.Unmarshal(data, &value); err != nil {
+			log.Printf("Failed to decode handler output for validation: %%v", err)
+			return
+		}
+		if err := checkOutputSchema(schema, value, "$"); err != nil {
+			log.Printf("Handler output doesn't match its schema: %%v", err)
+		}
+	}
+}
+
+// checkOutputSchema checks the decoded JSON value at the path against the schema. Null matches any schema,
+// as encoding/json encodes nil pointers, slices, and maps as null.
+func checkOutputSchema(schema map[string]any, value any, path string) error {
+	if value == nil {
+		return nil
+	}
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%%s is %%T, not an object", path, value)
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%%s lacks the required member %%q", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		for name, member := range object {
+			memberSchema, ok := properties[name].(map[string]any)
+			if !ok {
+				memberSchema = additional
+			}
+			if err := checkOutputSchema(memberSchema, member, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%%s is %%T, not an array", path, value)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range array {
+			if err := checkOutputSchema(items, item, fmt.Sprintf("%%s[%%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%%s is %%T, not a string", path, value)
+		}
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%%s is %%T, not a number", path, value)
+		}
+		if schema["type"] == "integer" && number != math.Trunc(number) {
+			return fmt.Errorf("%%s is %%v, not an integer", path, number)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%%s is %%T, not a boolean", path, value)
+		}
+	}
+	return nil
+}
+`, ValidateOutputTag, packageName, "`"+string(schema)+"`", validateOutputName)
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
@@ -0,0 +1,73 @@
+package migrator
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"slices"
+)
+
+// Warning is an advisory issue found while migrating a handler that doesn't prevent the migration
+type Warning struct {
+	Pos     token.Position // Position of the code the warning refers to (invalid if unknown)
+	Message string
+}
+
+// String returns the warning prefixed with its file:line position if known
+func (w Warning) String() string {
+	if w.Pos.IsValid() {
+		return fmt.Sprintf("%s:%d: %s", w.Pos.Filename, w.Pos.Line, w.Message)
+	}
+	return w.Message
+}
+
+// WarningsError is returned with Options.FailOnWarning if the migration succeeded but reported warnings
+type WarningsError struct {
+	Warnings []Warning
+}
+
+func (e *WarningsError) Error() string {
+	return fmt.Sprintf("migration reported %d warning(s)", len(e.Warnings))
+}
+
+// reporter writes progress messages and warnings to the log, collecting the warnings
+type reporter struct {
+	log      io.Writer
+	fset     *token.FileSet
+	warnings []Warning
+}
+
+// newReporter creates a reporter writing to w, discarding everything if w is nil
+func newReporter(w io.Writer, fset *token.FileSet) *reporter {
+	if w == nil {
+		w = io.Discard
+	}
+	return &reporter{log: w, fset: fset}
+}
+
+// logf writes a progress message
+func (r *reporter) logf(format string, args ...any) {
+	fmt.Fprintf(r.log, format+"\n", args...)
+}
+
+// warnf records and writes a warning about the code at pos, which may be token.NoPos. Warnings already reported,
+// e.g. while analyzing a source once per handler it registers, are skipped.
+func (r *reporter) warnf(pos token.Pos, format string, args ...any) {
+	warning := Warning{Message: fmt.Sprintf(format, args...)}
+	if pos.IsValid() {
+		warning.Pos = r.fset.Position(pos)
+	}
+	if slices.Contains(r.warnings, warning) {
+		return
+	}
+	r.warnings = append(r.warnings, warning)
+	fmt.Fprintf(r.log, "Warning: %s\n", warning)
+}
+
+// check returns a WarningsError if failOnWarning is set and warnings were reported
+func (r *reporter) check(failOnWarning bool) error {
+	if failOnWarning && len(r.warnings) > 0 {
+		return &WarningsError{Warnings: r.warnings}
+	}
+	return nil
+}
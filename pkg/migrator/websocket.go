@@ -0,0 +1,52 @@
+package migrator
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// websocketRequestID identifies the API Gateway WebSocket event type
+const websocketRequestID = eventsPkgPath + ".APIGatewayWebsocketProxyRequest"
+
+// websocketFields are the request context fields tying a WebSocket event to its route and connection
+var websocketFields = map[string]bool{
+	"RouteKey":         true,
+	"EventType":        true,
+	"ConnectionID":     true,
+	"ConnectedAt":      true,
+	"MessageID":        true,
+	"MessageDirection": true,
+	"DomainName":       true,
+	"Stage":            true,
+}
+
+// checkWebsocketHandler rejects handlers of API Gateway WebSocket events, which don't map to a single HTTP
+// request, reporting where the handler declared in the file uses route and connection fields
+func checkWebsocketHandler(r *reporter, file *ast.File, handlerRef *HandlerReference, handlerSig *HandlerSignature) error {
+	if !handlerSig.HasInput || strings.TrimPrefix(handlerSig.InputTypeID, "*") != websocketRequestID {
+		return nil
+	}
+
+	var uses []string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == handlerRef.SimpleName && fn.Body != nil {
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if selExpr, ok := n.(*ast.SelectorExpr); ok && websocketFields[selExpr.Sel.Name] {
+					r.warnf(selExpr.Sel.Pos(), "WebSocket handler uses the %s of the request context", selExpr.Sel.Name)
+					uses = append(uses, selExpr.Sel.Name)
+				}
+				return true
+			})
+		}
+	}
+
+	details := "its routes ($connect, $disconnect, $default, and custom route keys) are selected by RequestContext.RouteKey " +
+		"and replies are posted to RequestContext.ConnectionID through the API Gateway Management API"
+	if len(uses) > 0 {
+		details += fmt.Sprintf("; the handler uses %s (see the warnings above)", strings.Join(uses, ", "))
+	}
+	return fmt.Errorf("%s handles API Gateway WebSocket events (%s), which have no direct HTTP mapping: %s. "+
+		"Keep the WebSocket API in front of the function or move the connection handling into a dedicated service",
+		handlerRef.QualifiedName, handlerSig.InputType, details)
+}
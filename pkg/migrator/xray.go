@@ -0,0 +1,144 @@
+package migrator
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// xrayModulePath is the module path of the AWS X-Ray SDK, which sends traces to the X-Ray daemon Lambda runs
+const xrayModulePath = "github.com/aws/aws-xray-sdk-go"
+
+const (
+	// xrayPkgPath is the import path of the xray package of the X-Ray SDK
+	xrayPkgPath = xrayModulePath + "/xray"
+	// xrayTraceHeaderEnv is the environment variable Lambda passes the trace header of the invocation in
+	xrayTraceHeaderEnv = "_X_AMZN_TRACE_ID"
+)
+
+// xrayRemovedCalls are the functions of the X-Ray SDK only instrumenting or configuring it, whose calls are
+// removed when used as statements
+var xrayRemovedCalls = map[string]bool{
+	xrayPkgPath + ".Configure":                                  true,
+	xrayPkgPath + ".AWS":                                        true,
+	xrayPkgPath + ".AddAnnotation":                              true,
+	xrayPkgPath + ".AddMetadata":                                true,
+	xrayModulePath + "/instrumentation/awsv2.AWSV2Instrumentor": true,
+}
+
+// isXRayImport reports whether the import path refers to a package of the X-Ray SDK
+func isXRayImport(importPath string) bool {
+	return inModule(xrayModulePath, importPath)
+}
+
+// findXRayImports finds the imports of packages of the X-Ray SDK left in the import declarations of the file
+func findXRayImports(file *ast.File) []*ast.ImportSpec {
+	var imports []*ast.ImportSpec
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			for _, spec := range genDecl.Specs {
+				if importSpec, ok := spec.(*ast.ImportSpec); ok && isXRayImport(strings.Trim(importSpec.Path.Value, `"`)) {
+					imports = append(imports, importSpec)
+				}
+			}
+		}
+	}
+	return imports
+}
+
+// findXRayRefs finds the references to functions, types, and variables of packages of the X-Ray SDK in the file
+func findXRayRefs(file *ast.File) []*ast.SelectorExpr {
+	var refs []*ast.SelectorExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if selExpr, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selExpr.X.(*ast.Ident); ok && ident.Obj == nil && isXRayImport(importPathForName(file, ident.Name)) {
+				refs = append(refs, selExpr)
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// findXRayEnvReads finds the os.Getenv and os.LookupEnv calls reading the trace header or the configuration
+// of the X-Ray SDK (AWS_XRAY_*) from the environment Lambda sets up
+func findXRayEnvReads(file *ast.File) []*ast.CallExpr {
+	var calls []*ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		callExpr, ok := n.(*ast.CallExpr)
+		if !ok || len(callExpr.Args) != 1 {
+			return true
+		}
+		if name := qualifiedFuncName(file, callExpr.Fun); name != "os.Getenv" && name != "os.LookupEnv" {
+			return true
+		}
+		if lit, ok := callExpr.Args[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if env, err := strconv.Unquote(lit.Value); err == nil && (env == xrayTraceHeaderEnv || strings.HasPrefix(env, "AWS_XRAY_")) {
+				calls = append(calls, callExpr)
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+// stripXRay removes the X-Ray instrumentation from the file and returns the number of calls removed or
+// unwrapped:
+//
+//	xray.Configure(...)                 // removed, like xray.AWS, xray.AddAnnotation, and awsv2.AWSV2Instrumentor
+//	xray.Client(client)                 // unwrapped to client
+//	xray.Capture(ctx, "name", fn)       // unwrapped to fn(ctx)
+//	xray.CaptureAsync(ctx, "name", fn)  // unwrapped to go fn(ctx)
+//
+// The imports of the X-Ray SDK the file no longer refers to are removed, other uses (e.g., of segments) are
+// kept.
+func stripXRay(file *ast.File) int {
+	stripped := 0
+	astutil.Apply(file, nil, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.ExprStmt:
+			callExpr, ok := n.X.(*ast.CallExpr)
+			if !ok {
+				break
+			}
+			name := qualifiedFuncName(file, callExpr.Fun)
+			if xrayRemovedCalls[name] && c.Index() >= 0 {
+				c.Delete()
+				stripped++
+			} else if name == xrayPkgPath+".CaptureAsync" && len(callExpr.Args) == 3 {
+				c.Replace(&ast.GoStmt{Go: n.Pos(), Call: &ast.CallExpr{Fun: callExpr.Args[2], Args: callExpr.Args[:1]}})
+				stripped++
+			}
+		case *ast.CallExpr:
+			switch qualifiedFuncName(file, n.Fun) {
+			case xrayPkgPath + ".Client":
+				// A nil client is wrapped as http.DefaultClient, which unwrapping it would lose
+				if len(n.Args) == 1 && !isNilIdent(n.Args[0]) {
+					c.Replace(n.Args[0])
+					stripped++
+				}
+			case xrayPkgPath + ".Capture":
+				if len(n.Args) == 3 {
+					c.Replace(&ast.CallExpr{Fun: n.Args[2], Args: n.Args[:1]})
+					stripped++
+				}
+			}
+		}
+		return true
+	})
+
+	refs := packageRefs(file)
+	removeImports(file, func(importSpec *ast.ImportSpec) bool {
+		return isXRayImport(strings.Trim(importSpec.Path.Value, `"`)) && !refs[importName(importSpec)]
+	})
+	return stripped
+}
+
+// isNilIdent reports whether the expression is the nil identifier
+func isNilIdent(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "nil"
+}